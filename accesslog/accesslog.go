@@ -0,0 +1,152 @@
+// Package accesslog reads and writes a compact binary log of cache accesses,
+// for offline trace analysis and as simulator input from production traffic.
+//
+// A log starts with the magic bytes "lfa1" and is followed by one record per
+// access: the nanoseconds since the previous record as a uvarint, the
+// operation as a byte, the key's Hash as 8 little endian bytes, and the entry's
+// size in whole bytes as a uvarint.  The first record's time is relative to the
+// Unix epoch.
+package accesslog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bparli/lfuda-go/internal/keyhash"
+)
+
+var magic = []byte("lfa1")
+
+// ErrFormat is returned when reading a log which isn't in the access log format.
+var ErrFormat = errors.New("accesslog: invalid log")
+
+// Op is the kind of an access.
+type Op byte
+
+const (
+	// Hit is a Get which found its key.
+	Hit Op = iota + 1
+	// Miss is a Get which didn't.
+	Miss
+	// Set is a Set which stored its value.
+	Set
+)
+
+// Hash returns the hash recorded for key: the FNV-1a hash of its contents for
+// strings, byte slices and numbers, or of its %v formatting otherwise.
+func Hash(key interface{}) uint64 {
+	return keyhash.Sum(key)
+}
+
+// Record is one access.
+type Record struct {
+	Time    time.Time
+	Op      Op
+	KeyHash uint64
+	// Size is the entry's size, 0 for misses
+	Size float64
+}
+
+// Writer writes records to an underlying writer, buffered.  It's safe for
+// concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	last int64
+	err  error
+}
+
+// NewWriter creates a Writer writing a log to w.  Flush must be called to write
+// out the buffered records.
+func NewWriter(w io.Writer) *Writer {
+	l := &Writer{w: bufio.NewWriter(w)}
+	_, l.err = l.w.Write(magic)
+	return l
+}
+
+// Write adds a record to the log.  Records should be written in time order;
+// earlier times are recorded as the previous record's.  Once writing fails every
+// later Write and Flush returns the error.
+func (l *Writer) Write(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	now := r.Time.UnixNano()
+	delta := now - l.last
+	if delta < 0 {
+		delta = 0
+	} else {
+		l.last = now
+	}
+	var buf [2*binary.MaxVarintLen64 + 9]byte
+	n := binary.PutUvarint(buf[:], uint64(delta))
+	buf[n] = byte(r.Op)
+	binary.LittleEndian.PutUint64(buf[n+1:], r.KeyHash)
+	n += 9
+	n += binary.PutUvarint(buf[n:], uint64(math.Round(math.Max(0, r.Size))))
+	_, l.err = l.w.Write(buf[:n])
+	return l.err
+}
+
+// Flush writes any buffered records to the underlying writer.
+func (l *Writer) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.err = l.w.Flush()
+	return l.err
+}
+
+// Reader reads records from a log.
+type Reader struct {
+	r       *bufio.Reader
+	last    int64
+	started bool
+}
+
+// NewReader creates a Reader reading a log from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF at the end of the log.
+func (l *Reader) Read() (Record, error) {
+	if !l.started {
+		var m [4]byte
+		if _, err := io.ReadFull(l.r, m[:]); err != nil || string(m[:]) != string(magic) {
+			return Record{}, ErrFormat
+		}
+		l.started = true
+	}
+
+	delta, err := binary.ReadUvarint(l.r)
+	if err == io.EOF {
+		return Record{}, io.EOF
+	} else if err != nil {
+		return Record{}, ErrFormat
+	}
+	var buf [9]byte
+	if _, err := io.ReadFull(l.r, buf[:]); err != nil {
+		return Record{}, ErrFormat
+	}
+	size, err := binary.ReadUvarint(l.r)
+	if err != nil {
+		return Record{}, ErrFormat
+	}
+	l.last += int64(delta)
+	return Record{
+		Time:    time.Unix(0, l.last),
+		Op:      Op(buf[0]),
+		KeyHash: binary.LittleEndian.Uint64(buf[1:]),
+		Size:    float64(size),
+	}, nil
+}
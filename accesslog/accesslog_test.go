@@ -0,0 +1,48 @@
+package accesslog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Now()
+	records := []Record{
+		{Time: start, Op: Miss, KeyHash: 1},
+		{Time: start.Add(time.Millisecond), Op: Set, KeyHash: 1, Size: 100},
+		{Time: start.Add(2 * time.Millisecond), Op: Hit, KeyHash: 1 << 63, Size: 100},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, r := range records {
+		w.Write(r)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 4+3*(10+9+2) {
+		t.Errorf("log should be compact: %d bytes", buf.Len())
+	}
+
+	r := NewReader(&buf)
+	for i, want := range records {
+		got, err := r.Read()
+		if err != nil || !got.Time.Equal(want.Time) || got.Op != want.Op || got.KeyHash != want.KeyHash || got.Size != want.Size {
+			t.Errorf("record %d: got %+v, %v, want %+v", i, got, err, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("log should end with EOF: %v", err)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("nope"))).Read(); err != ErrFormat {
+		t.Errorf("bad magic should be rejected: %v", err)
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("lfa1\x01\x01"))).Read(); err != ErrFormat {
+		t.Errorf("truncated records should be rejected: %v", err)
+	}
+}
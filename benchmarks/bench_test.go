@@ -0,0 +1,265 @@
+package benchmarks
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/dgraph-io/ristretto"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// number of entries each cache is able to hold
+	capacity = 4096
+	// number of distinct keys in each trace
+	keySpace = 8 * capacity
+	// length of each generated trace
+	traceLen = 1 << 20
+)
+
+// benchCache is the common denominator of the compared caches
+type benchCache interface {
+	Set(key, value int64)
+	Get(key int64) bool
+}
+
+type lfudaCache struct{ c *lfuda.Cache }
+
+func (l lfudaCache) Set(key, value int64) { l.c.Set(key, value) }
+func (l lfudaCache) Get(key int64) bool {
+	_, ok := l.c.Get(key)
+	return ok
+}
+
+type lruCache struct{ c *lru.Cache }
+
+func (l lruCache) Set(key, value int64) { l.c.Add(key, value) }
+func (l lruCache) Get(key int64) bool {
+	_, ok := l.c.Get(key)
+	return ok
+}
+
+// ristrettoCache buffers sets and applies them asynchronously, as ristretto does
+// by default, unless sync is set, when it waits for every set to be applied.
+// Waiting makes hit ratios comparable with the synchronous caches but
+// understates ristretto's throughput, so both modes are reported.
+type ristrettoCache struct {
+	c    *ristretto.Cache
+	sync bool
+}
+
+func (r ristrettoCache) Set(key, value int64) {
+	r.c.Set(key, value, 1)
+	if r.sync {
+		r.c.Wait()
+	}
+}
+
+// Wait applies the buffered sets.
+func (r ristrettoCache) Wait() { r.c.Wait() }
+func (r ristrettoCache) Get(key int64) bool {
+	_, ok := r.c.Get(key)
+	return ok
+}
+
+type implementation struct {
+	name string
+	new  func() benchCache
+}
+
+// every value is an int64 so a byte sized lfuda cache holds capacity entries
+var implementations = []implementation{
+	{"lfuda", func() benchCache { return lfudaCache{lfuda.New(capacity * 8)} }},
+	{"gdsf", func() benchCache { return lfudaCache{lfuda.NewGDSF(capacity * 8)} }},
+	{"lfu", func() benchCache { return lfudaCache{lfuda.NewLFU(capacity * 8)} }},
+	{"golang-lru", func() benchCache {
+		c, err := lru.New(capacity)
+		if err != nil {
+			panic(err)
+		}
+		return lruCache{c}
+	}},
+	{"ristretto", func() benchCache { return newRistretto(false) }},
+	{"ristretto-sync", func() benchCache { return newRistretto(true) }},
+}
+
+func newRistretto(sync bool) benchCache {
+	c, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: capacity * 10,
+		MaxCost:     capacity,
+		BufferItems: 64,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return ristrettoCache{c, sync}
+}
+
+// waiter is implemented by caches which apply sets asynchronously.
+type waiter interface {
+	Wait()
+}
+
+type trace struct {
+	name string
+	keys []int64
+}
+
+func zipfTrace(seed int64, s float64) []int64 {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, s, 1, keySpace-1)
+	keys := make([]int64, traceLen)
+	for i := range keys {
+		keys[i] = int64(z.Uint64())
+	}
+	return keys
+}
+
+func uniformTrace(seed int64) []int64 {
+	r := rand.New(rand.NewSource(seed))
+	keys := make([]int64, traceLen)
+	for i := range keys {
+		keys[i] = r.Int63n(keySpace)
+	}
+	return keys
+}
+
+// shiftingTrace changes its popular set halfway through, which is where
+// dynamic aging is expected to pay off over plain LFU
+func shiftingTrace(seed int64) []int64 {
+	keys := zipfTrace(seed, 1.1)
+	for i := len(keys) / 2; i < len(keys); i++ {
+		keys[i] = (keys[i] + keySpace/2) % keySpace
+	}
+	return keys
+}
+
+var traces = []trace{
+	{"zipf-1.01", zipfTrace(1, 1.01)},
+	{"zipf-1.2", zipfTrace(2, 1.2)},
+	{"uniform", uniformTrace(3)},
+	{"shifting", shiftingTrace(4)},
+}
+
+func warm(c benchCache, keys []int64) {
+	for _, k := range keys[:capacity*4] {
+		c.Set(k, k)
+	}
+	// the fill is complete before anything is measured
+	if w, ok := c.(waiter); ok {
+		w.Wait()
+	}
+}
+
+// BenchmarkGetOrSet replays each trace as a read-through cache: a Get
+// followed by a Set on a miss.
+func BenchmarkGetOrSet(b *testing.B) {
+	for _, tr := range traces {
+		for _, impl := range implementations {
+			b.Run(fmt.Sprintf("%s/%s", tr.name, impl.name), func(b *testing.B) {
+				c := impl.new()
+				var hits, misses int
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					k := tr.keys[i%len(tr.keys)]
+					if c.Get(k) {
+						hits++
+					} else {
+						misses++
+						c.Set(k, k)
+					}
+				}
+				b.ReportMetric(float64(hits)/float64(hits+misses), "hit-ratio")
+			})
+		}
+	}
+}
+
+// BenchmarkGet measures lookups against a cache warmed with the same trace.
+func BenchmarkGet(b *testing.B) {
+	for _, tr := range traces {
+		for _, impl := range implementations {
+			b.Run(fmt.Sprintf("%s/%s", tr.name, impl.name), func(b *testing.B) {
+				c := impl.new()
+				warm(c, tr.keys)
+				var hits int
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if c.Get(tr.keys[i%len(tr.keys)]) {
+						hits++
+					}
+				}
+				b.ReportMetric(float64(hits)/float64(b.N), "hit-ratio")
+			})
+		}
+	}
+}
+
+// BenchmarkSet measures inserts, including any evictions they cause.
+func BenchmarkSet(b *testing.B) {
+	for _, tr := range traces {
+		for _, impl := range implementations {
+			b.Run(fmt.Sprintf("%s/%s", tr.name, impl.name), func(b *testing.B) {
+				c := impl.new()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					k := tr.keys[i%len(tr.keys)]
+					c.Set(k, k)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkGetOrSetParallel is BenchmarkGetOrSet with concurrent callers,
+// which is where lock contention shows up.
+func BenchmarkGetOrSetParallel(b *testing.B) {
+	for _, tr := range traces {
+		for _, impl := range implementations {
+			b.Run(fmt.Sprintf("%s/%s", tr.name, impl.name), func(b *testing.B) {
+				c := impl.new()
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Intn(len(tr.keys))
+					for pb.Next() {
+						k := tr.keys[i%len(tr.keys)]
+						if !c.Get(k) {
+							c.Set(k, k)
+						}
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+// TestHitRatios checks every implementation can replay every trace and logs
+// the resulting hit ratios (visible with -v).
+func TestHitRatios(t *testing.T) {
+	for _, tr := range traces {
+		for _, impl := range implementations {
+			c := impl.new()
+			var hits int
+			keys := tr.keys[:traceLen/8]
+			for _, k := range keys {
+				if c.Get(k) {
+					hits++
+				} else {
+					c.Set(k, k)
+				}
+			}
+			ratio := float64(hits) / float64(len(keys))
+			if ratio <= 0 || ratio >= 1 {
+				t.Errorf("%s/%s: implausible hit ratio %f", tr.name, impl.name, ratio)
+			}
+			t.Logf("%-10s %-10s hit ratio %.4f", tr.name, impl.name, ratio)
+		}
+	}
+}
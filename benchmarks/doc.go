@@ -0,0 +1,15 @@
+// Package benchmarks compares lfuda against other popular Go caches on
+// identical access traces.
+//
+// It lives in its own module so the comparison libraries never become
+// dependencies of lfuda itself.  Run it with:
+//
+//	cd benchmarks && go test -bench . -benchmem
+//
+// Besides the usual ns/op and allocs/op figures every benchmark reports a
+// "hit-ratio" metric so throughput and effectiveness can be compared side by side.
+// ristretto applies sets asynchronously, so it is reported twice: as "ristretto"
+// as it runs by default, and as "ristretto-sync" waiting for every set, whose
+// hit ratios are comparable with the synchronous caches but whose throughput
+// understates ristretto's.
+package benchmarks
@@ -0,0 +1,19 @@
+module github.com/bparli/lfuda-go/benchmarks
+
+go 1.19
+
+replace github.com/bparli/lfuda-go => ../
+
+require (
+	github.com/bparli/lfuda-go v0.0.0-00010101000000-000000000000
+	github.com/dgraph-io/ristretto v0.1.1
+	github.com/hashicorp/golang-lru v1.0.2
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/dustin/go-humanize v1.0.0 // indirect
+	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/sys v0.0.0-20221010170243-090e33056c14 // indirect
+)
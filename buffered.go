@@ -0,0 +1,137 @@
+package lfuda
+
+import (
+	"sync"
+	"time"
+)
+
+// Buffered wraps a Cache so that Set only enqueues into a ring buffer and a
+// background goroutine applies the queued sets in batches under the cache lock.
+//
+// Callers never contend on the cache lock when setting, at the cost of eventual
+// visibility: a value can be read back only once its Set has been applied.  Flush
+// forces all pending sets to be applied.  Applied sets are logged, restored and
+// reported to hooks as direct Sets are, with their durations measured from when
+// they were enqueued.  Every other method is served directly by the underlying
+// Cache.
+type Buffered struct {
+	*Cache
+	sets      chan setOp
+	flush     chan chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// held for reading while enqueueing, so no set is enqueued once Close has
+	// started draining
+	closeLock sync.RWMutex
+	closed    bool
+}
+
+type setOp struct {
+	key   interface{}
+	value interface{}
+	// when the set was enqueued, for hooks
+	start time.Time
+}
+
+// NewBuffered wraps c with a Set buffer holding up to bufferSize pending sets.
+func NewBuffered(c *Cache, bufferSize int) *Buffered {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	b := &Buffered{
+		Cache:   c,
+		sets:    make(chan setOp, bufferSize),
+		flush:   make(chan chan struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.apply()
+	return b
+}
+
+// Set enqueues the key/value to be added to the cache.  Returns false if the
+// buffer is full or the Buffered closed, in which case the set is dropped.
+func (b *Buffered) Set(key, value interface{}) bool {
+	b.closeLock.RLock()
+	defer b.closeLock.RUnlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.sets <- setOp{key, value, b.Cache.hookStart()}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Flush blocks until every set enqueued before the call has been applied.
+func (b *Buffered) Flush() {
+	ack := make(chan struct{})
+	select {
+	case b.flush <- ack:
+		<-ack
+	case <-b.stopped:
+	}
+}
+
+// Close applies any pending sets and stops the background goroutine.  Sets
+// after Close are dropped.
+func (b *Buffered) Close() {
+	b.closeOnce.Do(func() {
+		b.closeLock.Lock()
+		b.closed = true
+		b.closeLock.Unlock()
+		close(b.stop)
+	})
+	<-b.stopped
+}
+
+func (b *Buffered) apply() {
+	defer close(b.stopped)
+	for {
+		select {
+		case op := <-b.sets:
+			b.applyBatch("Buffered.apply", op)
+		case ack := <-b.flush:
+			b.drain("Buffered.Flush")
+			close(ack)
+		case <-b.stop:
+			b.drain("Buffered.Close")
+			return
+		}
+	}
+}
+
+// applyBatch applies op along with whatever else is already queued, taking the
+// cache lock only once, with name naming what applied them for the lock guard
+// and slow op hook.
+func (b *Buffered) applyBatch(name string, op setOp) {
+	batch := []setOp{op}
+	b.Cache.writeLock(name, nil)
+	b.Cache.set(op.key, op.value)
+	for n := len(b.sets); n > 0; n-- {
+		op = <-b.sets
+		b.Cache.set(op.key, op.value)
+		batch = append(batch, op)
+	}
+	b.Cache.publish()
+	b.Cache.writeUnlock()
+	for _, op := range batch {
+		b.Cache.hookSet(op.key, op.start)
+	}
+}
+
+// drain applies everything queued, for Flush or Close as name says.
+func (b *Buffered) drain(name string) {
+	for {
+		select {
+		case op := <-b.sets:
+			b.applyBatch(name, op)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package lfuda
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bparli/lfuda-go/accesslog"
+	"github.com/bparli/lfuda-go/internal/keyhash"
+)
+
+func TestBufferedFlush(t *testing.T) {
+	b := NewBuffered(New(1000), 64)
+	defer b.Close()
+
+	for i := 0; i < 50; i++ {
+		if !b.Set(i, i) {
+			t.Fatalf("set %d should have been enqueued", i)
+		}
+	}
+	b.Flush()
+
+	if b.Len() != 50 {
+		t.Errorf("all enqueued sets should be applied after Flush: %d", b.Len())
+	}
+	if v, ok := b.Get(42); !ok || v != 42 {
+		t.Errorf("bad value after Flush: %v, %v", v, ok)
+	}
+}
+
+func TestBufferedFull(t *testing.T) {
+	c := New(1000)
+	b := NewBuffered(c, 1)
+	defer b.Close()
+
+	// hold the cache lock so the applier cannot make progress
+	c.lock.Lock()
+	dropped := false
+	for i := 0; i < 10; i++ {
+		if !b.Set(i, i) {
+			dropped = true
+		}
+	}
+	c.lock.Unlock()
+
+	if !dropped {
+		t.Errorf("sets beyond the buffer size should be dropped")
+	}
+}
+
+func TestBufferedClose(t *testing.T) {
+	b := NewBuffered(New(1000), 16)
+	b.Set(1, 1)
+	b.Close()
+	b.Close()
+	b.Flush()
+
+	if !b.Contains(1) {
+		t.Errorf("pending sets should be applied on Close")
+	}
+}
+
+func TestBufferedClosedSet(t *testing.T) {
+	b := NewBuffered(New(1000), 16)
+	b.Close()
+	if b.Set(1, 1) {
+		t.Errorf("sets after Close should be refused")
+	}
+}
+
+func TestBufferedHooks(t *testing.T) {
+	h := &recordingHooks{}
+	var log bytes.Buffer
+	w := accesslog.NewWriter(&log)
+	b := NewBuffered(New(1000, WithHooks(h), WithAccessLog(w)), 16)
+	defer b.Close()
+	b.Set("a", "a")
+	b.Set("b", "b")
+	b.Flush()
+	if len(h.sets) != 2 || h.sets[0] != "a" {
+		t.Errorf("applied sets should be reported to hooks: %v", h.sets)
+	}
+	w.Flush()
+	r := accesslog.NewReader(&log)
+	if rec, err := r.Read(); err != nil || rec.Op != accesslog.Set || rec.KeyHash != keyhash.Sum("a") {
+		t.Errorf("applied sets should be logged: %+v, %v", rec, err)
+	}
+}
+
+func TestBufferedSlowOpNames(t *testing.T) {
+	var mu sync.Mutex
+	ops := make(map[string]bool)
+	b := NewBuffered(New(1000, WithSlowOpHook(0, func(op SlowOp) {
+		mu.Lock()
+		ops[op.Op] = true
+		mu.Unlock()
+	})), 64)
+	b.Set(1, 1)
+	for deadline := time.Now().Add(time.Second); b.Len() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	b.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !ops["Buffered.apply"] || ops["Buffered.Flush"] {
+		t.Errorf("sets applied in the background shouldn't be reported as Flush: %v", ops)
+	}
+}
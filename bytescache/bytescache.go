@@ -0,0 +1,407 @@
+// Package bytescache provides a thread-safe LFUDA/GDSF cache restricted to
+// string keys and []byte values, built for high throughput at large sizes.
+//
+// Value sizes are simply their lengths so no reflection or formatting is done on
+// Set, and entries and frequency nodes live in preallocated slices linked by
+// index rather than in individually allocated list elements.  This keeps the
+// number of heap objects (and so GC work) per entry to the key and value
+// themselves.  Within a frequency node entries are kept in insertion order, so
+// ties are broken least recently promoted first.
+package bytescache
+
+import "sync"
+
+const null = -1
+
+// EvictCallback is used to get a callback when an entry is evicted.
+type EvictCallback func(key string, value []byte)
+
+type policy int
+
+const (
+	lfuda policy = iota
+	gdsf
+)
+
+type entry struct {
+	key      string
+	value    []byte
+	hits     float64
+	priority float64
+	// frequency node holding the entry and the neighbouring entries in it
+	node int32
+	prev int32
+	next int32
+}
+
+type node struct {
+	priority float64
+	// neighbouring frequency nodes
+	prev int32
+	next int32
+	// first and last entries in this node
+	head int32
+	tail int32
+}
+
+// Cache is a thread-safe fixed size cache of []byte values.
+type Cache struct {
+	lock     sync.Mutex
+	policy   policy
+	capacity int64
+	size     int64
+	age      float64
+	onEvict  EvictCallback
+
+	index       map[string]int32
+	entries     []entry
+	freeEntries []int32
+	nodes       []node
+	freeNodes   []int32
+	// lowest and highest priority frequency nodes
+	head int32
+	tail int32
+}
+
+// New creates a Cache holding up to capacity bytes of values under the LFUDA
+// policy, with room preallocated for expectedEntries entries.
+func New(capacity int64, expectedEntries int) *Cache {
+	return newCache(capacity, expectedEntries, lfuda, nil)
+}
+
+// NewGDSF creates a Cache holding up to capacity bytes of values under the GDSF
+// policy, with room preallocated for expectedEntries entries.
+func NewGDSF(capacity int64, expectedEntries int) *Cache {
+	return newCache(capacity, expectedEntries, gdsf, nil)
+}
+
+// NewWithEvict constructs an LFUDA Cache with the given eviction callback.
+func NewWithEvict(capacity int64, expectedEntries int, onEvict EvictCallback) *Cache {
+	return newCache(capacity, expectedEntries, lfuda, onEvict)
+}
+
+// NewGDSFWithEvict constructs a GDSF Cache with the given eviction callback.
+func NewGDSFWithEvict(capacity int64, expectedEntries int, onEvict EvictCallback) *Cache {
+	return newCache(capacity, expectedEntries, gdsf, onEvict)
+}
+
+func newCache(capacity int64, expectedEntries int, p policy, onEvict EvictCallback) *Cache {
+	if expectedEntries < 0 {
+		expectedEntries = 0
+	}
+	return &Cache{
+		policy:   p,
+		capacity: capacity,
+		onEvict:  onEvict,
+		index:    make(map[string]int32, expectedEntries),
+		entries:  make([]entry, 0, expectedEntries),
+		nodes:    make([]node, 0, 64),
+		head:     null,
+		tail:     null,
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	i, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	c.increment(i)
+	return c.entries[i].value, true
+}
+
+// Peek looks up a key's value without incrementing its hits.
+func (c *Cache) Peek(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if i, ok := c.index[key]; ok {
+		return c.entries[i].value, true
+	}
+	return nil, false
+}
+
+// Contains checks if a key is in the cache without incrementing its hits.
+func (c *Cache) Contains(key string) bool {
+	c.lock.Lock()
+	_, ok := c.index[key]
+	c.lock.Unlock()
+	return ok
+}
+
+// Set adds a value to the cache.  Returns true if an eviction occurred.  Values
+// larger than the cache's capacity are not stored.
+func (c *Cache) Set(key string, value []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	size := int64(len(value))
+	if size > c.capacity {
+		return false
+	}
+
+	evicted := false
+	if i, ok := c.index[key]; ok {
+		// value already exists for key.  overwrite
+		c.size += size - int64(len(c.entries[i].value))
+		c.entries[i].value = value
+		c.increment(i)
+		for c.size > c.capacity && c.evict(i) {
+			evicted = true
+		}
+		return evicted
+	}
+
+	for c.size+size > c.capacity && c.evict(null) {
+		evicted = true
+	}
+
+	i := c.allocEntry()
+	c.entries[i] = entry{key: key, value: value, node: null, prev: null, next: null}
+	c.index[key] = i
+	c.size += size
+	c.increment(i)
+	return evicted
+}
+
+// Remove removes the provided key from the cache, returning if the key was
+// contained.
+func (c *Cache) Remove(key string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.remove(key)
+}
+
+// Purge completely clears the cache.
+func (c *Cache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.onEvict != nil {
+		for key, i := range c.index {
+			c.onEvict(key, c.entries[i].value)
+		}
+	}
+	// reallocate rather than truncate so the old keys and values can be collected
+	c.index = make(map[string]int32, len(c.index))
+	c.entries = make([]entry, 0, cap(c.entries))
+	c.freeEntries = nil
+	c.nodes = make([]node, 0, cap(c.nodes))
+	c.freeNodes = nil
+	c.head, c.tail = null, null
+	c.size = 0
+	c.age = 0
+}
+
+// Keys returns a slice of the keys in the cache ordered by priority, highest first.
+func (c *Cache) Keys() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	keys := make([]string, 0, len(c.index))
+	for n := c.tail; n != null; n = c.nodes[n].prev {
+		for i := c.nodes[n].tail; i != null; i = c.entries[i].prev {
+			keys = append(keys, c.entries[i].key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of items in the cache.
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.index)
+}
+
+// Size returns the current size of the cache in bytes.
+func (c *Cache) Size() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.size
+}
+
+// Age returns the cache's current age.
+func (c *Cache) Age() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.age
+}
+
+func (c *Cache) priority(e *entry) float64 {
+	if c.policy == gdsf {
+		size := float64(len(e.value))
+		if size < 1 {
+			size = 1
+		}
+		return e.hits/size + c.age
+	}
+	return e.hits + c.age
+}
+
+// increment bumps an entry's hits and moves it to the frequency node matching
+// its new priority.
+func (c *Cache) increment(i int32) {
+	e := &c.entries[i]
+	old := e.node
+	e.hits++
+	e.priority = c.priority(e)
+
+	// search from the entry's current node (or the lowest node for new
+	// entries) for the nodes either side of the new priority.  The priority
+	// only drops when an overwrite grows a GDSF entry's value.
+	prev, next := int32(null), c.head
+	if old != null {
+		if e.priority >= c.nodes[old].priority {
+			prev, next = old, c.nodes[old].next
+		} else {
+			prev, next = c.nodes[old].prev, old
+		}
+	}
+	for next != null && c.nodes[next].priority < e.priority {
+		prev, next = next, c.nodes[next].next
+	}
+	for prev != null && c.nodes[prev].priority > e.priority {
+		prev, next = c.nodes[prev].prev, prev
+	}
+
+	var target int32
+	switch {
+	case prev != null && c.nodes[prev].priority == e.priority:
+		target = prev
+	case next != null && c.nodes[next].priority == e.priority:
+		target = next
+	default:
+		target = c.insertNode(e.priority, prev, next)
+	}
+	if target == old {
+		return
+	}
+
+	if old != null {
+		c.unlinkEntry(i)
+	}
+	c.linkEntry(i, target)
+}
+
+// evict removes the lowest priority entry other than skip.  Returns false if
+// there was nothing to evict.
+func (c *Cache) evict(skip int32) bool {
+	for n := c.head; n != null; n = c.nodes[n].next {
+		for i := c.nodes[n].head; i != null; i = c.entries[i].next {
+			if i == skip {
+				continue
+			}
+			e := &c.entries[i]
+			// cache age should be less than or equal to the minimum key value in the cache
+			if c.age < e.priority {
+				c.age = e.priority
+			}
+			c.remove(e.key)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) remove(key string) bool {
+	i, ok := c.index[key]
+	if !ok {
+		return false
+	}
+	e := c.entries[i]
+	delete(c.index, key)
+	c.unlinkEntry(i)
+	c.size -= int64(len(e.value))
+	c.entries[i] = entry{}
+	c.freeEntries = append(c.freeEntries, i)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+	return true
+}
+
+func (c *Cache) allocEntry() int32 {
+	if n := len(c.freeEntries); n > 0 {
+		i := c.freeEntries[n-1]
+		c.freeEntries = c.freeEntries[:n-1]
+		return i
+	}
+	c.entries = append(c.entries, entry{})
+	return int32(len(c.entries) - 1)
+}
+
+// insertNode creates a frequency node between prev and next.
+func (c *Cache) insertNode(priority float64, prev, next int32) int32 {
+	var n int32
+	if free := len(c.freeNodes); free > 0 {
+		n = c.freeNodes[free-1]
+		c.freeNodes = c.freeNodes[:free-1]
+	} else {
+		c.nodes = append(c.nodes, node{})
+		n = int32(len(c.nodes) - 1)
+	}
+	c.nodes[n] = node{priority: priority, prev: prev, next: next, head: null, tail: null}
+
+	if prev != null {
+		c.nodes[prev].next = n
+	} else {
+		c.head = n
+	}
+	if next != null {
+		c.nodes[next].prev = n
+	} else {
+		c.tail = n
+	}
+	return n
+}
+
+func (c *Cache) removeNode(n int32) {
+	prev, next := c.nodes[n].prev, c.nodes[n].next
+	if prev != null {
+		c.nodes[prev].next = next
+	} else {
+		c.head = next
+	}
+	if next != null {
+		c.nodes[next].prev = prev
+	} else {
+		c.tail = prev
+	}
+	c.freeNodes = append(c.freeNodes, n)
+}
+
+// linkEntry appends an entry to the end of frequency node n.
+func (c *Cache) linkEntry(i, n int32) {
+	e := &c.entries[i]
+	e.node = n
+	e.next = null
+	e.prev = c.nodes[n].tail
+	if e.prev != null {
+		c.entries[e.prev].next = i
+	} else {
+		c.nodes[n].head = i
+	}
+	c.nodes[n].tail = i
+}
+
+// unlinkEntry removes an entry from its frequency node, removing the node too
+// if it is left empty.
+func (c *Cache) unlinkEntry(i int32) {
+	e := &c.entries[i]
+	n := e.node
+	if e.prev != null {
+		c.entries[e.prev].next = e.next
+	} else {
+		c.nodes[n].head = e.next
+	}
+	if e.next != null {
+		c.entries[e.next].prev = e.prev
+	} else {
+		c.nodes[n].tail = e.prev
+	}
+	e.node, e.prev, e.next = null, null, null
+	if c.nodes[n].head == null {
+		c.removeNode(n)
+	}
+}
@@ -0,0 +1,155 @@
+package bytescache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// verify checks the cache's internal links and accounting are consistent.
+func verify(t *testing.T, c *Cache) {
+	t.Helper()
+	var size int64
+	count := 0
+	prev := int32(null)
+	for n := c.head; n != null; n = c.nodes[n].next {
+		if c.nodes[n].prev != prev {
+			t.Fatalf("bad node back link at %d", n)
+		}
+		if prev != null && c.nodes[prev].priority >= c.nodes[n].priority {
+			t.Fatalf("nodes out of order: %f >= %f", c.nodes[prev].priority, c.nodes[n].priority)
+		}
+		if c.nodes[n].head == null {
+			t.Fatalf("empty node %d", n)
+		}
+		for i := c.nodes[n].head; i != null; i = c.entries[i].next {
+			e := c.entries[i]
+			if e.node != n || e.priority != c.nodes[n].priority || c.index[e.key] != i {
+				t.Fatalf("bad entry %q", e.key)
+			}
+			size += int64(len(e.value))
+			count++
+		}
+		prev = n
+	}
+	if prev != c.tail || count != len(c.index) || size != c.size || size > c.capacity {
+		t.Fatalf("bad accounting: %d entries, %d bytes", count, size)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := New(2, 2)
+	c.Set("a", []byte("a"))
+	c.Set("b", []byte("b"))
+	if v, ok := c.Get("a"); !ok || string(v) != "a" {
+		t.Errorf("bad value: %q, %v", v, ok)
+	}
+
+	// b is the least frequently used
+	if !c.Set("c", []byte("c")) {
+		t.Errorf("set should have evicted")
+	}
+	if c.Contains("b") || !c.Contains("a") || !c.Contains("c") {
+		t.Errorf("b should have been evicted: %v", c.Keys())
+	}
+	if c.Age() != 1 {
+		t.Errorf("age should be the evicted entry's priority: %f", c.Age())
+	}
+	c.Get("a")
+	if keys := c.Keys(); keys[0] != "a" {
+		t.Errorf("a should be the highest priority key: %v", keys)
+	}
+
+	if c.Set("big", []byte("too big")) || c.Contains("big") {
+		t.Errorf("oversized values should not be stored")
+	}
+
+	c.Remove("a")
+	if c.Len() != 1 || c.Size() != 1 {
+		t.Errorf("bad len/size after remove: %d, %d", c.Len(), c.Size())
+	}
+	verify(t, c)
+
+	c.Purge()
+	if c.Len() != 0 || c.Size() != 0 || c.Age() != 0 {
+		t.Errorf("cache should be empty after Purge")
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	evicted := 0
+	c := NewGDSFWithEvict(10, 0, func(key string, value []byte) { evicted++ })
+	c.Set("a", []byte("aaaa"))
+	c.Set("b", []byte("bbbb"))
+	c.Set("a", []byte("aaaaaa"))
+	verify(t, c)
+
+	if c.Size() != 10 || evicted != 0 {
+		t.Errorf("overwrite should update the size: %d", c.Size())
+	}
+	c.Set("a", []byte("aaaaaaaa"))
+	verify(t, c)
+	if c.Contains("b") || c.Size() != 8 || evicted != 1 {
+		t.Errorf("growing a value should evict other entries: %v, %d", c.Keys(), c.Size())
+	}
+}
+
+func TestTieBreak(t *testing.T) {
+	c := New(3, 3)
+	c.Set("a", []byte("a"))
+	c.Set("b", []byte("b"))
+	c.Set("c", []byte("c"))
+
+	// all share hits=1, so the first inserted goes first
+	c.Set("d", []byte("d"))
+	if c.Contains("a") {
+		t.Errorf("ties should be broken by insertion order: %v", c.Keys())
+	}
+}
+
+func TestRandom(t *testing.T) {
+	for _, c := range []*Cache{New(1000, 100), NewGDSF(1000, 100)} {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 20000; i++ {
+			key := fmt.Sprint(r.Intn(300))
+			switch r.Intn(10) {
+			case 0:
+				c.Remove(key)
+			case 1, 2, 3:
+				c.Set(key, make([]byte, r.Intn(40)))
+			default:
+				c.Get(key)
+			}
+		}
+		verify(t, c)
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := New(1<<20, 1<<14)
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+	value := make([]byte, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set(keys[i%len(keys)], value)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := New(1<<20, 1<<14)
+	keys := make([]string, 1<<14)
+	value := make([]byte, 64)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+		c.Set(keys[i], value)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(keys[i%len(keys)])
+	}
+}
@@ -0,0 +1,68 @@
+// Command lfudasim simulates lfuda caches on an access log recorded with
+// lfuda.WithAccessLog.
+//
+//	lfudasim -trace access.log -size 1048576
+//	lfudasim -trace access.log -target 0.9
+//
+// With -size it reports the hit ratios of a cache of that many bytes.  With
+// -target it searches for the smallest capacity reaching that hit ratio and
+// prints the projected miss curve.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bparli/lfuda-go/accesslog"
+	"github.com/bparli/lfuda-go/simcache"
+)
+
+func main() {
+	tracePath := flag.String("trace", "", "access log to simulate")
+	size := flag.Float64("size", 0, "capacity in bytes to simulate")
+	target := flag.Float64("target", 0, "hit ratio to recommend a capacity for")
+	policy := flag.String("policy", "lfuda", "eviction policy: lfuda, gdsf or lfu")
+	flag.Parse()
+	if *tracePath == "" || (*size <= 0) == (*target <= 0) {
+		fmt.Fprintln(os.Stderr, "usage: lfudasim -trace file (-size bytes | -target ratio) [-policy lfuda|gdsf|lfu]")
+		os.Exit(2)
+	}
+
+	newCache := map[string]func(float64) *simcache.Cache{
+		"lfuda": func(size float64) *simcache.Cache { return simcache.New(size) },
+		"gdsf":  func(size float64) *simcache.Cache { return simcache.NewGDSF(size) },
+		"lfu":   func(size float64) *simcache.Cache { return simcache.NewLFU(size) },
+	}[*policy]
+	if newCache == nil {
+		log.Fatalf("unknown policy %q", *policy)
+	}
+
+	f, err := os.Open(*tracePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	trace, err := simcache.ReadTrace(accesslog.NewReader(f))
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *size > 0 {
+		s := simcache.Run(trace, newCache(*size))
+		fmt.Printf("accesses %d  hit ratio %.4f  byte hit ratio %.4f\n", s.Hits+s.Misses, s.HitRatio(), s.ByteHitRatio())
+		return
+	}
+
+	r := simcache.Recommend(trace, *target, newCache)
+	fmt.Println("size\tmiss ratio")
+	for _, p := range r.Curve {
+		fmt.Printf("%.0f\t%.4f\n", p.Size, p.MissRatio)
+	}
+	if !r.Achieved {
+		fmt.Printf("target %.4f not reachable; holding every key (%.0f bytes) hits %.4f\n", *target, r.Size, r.HitRatio)
+		os.Exit(1)
+	}
+	fmt.Printf("recommended size %.0f bytes, hit ratio %.4f\n", r.Size, r.HitRatio)
+}
@@ -0,0 +1,93 @@
+package lfuda
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+// Codec converts values to and from bytes for features which persist, spill or
+// compress cached values.  Implementations can wrap protobuf, msgpack, zstd with
+// a dictionary and so on without the cache depending on any of them.
+type Codec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// BytesCodec is a Codec which passes []byte values through unchanged.
+type BytesCodec struct{}
+
+// Marshal returns value, which must be a []byte.
+func (BytesCodec) Marshal(value interface{}) ([]byte, error) {
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("lfuda: BytesCodec can't marshal %T", value)
+	}
+	return data, nil
+}
+
+// Unmarshal returns data.
+func (BytesCodec) Unmarshal(data []byte) (interface{}, error) {
+	return data, nil
+}
+
+// StringCodec is a Codec for string values, such as keys.
+type StringCodec struct{}
+
+// Marshal returns the bytes of value, which must be a string.
+func (StringCodec) Marshal(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("lfuda: StringCodec can't marshal %T", value)
+	}
+	return []byte(s), nil
+}
+
+// Unmarshal returns data as a string.
+func (StringCodec) Unmarshal(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+// GzipCodec is a Codec which gzip compresses the output of another Codec, or of
+// BytesCodec if Codec is nil.
+type GzipCodec struct {
+	Codec Codec
+}
+
+func (g GzipCodec) inner() Codec {
+	if g.Codec == nil {
+		return BytesCodec{}
+	}
+	return g.Codec
+}
+
+// Marshal encodes value with the inner codec and compresses the result.
+func (g GzipCodec) Marshal(value interface{}) ([]byte, error) {
+	data, err := g.inner().Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decompresses data and decodes it with the inner codec.
+func (g GzipCodec) Unmarshal(data []byte) (interface{}, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	data, err = ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	return g.inner().Unmarshal(data)
+}
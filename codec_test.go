@@ -0,0 +1,51 @@
+package lfuda
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+// intCodec is a minimal user supplied codec
+type intCodec struct{}
+
+func (intCodec) Marshal(value interface{}) ([]byte, error) {
+	return []byte(strconv.Itoa(value.(int))), nil
+}
+
+func (intCodec) Unmarshal(data []byte) (interface{}, error) {
+	return strconv.Atoi(string(data))
+}
+
+func TestBytesCodec(t *testing.T) {
+	var c Codec = BytesCodec{}
+	data, err := c.Marshal([]byte("abc"))
+	if err != nil || string(data) != "abc" {
+		t.Errorf("bad marshal: %q, %v", data, err)
+	}
+	if _, err := c.Marshal("abc"); err == nil {
+		t.Errorf("non []byte values should not marshal")
+	}
+}
+
+func TestGzipCodec(t *testing.T) {
+	in := bytes.Repeat([]byte("abc"), 100)
+	var c Codec = GzipCodec{}
+	data, err := c.Marshal(in)
+	if err != nil || len(data) >= len(in) {
+		t.Fatalf("bad marshal: %d bytes, %v", len(data), err)
+	}
+	out, err := c.Unmarshal(data)
+	if err != nil || !bytes.Equal(out.([]byte), in) {
+		t.Errorf("round trip failed: %v", err)
+	}
+
+	c = GzipCodec{Codec: intCodec{}}
+	data, _ = c.Marshal(42)
+	if v, err := c.Unmarshal(data); err != nil || v != 42 {
+		t.Errorf("round trip through the inner codec failed: %v, %v", v, err)
+	}
+	if _, err := c.Unmarshal([]byte("not gzip")); err == nil {
+		t.Errorf("corrupt data should fail")
+	}
+}
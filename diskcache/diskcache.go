@@ -0,0 +1,188 @@
+// Package diskcache manages a bounded directory of files with lfuda.
+//
+// Each cached entry is a file in the directory whose on-disk size is its cost,
+// so the cache keeps the directory under its capacity in bytes.  Files are
+// deleted from disk when their entry is evicted.  File names are the URL safe
+// base64 encoding of their keys, so a directory left by a previous process is
+// adopted again by New.
+package diskcache
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bparli/lfuda-go"
+)
+
+// file names are limited to 255 bytes, which holds 191 bytes of base64 encoded key
+const maxKeyLen = 191
+
+// temporary files are dot prefixed, which the base64 encoding never produces
+const tempPrefix = ".tmp-"
+
+var (
+	// ErrNotFound is returned by Open when there is no file cached for a key.
+	ErrNotFound = errors.New("diskcache: not found")
+
+	// ErrTooLarge is returned by Put when a file is larger than the whole cache.
+	ErrTooLarge = errors.New("diskcache: file larger than cache capacity")
+
+	// ErrKeyTooLong is returned by Put for keys which can't be used as a file name.
+	ErrKeyTooLong = errors.New("diskcache: key too long")
+)
+
+// Cache is a bounded cache of files in a directory.
+type Cache struct {
+	dir      string
+	capacity float64
+	cache    *lfuda.Cache
+
+	// serializes changes to the directory so a file is never deleted by an
+	// eviction after it has been replaced by a newer Put
+	mu sync.Mutex
+}
+
+// New creates a Cache keeping up to capacity bytes of files in dir using the
+// LFUDA policy.  The directory is created if needed, and files already in it are
+// added to the cache.
+func New(dir string, capacity float64) (*Cache, error) {
+	return newCache(dir, capacity, lfuda.NewWithEvict)
+}
+
+// NewGDSF creates a Cache keeping up to capacity bytes of files in dir using the
+// GDSF policy, which favours keeping many small files over a few large ones.
+func NewGDSF(dir string, capacity float64) (*Cache, error) {
+	return newCache(dir, capacity, lfuda.NewGDSFWithEvict)
+}
+
+func newCache(dir string, capacity float64, build func(float64, func(interface{}, interface{}), ...lfuda.Option) *lfuda.Cache) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: dir, capacity: capacity}
+	c.cache = build(capacity, func(key, value interface{}) {
+		os.Remove(value.(string))
+	})
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range files {
+		name := info.Name()
+		path := filepath.Join(dir, name)
+		if strings.HasPrefix(name, tempPrefix) {
+			// left over from an interrupted Put
+			os.Remove(path)
+			continue
+		}
+		key, err := base64.RawURLEncoding.DecodeString(name)
+		if err != nil || !info.Mode().IsRegular() {
+			// not ours
+			continue
+		}
+		if !c.cache.SetWithSize(string(key), path, float64(info.Size())) && !c.cache.Contains(string(key)) {
+			os.Remove(path)
+		}
+	}
+	return c, nil
+}
+
+// Path returns the path of the file cached for key, counting it as a hit.
+func (c *Cache) Path(key string) (string, bool) {
+	path, ok := c.cache.Get(key)
+	if !ok {
+		return "", false
+	}
+	return path.(string), true
+}
+
+// Open opens the file cached for key, counting it as a hit.  An open file
+// remains readable even if it is evicted before being closed.
+func (c *Cache) Open(key string) (*os.File, error) {
+	path, ok := c.Path(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		// evicted since the lookup
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Put writes the contents of r to the file cached for key, replacing any
+// existing file, and returns the number of bytes written.  Files larger than the
+// cache are not kept.
+func (c *Cache) Put(key string, r io.Reader) (int64, error) {
+	if len(key) > maxKeyLen {
+		return 0, ErrKeyTooLong
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, tempPrefix)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && float64(n) > c.capacity {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return n, err
+	}
+
+	path := filepath.Join(c.dir, base64.RawURLEncoding.EncodeToString([]byte(key)))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return n, err
+	}
+	c.cache.SetWithSize(key, path, float64(n))
+	return n, nil
+}
+
+// Contains checks if a file is cached for key without counting it as a hit.
+func (c *Cache) Contains(key string) bool {
+	return c.cache.Contains(key)
+}
+
+// Remove deletes the file cached for key, returning if there was one.
+func (c *Cache) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Remove(key)
+}
+
+// Purge deletes every cached file.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Purge()
+}
+
+// Len returns the number of cached files.
+func (c *Cache) Len() int {
+	return c.cache.Len()
+}
+
+// Size returns the total size of the cached files in bytes.
+func (c *Cache) Size() float64 {
+	return c.cache.Size()
+}
+
+// Dir returns the directory holding the cached files.
+func (c *Cache) Dir() string {
+	return c.dir
+}
@@ -0,0 +1,117 @@
+package diskcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func files(t *testing.T, dir string) int {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(infos)
+}
+
+func read(t *testing.T, c *Cache, key string) string {
+	f, err := c.Open(key)
+	if err != nil {
+		t.Fatalf("open %q: %v", key, err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCache(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	c, err := New(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.Put("a/b", strings.NewReader("aaaa")); err != nil || n != 4 {
+		t.Fatalf("bad put: %v, %v", n, err)
+	}
+	c.Put("c", strings.NewReader("cccc"))
+	if got := read(t, c, "a/b"); got != "aaaa" {
+		t.Errorf("bad contents: %q", got)
+	}
+	if c.Size() != 8 || files(t, dir) != 2 {
+		t.Errorf("bad size: %v, %d files", c.Size(), files(t, dir))
+	}
+
+	// c is the least frequently used so its file is deleted
+	c.Put("d", strings.NewReader("dddd"))
+	if c.Contains("c") || files(t, dir) != 2 {
+		t.Errorf("c should have been evicted: %d files", files(t, dir))
+	}
+	if _, err := c.Open("c"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound: %v", err)
+	}
+
+	if _, err := c.Put("big", strings.NewReader("0123456789x")); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge: %v", err)
+	}
+	if _, err := c.Put(strings.Repeat("k", 200), strings.NewReader("k")); err != ErrKeyTooLong {
+		t.Errorf("expected ErrKeyTooLong: %v", err)
+	}
+	if files(t, dir) != 2 {
+		t.Errorf("failed puts should not leave files behind")
+	}
+
+	// overwriting replaces the file and its size
+	c.Put("d", strings.NewReader("dd"))
+	if got := read(t, c, "d"); got != "dd" || c.Size() != 6 {
+		t.Errorf("bad overwrite: %q, %v", got, c.Size())
+	}
+
+	c.Remove("d")
+	if files(t, dir) != 1 {
+		t.Errorf("Remove should delete the file")
+	}
+	c.Purge()
+	if files(t, dir) != 0 || c.Len() != 0 {
+		t.Errorf("Purge should delete every file")
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	c, _ := New(dir, 10)
+	c.Put("a", strings.NewReader("aaaa"))
+	c.Put("b", strings.NewReader("bbbb"))
+	ioutil.WriteFile(filepath.Join(dir, tempPrefix+"1"), []byte("partial"), 0644)
+
+	c, err := NewGDSF(dir, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only one of the files fits the smaller capacity
+	if c.Len() != 1 || c.Size() != 4 || files(t, dir) != 1 {
+		t.Errorf("bad adoption: %d entries, %d files", c.Len(), files(t, dir))
+	}
+	key := "a"
+	if !c.Contains(key) {
+		key = "b"
+	}
+	if got := read(t, c, key); got != strings.Repeat(key, 4) {
+		t.Errorf("bad contents: %q", got)
+	}
+}
@@ -0,0 +1,30 @@
+package lfuda
+
+import "expvar"
+
+// Expvar returns an expvar.Var reporting the cache's length, size, age and
+// Stats as a JSON object whenever it is read.
+func (c *Cache) Expvar() expvar.Var {
+	return expvar.Func(func() interface{} {
+		stats := c.Stats()
+		return map[string]interface{}{
+			"len":           c.Len(),
+			"size":          c.Size(),
+			"age":           stats.Age,
+			"hits":          stats.Hits,
+			"misses":        stats.Misses,
+			"hit_ratio":     stats.HitRatio(),
+			"evictions":     stats.Evictions,
+			"evicted_bytes": stats.EvictedBytes,
+			"expirations":   stats.Expirations,
+			"rejected":      stats.Rejected,
+		}
+	})
+}
+
+// PublishExpvar publishes the cache's Expvar under name, so it is served at
+// /debug/vars with the other exported variables.  Like expvar.Publish it panics
+// if name is already in use.
+func (c *Cache) PublishExpvar(name string) {
+	expvar.Publish(name, c.Expvar())
+}
@@ -0,0 +1,24 @@
+package lfuda
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	c := New(2)
+	c.Set("a", "a")
+	c.Get("a")
+	c.Get("b")
+	c.Set("b", "bb")
+	c.PublishExpvar("lfuda_test_cache")
+
+	var vars map[string]float64
+	if err := json.Unmarshal([]byte(expvar.Get("lfuda_test_cache").String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars["len"] != 1 || vars["size"] != 2 || vars["hits"] != 1 || vars["evictions"] != 1 || vars["hit_ratio"] != 0.5 {
+		t.Errorf("bad vars: %v", vars)
+	}
+}
@@ -0,0 +1,132 @@
+// Package fragment caches rendered output, such as template fragments, in a
+// GDSF lfuda cache.
+//
+// Fragments are stored as []byte so the cache accounts for exactly the bytes
+// held, and are keyed by a hash of whatever content determines the output.
+// Fragments can optionally be stored gzip compressed, or encoded by any
+// lfuda.Codec, trading CPU on every hit for fitting more of them in the same
+// budget.
+package fragment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/bparli/lfuda-go"
+)
+
+// Cache caches rendered fragments.
+type Cache struct {
+	cache *lfuda.Cache
+	// encodes stored fragments, or nil to store them as is
+	codec lfuda.Codec
+}
+
+// New creates a Cache holding up to size bytes of fragments.
+func New(size float64) *Cache {
+	return &Cache{cache: lfuda.NewGDSF(size)}
+}
+
+// NewCompressed creates a Cache holding up to size bytes of gzip compressed
+// fragments.
+func NewCompressed(size float64) *Cache {
+	return NewWithCodec(size, lfuda.GzipCodec{})
+}
+
+// NewWithCodec creates a Cache holding up to size bytes of fragments encoded by
+// codec, which must marshal []byte values and unmarshal them back to []byte.
+func NewWithCodec(size float64, codec lfuda.Codec) *Cache {
+	return &Cache{
+		cache: lfuda.NewGDSF(size),
+		codec: codec,
+	}
+}
+
+// Key hashes the content a fragment is rendered from into a cache key.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	var n [8]byte
+	for _, part := range parts {
+		// length prefix each part so ("ab", "c") and ("a", "bc") differ
+		binary.BigEndian.PutUint64(n[:], uint64(len(part)))
+		h.Write(n[:])
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the fragment stored under key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	stored, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	out, err := c.decode(stored.([]byte))
+	if err != nil {
+		c.cache.Remove(key)
+		return nil, false
+	}
+	return out, true
+}
+
+// Set stores a rendered fragment under key.
+func (c *Cache) Set(key string, out []byte) error {
+	stored, err := c.encode(out)
+	if err != nil {
+		return err
+	}
+	c.cache.Set(key, stored)
+	return nil
+}
+
+// Render writes the fragment stored under key to w.  On a miss the fragment is
+// rendered with render, stored, and then written.
+func (c *Cache) Render(w io.Writer, key string, render func(w io.Writer) error) error {
+	if out, ok := c.Get(key); ok {
+		_, err := w.Write(out)
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		return err
+	}
+	if err := c.Set(key, buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Cache returns the underlying lfuda cache.
+func (c *Cache) Cache() *lfuda.Cache {
+	return c.cache
+}
+
+func (c *Cache) encode(out []byte) ([]byte, error) {
+	if c.codec == nil {
+		stored := make([]byte, len(out))
+		copy(stored, out)
+		return stored, nil
+	}
+	return c.codec.Marshal(out)
+}
+
+func (c *Cache) decode(stored []byte) ([]byte, error) {
+	if c.codec == nil {
+		return stored, nil
+	}
+	v, err := c.codec.Unmarshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	out, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("fragment: codec unmarshaled %T, not []byte", v)
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package fragment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+)
+
+func TestRender(t *testing.T) {
+	for _, c := range []*Cache{New(1 << 20), NewCompressed(1 << 20), NewWithCodec(1<<20, lfuda.GzipCodec{})} {
+		renders := 0
+		render := func(w io.Writer) error {
+			renders++
+			_, err := fmt.Fprintf(w, "<p>%s</p>", strings.Repeat("hello ", 100))
+			return err
+		}
+
+		key := Key([]byte("profile"), []byte("user-1"))
+		for i := 0; i < 3; i++ {
+			var buf bytes.Buffer
+			if err := c.Render(&buf, key, render); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(buf.String(), "<p>hello hello") {
+				t.Fatalf("bad fragment: %q", buf.String())
+			}
+		}
+		if renders != 1 {
+			t.Errorf("fragment should have been served from the cache: %d renders", renders)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	c := New(1 << 20)
+	fail := errors.New("boom")
+	if err := c.Render(&bytes.Buffer{}, "k", func(w io.Writer) error { return fail }); err != fail {
+		t.Errorf("render errors should be returned: %v", err)
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Errorf("failed renders should not be cached")
+	}
+}
+
+func TestCompressedSize(t *testing.T) {
+	out := []byte(strings.Repeat("abcdefgh", 1000))
+	plain, compressed := New(1<<20), NewCompressed(1<<20)
+	plain.Set("k", out)
+	compressed.Set("k", out)
+
+	if plain.Cache().Size() != float64(len(out)) {
+		t.Errorf("fragments should be sized by their bytes: %f", plain.Cache().Size())
+	}
+	if compressed.Cache().Size() >= plain.Cache().Size() {
+		t.Errorf("compressed fragment should be smaller: %f", compressed.Cache().Size())
+	}
+	if got, ok := compressed.Get("k"); !ok || !bytes.Equal(got, out) {
+		t.Errorf("compressed fragment should round trip")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key([]byte("ab"), []byte("c")) == Key([]byte("a"), []byte("bc")) {
+		t.Errorf("part boundaries should be part of the key")
+	}
+	if Key([]byte("a")) != Key([]byte("a")) {
+		t.Errorf("keys should be deterministic")
+	}
+}
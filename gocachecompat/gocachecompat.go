@@ -0,0 +1,220 @@
+// Package gocachecompat exposes an lfuda cache through the method set of
+// github.com/patrickmn/go-cache, so code written against that library can
+// switch to a size bounded LFUDA cache with minimal changes.
+//
+// The main difference is that New takes the cache size in bytes instead of a
+// cleanup interval: expired items are dropped lazily when they are looked up
+// or by DeleteExpired, and the LFUDA policy evicts items once the cache is full.
+package gocachecompat
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bparli/lfuda-go"
+)
+
+const (
+	// NoExpiration is for use with functions that take an expiration time.
+	NoExpiration time.Duration = -1
+	// DefaultExpiration is for use with functions that take an expiration
+	// time.  Equivalent to passing in the same expiration duration as was
+	// given to New.
+	DefaultExpiration time.Duration = 0
+)
+
+// Item is a cached object and its expiration time in Unix nanoseconds (or 0
+// if it never expires).
+type Item struct {
+	Object     interface{}
+	Expiration int64
+}
+
+// Expired returns true if the item has expired.
+func (item Item) Expired() bool {
+	if item.Expiration == 0 {
+		return false
+	}
+	return time.Now().UnixNano() > item.Expiration
+}
+
+// Cache is a go-cache compatible wrapper around an lfuda cache.
+type Cache struct {
+	cache             *lfuda.Cache
+	defaultExpiration time.Duration
+	// held by every write, so the check-then-set of Add and Replace is atomic
+	mu sync.Mutex
+}
+
+// New creates a Cache of the given size in bytes using the LFUDA policy.
+// Items set with DefaultExpiration expire after defaultExpiration; if it is
+// less than one, they never expire.
+func New(size float64, defaultExpiration time.Duration) *Cache {
+	return NewWithCache(lfuda.New(size), defaultExpiration)
+}
+
+// NewWithCache creates a Cache storing items in the given cache.
+func NewWithCache(cache *lfuda.Cache, defaultExpiration time.Duration) *Cache {
+	if defaultExpiration == 0 {
+		defaultExpiration = NoExpiration
+	}
+	return &Cache{
+		cache:             cache,
+		defaultExpiration: defaultExpiration,
+	}
+}
+
+// Set adds an item to the cache, replacing any existing item.  If the
+// duration is 0 (DefaultExpiration), the cache's default expiration time is
+// used.  If it is -1 (NoExpiration), the item never expires.
+func (c *Cache) Set(k string, x interface{}, d time.Duration) {
+	c.mu.Lock()
+	c.cache.Set(k, c.item(x, d))
+	c.mu.Unlock()
+}
+
+// SetDefault adds an item to the cache, replacing any existing item, using the
+// default expiration.
+func (c *Cache) SetDefault(k string, x interface{}) {
+	c.Set(k, x, DefaultExpiration)
+}
+
+// Add adds an item to the cache only if an item doesn't already exist for the
+// given key, or if the existing item has expired.  Returns an error otherwise.
+func (c *Cache) Add(k string, x interface{}, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.peek(k); found {
+		return fmt.Errorf("Item %s already exists", k)
+	}
+	c.cache.Set(k, c.item(x, d))
+	return nil
+}
+
+// Replace sets a new value for the cache key only if it already exists and
+// hasn't expired.  Returns an error otherwise.
+func (c *Cache) Replace(k string, x interface{}, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.peek(k); !found {
+		return fmt.Errorf("Item %s doesn't exist", k)
+	}
+	c.cache.Set(k, c.item(x, d))
+	return nil
+}
+
+// Get gets an item from the cache.  Returns the item or nil, and a bool
+// indicating whether the key was found.
+func (c *Cache) Get(k string) (interface{}, bool) {
+	item, found := c.get(k)
+	return item.Object, found
+}
+
+// GetWithExpiration returns an item and its expiration time from the cache.
+// The expiration time is the zero time.Time if the item never expires.
+func (c *Cache) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+	item, found := c.get(k)
+	if !found {
+		return nil, time.Time{}, false
+	}
+	if item.Expiration == 0 {
+		return item.Object, time.Time{}, true
+	}
+	return item.Object, time.Unix(0, item.Expiration), true
+}
+
+// Delete deletes an item from the cache.  Does nothing if the key is not in
+// the cache.
+func (c *Cache) Delete(k string) {
+	c.mu.Lock()
+	c.cache.Remove(k)
+	c.mu.Unlock()
+}
+
+// DeleteExpired deletes all expired items from the cache.
+func (c *Cache) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range c.cache.Keys() {
+		if v, ok := c.cache.Peek(k); ok && v.(Item).Expired() {
+			c.cache.Remove(k)
+		}
+	}
+}
+
+// Items copies all unexpired items in the cache into a new map and returns it.
+func (c *Cache) Items() map[string]Item {
+	keys := c.cache.Keys()
+	items := make(map[string]Item, len(keys))
+	for _, k := range keys {
+		if v, ok := c.cache.Peek(k); ok && !v.(Item).Expired() {
+			items[k.(string)] = v.(Item)
+		}
+	}
+	return items
+}
+
+// ItemCount returns the number of items in the cache.  This may include items
+// that have expired but have not yet been cleaned up.
+func (c *Cache) ItemCount() int {
+	return c.cache.Len()
+}
+
+// Flush deletes all items from the cache.
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	c.cache.Purge()
+	c.mu.Unlock()
+}
+
+// Cache returns the underlying lfuda cache.  Writes made directly to it aren't
+// ordered with Add and Replace.
+func (c *Cache) Cache() *lfuda.Cache {
+	return c.cache
+}
+
+func (c *Cache) item(x interface{}, d time.Duration) Item {
+	if d == DefaultExpiration {
+		d = c.defaultExpiration
+	}
+	var expiration int64
+	if d > 0 {
+		expiration = time.Now().Add(d).UnixNano()
+	}
+	return Item{Object: x, Expiration: expiration}
+}
+
+// get looks up an unexpired item, counting the lookup as a hit, and removes
+// the item if it has expired.
+func (c *Cache) get(k string) (Item, bool) {
+	v, ok := c.cache.Get(k)
+	if !ok {
+		return Item{}, false
+	}
+	item := v.(Item)
+	if item.Expired() {
+		c.mu.Lock()
+		// unless it was set again meanwhile
+		if _, found := c.peek(k); !found {
+			c.cache.Remove(k)
+		}
+		c.mu.Unlock()
+		return Item{}, false
+	}
+	return item, true
+}
+
+// peek looks up an unexpired item without counting a hit or removing it.  Must
+// be called with mu held for the result to stay current.
+func (c *Cache) peek(k string) (Item, bool) {
+	v, ok := c.cache.Peek(k)
+	if !ok {
+		return Item{}, false
+	}
+	item := v.(Item)
+	if item.Expired() {
+		return Item{}, false
+	}
+	return item, true
+}
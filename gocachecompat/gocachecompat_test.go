@@ -0,0 +1,104 @@
+package gocachecompat
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := New(1<<20, DefaultExpiration)
+
+	c.Set("a", 1, DefaultExpiration)
+	c.Set("b", "b", NoExpiration)
+	c.SetDefault("c", 3.5)
+
+	if x, found := c.Get("a"); !found || x != 1 {
+		t.Errorf("bad item a: %v, %v", x, found)
+	}
+	if _, exp, found := c.GetWithExpiration("b"); !found || !exp.IsZero() {
+		t.Errorf("item b should never expire: %v, %v", exp, found)
+	}
+	if c.ItemCount() != 3 || len(c.Items()) != 3 {
+		t.Errorf("bad item count: %d, %v", c.ItemCount(), c.Items())
+	}
+
+	c.Delete("a")
+	if _, found := c.Get("a"); found {
+		t.Errorf("deleted item should not be found")
+	}
+
+	c.Flush()
+	if c.ItemCount() != 0 {
+		t.Errorf("cache should be empty after Flush")
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	c := New(1<<20, time.Millisecond)
+	c.SetDefault("a", 1)
+	c.Set("b", 2, time.Hour)
+	c.Set("c", 3, time.Millisecond)
+
+	if _, exp, _ := c.GetWithExpiration("b"); time.Until(exp) < 59*time.Minute {
+		t.Errorf("bad expiration: %v", exp)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, found := c.Get("a"); found {
+		t.Errorf("item a should have expired")
+	}
+	if items := c.Items(); len(items) != 1 || items["b"].Object != 2 {
+		t.Errorf("only item b should remain: %v", items)
+	}
+
+	c.DeleteExpired()
+	if c.ItemCount() != 1 {
+		t.Errorf("expired items should be deleted: %d", c.ItemCount())
+	}
+}
+
+func TestAddReplace(t *testing.T) {
+	c := New(1<<20, NoExpiration)
+
+	if err := c.Replace("a", 1, DefaultExpiration); err == nil {
+		t.Errorf("replacing a missing item should fail")
+	}
+	if err := c.Add("a", 1, DefaultExpiration); err != nil {
+		t.Errorf("adding a new item should succeed: %v", err)
+	}
+	if err := c.Add("a", 2, DefaultExpiration); err == nil {
+		t.Errorf("adding an existing item should fail")
+	}
+	if err := c.Replace("a", 3, DefaultExpiration); err != nil {
+		t.Errorf("replacing an existing item should succeed: %v", err)
+	}
+	if x, _ := c.Get("a"); x != 3 {
+		t.Errorf("bad item: %v", x)
+	}
+}
+
+func TestAddRacingSet(t *testing.T) {
+	c := New(1<<20, NoExpiration)
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.Set(k, "set", DefaultExpiration)
+		}()
+		go func() {
+			defer wg.Done()
+			c.Add(k, "add", DefaultExpiration)
+		}()
+	}
+	wg.Wait()
+	// an Add either fails after the Set or is overwritten by it
+	for i := 0; i < 1000; i++ {
+		if x, _ := c.Get(strconv.Itoa(i)); x != "set" {
+			t.Fatalf("Add overwrote a Set of %d", i)
+		}
+	}
+}
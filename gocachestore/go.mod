@@ -0,0 +1,15 @@
+module github.com/bparli/lfuda-go/gocachestore
+
+go 1.22
+
+replace github.com/bparli/lfuda-go => ../
+
+require (
+	github.com/bparli/lfuda-go v0.0.0-00010101000000-000000000000
+	github.com/eko/gocache/lib/v4 v4.2.0
+)
+
+require (
+	go.uber.org/mock v0.4.0 // indirect
+	golang.org/x/exp v0.0.0-20240416160154-fe59bbe5cc7f // indirect
+)
@@ -0,0 +1,174 @@
+// Package gocachestore provides a github.com/eko/gocache store backed by an
+// lfuda cache, so lfuda can take part in gocache's chained, loadable and
+// marshaled caches.
+package gocachestore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/eko/gocache/lib/v4/store"
+)
+
+// LfudaType is the store type returned by GetType.
+const LfudaType = "lfuda"
+
+var (
+	errNotFound = errors.New("value not found in lfuda store")
+	errTooLarge = errors.New("value too large for lfuda store")
+)
+
+// LfudaStore is a gocache store backed by an lfuda cache.
+type LfudaStore struct {
+	cache   *lfuda.Cache
+	options *store.Options
+
+	mu     sync.Mutex
+	tagged map[string]map[interface{}]struct{}
+	tags   map[interface{}][]string
+}
+
+type entry struct {
+	value   interface{}
+	expires int64
+}
+
+var _ store.StoreInterface = (*LfudaStore)(nil)
+
+// NewLfuda creates a store holding up to size bytes under the LFUDA policy.
+// The options are used as defaults for every Set.
+func NewLfuda(size float64, options ...store.Option) *LfudaStore {
+	s := &LfudaStore{
+		options: store.ApplyOptions(options...),
+		tagged:  make(map[string]map[interface{}]struct{}),
+		tags:    make(map[interface{}][]string),
+	}
+	s.cache = lfuda.NewWithEvict(size, s.evicted)
+	return s
+}
+
+// Get returns the value stored under key.
+func (s *LfudaStore) Get(ctx context.Context, key any) (any, error) {
+	value, _, err := s.GetWithTTL(ctx, key)
+	return value, err
+}
+
+// GetWithTTL returns the value stored under key and its remaining TTL, which
+// is 0 if the value never expires.
+func (s *LfudaStore) GetWithTTL(_ context.Context, key any) (any, time.Duration, error) {
+	stored, ok := s.cache.Get(key)
+	if !ok {
+		return nil, 0, store.NotFoundWithCause(errNotFound)
+	}
+	e := stored.(entry)
+	if e.expires == 0 {
+		return e.value, 0, nil
+	}
+	ttl := time.Duration(e.expires - time.Now().UnixNano())
+	if ttl <= 0 {
+		s.cache.Remove(key)
+		return nil, 0, store.NotFoundWithCause(errNotFound)
+	}
+	return e.value, ttl, nil
+}
+
+// Set stores value under key.  The expiration and tags options are honored;
+// cost is ignored since lfuda sizes values itself.  Setting a key again replaces
+// its tags along with its value.  Returns an error if the value is larger than
+// the store.
+func (s *LfudaStore) Set(_ context.Context, key any, value any, options ...store.Option) error {
+	opts := store.ApplyOptionsWithDefault(s.options, options...)
+
+	e := entry{value: value}
+	if opts.Expiration > 0 {
+		e.expires = time.Now().Add(opts.Expiration).UnixNano()
+	}
+
+	if set, _ := s.cache.SetWithVictims(key, e); !set {
+		// any previous value was left in place along with its tags
+		return errTooLarge
+	}
+
+	// index the stored value under its own tags, dropping those of any value
+	// it replaced
+	s.mu.Lock()
+	s.untag(key)
+	if len(opts.Tags) > 0 {
+		for _, tag := range opts.Tags {
+			if s.tagged[tag] == nil {
+				s.tagged[tag] = make(map[interface{}]struct{})
+			}
+			s.tagged[tag][key] = struct{}{}
+		}
+		s.tags[key] = opts.Tags
+	}
+	s.mu.Unlock()
+
+	// the value may have been evicted before it was indexed
+	if len(opts.Tags) > 0 && !s.cache.Contains(key) {
+		s.evicted(key, e)
+	}
+	return nil
+}
+
+// Delete removes the value stored under key.
+func (s *LfudaStore) Delete(_ context.Context, key any) error {
+	s.cache.Remove(key)
+	return nil
+}
+
+// Invalidate removes every value carrying any of the given tags.
+func (s *LfudaStore) Invalidate(_ context.Context, options ...store.InvalidateOption) error {
+	opts := store.ApplyInvalidateOptions(options...)
+
+	var keys []interface{}
+	s.mu.Lock()
+	for _, tag := range opts.Tags {
+		for key := range s.tagged[tag] {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.Unlock()
+
+	// removing calls back into evicted, which takes s.mu
+	for _, key := range keys {
+		s.cache.Remove(key)
+	}
+	return nil
+}
+
+// Clear removes every value from the store.
+func (s *LfudaStore) Clear(_ context.Context) error {
+	s.cache.Purge()
+	return nil
+}
+
+// GetType returns the store type.
+func (s *LfudaStore) GetType() string {
+	return LfudaType
+}
+
+// Cache returns the underlying lfuda cache.
+func (s *LfudaStore) Cache() *lfuda.Cache {
+	return s.cache
+}
+
+func (s *LfudaStore) evicted(key, value interface{}) {
+	s.mu.Lock()
+	s.untag(key)
+	s.mu.Unlock()
+}
+
+// untag removes key from the index of its tags.  Must be called with mu held.
+func (s *LfudaStore) untag(key interface{}) {
+	for _, tag := range s.tags[key] {
+		delete(s.tagged[tag], key)
+		if len(s.tagged[tag]) == 0 {
+			delete(s.tagged, tag)
+		}
+	}
+	delete(s.tags, key)
+}
@@ -0,0 +1,97 @@
+package gocachestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/store"
+)
+
+func TestLfudaStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewLfuda(1 << 20)
+
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, store.NotFound{}) {
+		t.Errorf("missing keys should return NotFound: %v", err)
+	}
+
+	s.Set(ctx, "a", "value")
+	if v, ttl, err := s.GetWithTTL(ctx, "a"); err != nil || v != "value" || ttl != 0 {
+		t.Errorf("bad value: %v, %v, %v", v, ttl, err)
+	}
+
+	s.Delete(ctx, "a")
+	if _, err := s.Get(ctx, "a"); err == nil {
+		t.Errorf("deleted value should not be found")
+	}
+
+	s.Set(ctx, "a", 1)
+	s.Set(ctx, "b", 2)
+	s.Clear(ctx)
+	if s.Cache().Len() != 0 {
+		t.Errorf("store should be empty after Clear")
+	}
+	if s.GetType() != LfudaType {
+		t.Errorf("bad type: %s", s.GetType())
+	}
+}
+
+func TestLfudaStoreExpiration(t *testing.T) {
+	ctx := context.Background()
+	s := NewLfuda(1<<20, store.WithExpiration(time.Millisecond))
+
+	s.Set(ctx, "a", 1)
+	s.Set(ctx, "b", 2, store.WithExpiration(time.Hour))
+	if _, ttl, _ := s.GetWithTTL(ctx, "b"); ttl < 59*time.Minute {
+		t.Errorf("per-Set expiration should override the default: %v", ttl)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.Get(ctx, "a"); err == nil {
+		t.Errorf("value should have expired")
+	}
+	if _, err := s.Get(ctx, "b"); err != nil {
+		t.Errorf("value should not have expired: %v", err)
+	}
+}
+
+func TestLfudaStoreInvalidate(t *testing.T) {
+	ctx := context.Background()
+	s := NewLfuda(1 << 20)
+
+	s.Set(ctx, "a", 1, store.WithTags([]string{"users"}))
+	s.Set(ctx, "b", 2, store.WithTags([]string{"users", "orders"}))
+	s.Set(ctx, "c", 3, store.WithTags([]string{"orders"}))
+
+	s.Invalidate(ctx, store.WithInvalidateTags([]string{"users"}))
+	if _, err := s.Get(ctx, "a"); err == nil {
+		t.Errorf("a should have been invalidated")
+	}
+	if _, err := s.Get(ctx, "c"); err != nil {
+		t.Errorf("c should not have been invalidated: %v", err)
+	}
+	if len(s.tags) != 1 || len(s.tagged) != 1 {
+		t.Errorf("tag index should only track c: %v %v", s.tags, s.tagged)
+	}
+}
+
+func TestLfudaStoreRetag(t *testing.T) {
+	ctx := context.Background()
+	s := NewLfuda(100)
+
+	s.Set(ctx, "a", 1, store.WithTags([]string{"old"}))
+	s.Set(ctx, "a", 2, store.WithTags([]string{"new"}))
+	s.Invalidate(ctx, store.WithInvalidateTags([]string{"old"}))
+	if v, err := s.Get(ctx, "a"); err != nil || v != 2 {
+		t.Errorf("invalidating a replaced value's tag shouldn't remove the new one: %v, %v", v, err)
+	}
+
+	if err := s.Set(ctx, "big", make([]byte, 200), store.WithTags([]string{"big"})); err == nil {
+		t.Errorf("values larger than the store should be refused")
+	}
+	if _, ok := s.tagged["big"]; ok || len(s.tags) != 1 {
+		t.Errorf("refused values shouldn't be indexed: %v", s.tags)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/bparli/lfuda-go/grpccache
+
+go 1.21
+
+replace github.com/bparli/lfuda-go => ../
+
+require (
+	github.com/bparli/lfuda-go v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.34.1
+)
+
+require (
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
@@ -0,0 +1,114 @@
+// Package grpccache provides a gRPC unary client interceptor which caches the
+// responses of idempotent methods in an lfuda cache.
+//
+// Responses are keyed by the full method name plus the deterministically
+// marshaled request, and stored marshaled so that the cache's size accounting
+// reflects their wire size.  Only methods given a TTL are cached, and a cached
+// response is served until its method's TTL has passed.
+package grpccache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/bparli/lfuda-go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Interceptor caches unary RPC responses.
+type Interceptor struct {
+	cache *lfuda.Cache
+	ttls  map[string]time.Duration
+	now   func() time.Time
+}
+
+// New creates an Interceptor caching up to size bytes of marshaled responses
+// under the GDSF policy.  ttls maps full method names (e.g.
+// "/package.Service/Method") to how long their responses may be served from
+// the cache; methods not in ttls are never cached.
+func New(size float64, ttls map[string]time.Duration) *Interceptor {
+	return NewWithCache(lfuda.NewGDSF(size), ttls)
+}
+
+// NewWithCache creates an Interceptor storing responses in the given cache.
+func NewWithCache(cache *lfuda.Cache, ttls map[string]time.Duration) *Interceptor {
+	methods := make(map[string]time.Duration, len(ttls))
+	for method, ttl := range ttls {
+		if ttl > 0 {
+			methods[method] = ttl
+		}
+	}
+	return &Interceptor{
+		cache: cache,
+		ttls:  methods,
+		now:   time.Now,
+	}
+}
+
+// Cache returns the underlying lfuda cache.
+func (i *Interceptor) Cache() *lfuda.Cache {
+	return i.cache
+}
+
+// Unary returns the interceptor, to be installed with grpc.WithUnaryInterceptor.
+func (i *Interceptor) Unary() grpc.UnaryClientInterceptor {
+	return i.intercept
+}
+
+func (i *Interceptor) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ttl, ok := i.ttls[method]
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	reqMsg, ok := req.(proto.Message)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	replyMsg, ok := reply.(proto.Message)
+	if !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	reqBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(reqMsg)
+	if err != nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	key := method + "\x00" + string(reqBytes)
+
+	if entry, ok := i.cache.Get(key); ok {
+		if i.decode(entry.([]byte), replyMsg) {
+			return nil
+		}
+		i.cache.Remove(key)
+	}
+
+	if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+		return err
+	}
+
+	replyBytes, err := proto.Marshal(replyMsg)
+	if err != nil {
+		// the call itself succeeded, we just can't cache its response
+		return nil
+	}
+	entry := make([]byte, 8+len(replyBytes))
+	binary.BigEndian.PutUint64(entry, uint64(i.now().Add(ttl).UnixNano()))
+	copy(entry[8:], replyBytes)
+	i.cache.Set(key, entry)
+	return nil
+}
+
+// decode unmarshals a fresh cached entry into reply, returning false if the
+// entry has expired or can't be unmarshaled.
+func (i *Interceptor) decode(entry []byte, reply proto.Message) bool {
+	if len(entry) < 8 {
+		return false
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(entry)))
+	if !i.now().Before(expires) {
+		return false
+	}
+	return proto.Unmarshal(entry[8:], reply) == nil
+}
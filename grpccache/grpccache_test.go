@@ -0,0 +1,88 @@
+package grpccache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const method = "/test.Echo/Echo"
+
+type echo struct {
+	calls int
+	err   error
+}
+
+func (e *echo) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	e.calls++
+	if e.err != nil {
+		return e.err
+	}
+	proto.Merge(reply.(proto.Message), wrapperspb.String("echo "+req.(*wrapperspb.StringValue).GetValue()))
+	return nil
+}
+
+func call(t *testing.T, i *Interceptor, e *echo, method, value string) (string, error) {
+	reply := new(wrapperspb.StringValue)
+	err := i.Unary()(context.Background(), method, wrapperspb.String(value), reply, nil, e.invoke)
+	return reply.GetValue(), err
+}
+
+func TestInterceptorCaches(t *testing.T) {
+	i := New(1<<20, map[string]time.Duration{method: time.Minute})
+	e := &echo{}
+
+	for n := 0; n < 3; n++ {
+		if got, err := call(t, i, e, method, "a"); err != nil || got != "echo a" {
+			t.Fatalf("bad reply: %q, %v", got, err)
+		}
+	}
+	if e.calls != 1 {
+		t.Errorf("reply should have been served from the cache: %d calls", e.calls)
+	}
+
+	call(t, i, e, method, "b")
+	if e.calls != 2 {
+		t.Errorf("different requests should not share entries: %d calls", e.calls)
+	}
+}
+
+func TestInterceptorTTL(t *testing.T) {
+	now := time.Now()
+	i := New(1<<20, map[string]time.Duration{method: time.Minute})
+	i.now = func() time.Time { return now }
+	e := &echo{}
+
+	call(t, i, e, method, "a")
+	now = now.Add(2 * time.Minute)
+	call(t, i, e, method, "a")
+	if e.calls != 2 {
+		t.Errorf("expired reply should not be served: %d calls", e.calls)
+	}
+}
+
+func TestInterceptorUncached(t *testing.T) {
+	i := New(1<<20, map[string]time.Duration{method: time.Minute})
+	e := &echo{}
+
+	call(t, i, e, "/test.Echo/Other", "a")
+	call(t, i, e, "/test.Echo/Other", "a")
+	if e.calls != 2 {
+		t.Errorf("methods without a TTL should not be cached: %d calls", e.calls)
+	}
+
+	e.err = errors.New("unavailable")
+	if _, err := call(t, i, e, method, "b"); err == nil {
+		t.Errorf("errors should be returned")
+	}
+	e.err = nil
+	call(t, i, e, method, "b")
+	if e.calls != 4 {
+		t.Errorf("failed calls should not be cached: %d calls", e.calls)
+	}
+}
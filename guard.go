@@ -0,0 +1,96 @@
+package lfuda
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// LockHeldError is passed to the error hook of a cache created WithLockGuard
+// when its lock is held longer than the guard's threshold, typically because a
+// callback called back into the cache or sizing a value is running away.
+type LockHeldError struct {
+	Threshold time.Duration
+	// Stacks holds the stacks of all goroutines when the threshold passed,
+	// truncated to 1MB, to find the holder
+	Stacks []byte
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("lfuda: cache lock held longer than %v", e.Threshold)
+}
+
+// WithLockGuard reports locks held longer than threshold, by writers or by
+// Ascend, to the error hook as *LockHeldError errors, rather than the process
+// silently freezing.  Has no effect without WithErrorHook.
+func WithLockGuard(threshold time.Duration) Option {
+	return func(c *config) {
+		c.lockThreshold = threshold
+	}
+}
+
+// lockGuard times lock holds.  A nil guard does nothing.
+type lockGuard struct {
+	threshold time.Duration
+	onError   func(error)
+	// timer for the write lock, only reset or stopped by its holder
+	timer *time.Timer
+}
+
+func newLockGuard(threshold time.Duration, onError func(error)) *lockGuard {
+	g := &lockGuard{threshold: threshold, onError: onError}
+	g.timer = time.AfterFunc(threshold, g.report)
+	g.timer.Stop()
+	return g
+}
+
+func (g *lockGuard) report() {
+	stacks := make([]byte, 1<<20)
+	stacks = stacks[:runtime.Stack(stacks, true)]
+	g.onError(&LockHeldError{Threshold: g.threshold, Stacks: stacks})
+}
+
+// start times a write lock hold.  Must be called with the write lock held.
+func (g *lockGuard) start() {
+	if g != nil {
+		g.timer.Reset(g.threshold)
+	}
+}
+
+// stop ends timing a write lock hold.  Must be called with the write lock held.
+func (g *lockGuard) stop() {
+	if g != nil {
+		g.timer.Stop()
+	}
+}
+
+// startRead times a read lock hold, returning a timer to be stopped when it's
+// released.
+func (g *lockGuard) startRead() *time.Timer {
+	if g == nil {
+		return nil
+	}
+	return time.AfterFunc(g.threshold, g.report)
+}
+
+// writeLock takes the write lock for the named operation on key, which may be
+// nil.
+func (c *Cache) writeLock(op string, key interface{}) {
+	c.lock.Lock()
+	c.guard.start()
+	c.slow.start(op, key)
+}
+
+func (c *Cache) writeUnlock() {
+	c.guard.stop()
+	slow := c.slow.stop(c.lfuda)
+	evictions := c.pendingEvictions
+	c.pendingEvictions = nil
+	c.lock.Unlock()
+	if slow != nil {
+		c.slow.hook(*slow)
+	}
+	for _, e := range evictions {
+		c.evictions <- e
+	}
+}
@@ -0,0 +1,42 @@
+package lfuda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockGuard(t *testing.T) {
+	errs := make(chan error, 10)
+	c := NewWithEvict(1, func(key, value interface{}) {
+		time.Sleep(50 * time.Millisecond)
+	}, WithErrorHook(func(err error) { errs <- err }), WithLockGuard(10*time.Millisecond))
+
+	c.Set("a", "x")
+	c.Get("a")
+	select {
+	case err := <-errs:
+		t.Fatalf("short holds should not be reported: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// the slow callback holds the lock
+	c.Set("b", "x")
+	select {
+	case err := <-errs:
+		if e, ok := err.(*LockHeldError); !ok || e.Threshold != 10*time.Millisecond || len(e.Stacks) == 0 {
+			t.Errorf("bad error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("long hold should have been reported")
+	}
+
+	c.Ascend(func(key, value interface{}) bool {
+		time.Sleep(50 * time.Millisecond)
+		return true
+	})
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Errorf("long Ascend should have been reported")
+	}
+}
@@ -0,0 +1,58 @@
+package lfuda
+
+import (
+	"time"
+
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// Hooks observe a cache's operations as they happen, with how long each took
+// including any wait for the lock, so they can be turned into counters and
+// latency histograms.  The lfudaotel module implements them with
+// OpenTelemetry.  OnHit, OnMiss and OnSet are called after the lock is
+// released; OnEvict is called with it held, so must not use the cache.
+type Hooks interface {
+	// OnHit is called for each Get which found its key
+	OnHit(key interface{}, d time.Duration)
+	// OnMiss is called for each Get which didn't
+	OnMiss(key interface{}, d time.Duration)
+	// OnSet is called for each Set or variant of Set
+	OnSet(key interface{}, d time.Duration)
+	// OnEvict is called for each entry evicted to make room, or by Evict
+	OnEvict(v simplelfuda.Victim)
+}
+
+// WithHooks reports the cache's operations to h.
+func WithHooks(h Hooks) Option {
+	return func(c *config) {
+		c.hooks = h
+	}
+}
+
+// hookStart returns the time an operation began, or the zero time without
+// hooks so they cost nothing when not configured.
+func (c *Cache) hookStart() time.Time {
+	if c.hooks == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// hookGet reports a Get which began at start.
+func (c *Cache) hookGet(key interface{}, ok bool, start time.Time) {
+	if c.hooks == nil {
+		return
+	}
+	if ok {
+		c.hooks.OnHit(key, time.Since(start))
+	} else {
+		c.hooks.OnMiss(key, time.Since(start))
+	}
+}
+
+// hookSet reports a Set which began at start.
+func (c *Cache) hookSet(key interface{}, start time.Time) {
+	if c.hooks != nil {
+		c.hooks.OnSet(key, time.Since(start))
+	}
+}
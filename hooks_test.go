@@ -0,0 +1,40 @@
+package lfuda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+type recordingHooks struct {
+	hits, misses, sets []interface{}
+	evicted            []interface{}
+}
+
+func (h *recordingHooks) OnHit(key interface{}, d time.Duration)  { h.hits = append(h.hits, key) }
+func (h *recordingHooks) OnMiss(key interface{}, d time.Duration) { h.misses = append(h.misses, key) }
+func (h *recordingHooks) OnSet(key interface{}, d time.Duration)  { h.sets = append(h.sets, key) }
+func (h *recordingHooks) OnEvict(v simplelfuda.Victim)            { h.evicted = append(h.evicted, v.Key) }
+
+func TestHooks(t *testing.T) {
+	h := &recordingHooks{}
+	c := New(2, WithHooks(h))
+	c.Set("a", "x")
+	c.Get("a")
+	c.Get("b")
+	c.SetWithSize("b", "y", 2)
+
+	if len(h.hits) != 1 || h.hits[0] != "a" {
+		t.Errorf("bad hits: %v", h.hits)
+	}
+	if len(h.misses) != 1 || h.misses[0] != "b" {
+		t.Errorf("bad misses: %v", h.misses)
+	}
+	if len(h.sets) != 2 || h.sets[1] != "b" {
+		t.Errorf("bad sets: %v", h.sets)
+	}
+	if len(h.evicted) != 1 || h.evicted[0] != "a" {
+		t.Errorf("bad evictions: %v", h.evicted)
+	}
+}
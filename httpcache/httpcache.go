@@ -0,0 +1,247 @@
+// Package httpcache provides an http.RoundTripper which caches complete HTTP
+// responses (status, headers and body) in a GDSF lfuda cache.
+//
+// It is intended for reverse proxies: set it as the Transport of an
+// httputil.ReverseProxy and upstream responses are served from memory while fresh.
+// Responses are keyed by URL plus the values of the request headers named by the
+// response's Vary header, and sized by their serialized bytes so that GDSF favors
+// small, popular responses.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bparli/lfuda-go"
+)
+
+var errCorruptEntry = errors.New("httpcache: corrupt cache entry")
+
+// Transport is a caching http.RoundTripper.
+type Transport struct {
+	// Transport is used to fetch responses which aren't cached.  If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	cache *lfuda.Cache
+}
+
+// NewTransport creates a Transport caching up to size bytes of responses under
+// the GDSF policy, fetching misses with rt.
+func NewTransport(size float64, rt http.RoundTripper) *Transport {
+	return NewTransportWithCache(lfuda.NewGDSF(size), rt)
+}
+
+// NewTransportWithCache creates a Transport storing responses in the given cache.
+func NewTransportWithCache(cache *lfuda.Cache, rt http.RoundTripper) *Transport {
+	return &Transport{
+		Transport: rt,
+		cache:     cache,
+	}
+}
+
+// Cache returns the underlying lfuda cache.
+func (t *Transport) Cache() *lfuda.Cache {
+	return t.cache
+}
+
+// RoundTrip serves req from the cache when a fresh response is stored for it,
+// otherwise it fetches the response and stores it if it is cacheable.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheableRequest(req) {
+		return t.transport().RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	if resp, ok := t.lookup(url, req); ok {
+		return resp, nil
+	}
+
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	expires, ok := freshUntil(resp, time.Now())
+	if !ok {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// entries are sized explicitly as their length is already known
+	vary := varyHeaders(resp.Header)
+	if len(vary) > 0 {
+		names := []byte(strings.Join(vary, ","))
+		t.cache.SetWithSize(varyKey(url), names, float64(len(names)))
+	} else {
+		// the URL may have varied before, and its old names would key lookups
+		t.cache.Remove(varyKey(url))
+	}
+	if entry, err := encode(resp, body, expires); err == nil {
+		t.cache.SetWithSize(responseKey(url, vary, req.Header), entry, float64(len(entry)))
+	}
+	return resp, nil
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) lookup(url string, req *http.Request) (*http.Response, bool) {
+	var vary []string
+	if names, ok := t.cache.Peek(varyKey(url)); ok {
+		vary = strings.Split(string(names.([]byte)), ",")
+	}
+
+	key := responseKey(url, vary, req.Header)
+	entry, ok := t.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	resp, expires, err := decode(entry.([]byte), req)
+	if err != nil || !time.Now().Before(expires) {
+		t.cache.Remove(key)
+		return nil, false
+	}
+	return resp, true
+}
+
+func cacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, noStore := cacheControl(req.Header)["no-store"]
+	return !noStore
+}
+
+// freshUntil returns when a response stops being fresh, or false if it must
+// not be cached.  Only responses with explicit freshness information are cached.
+func freshUntil(resp *http.Response, now time.Time) (time.Time, bool) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return time.Time{}, false
+	}
+
+	cc := cacheControl(resp.Header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[directive]; ok {
+			return time.Time{}, false
+		}
+	}
+	for _, vary := range varyHeaders(resp.Header) {
+		if vary == "*" {
+			return time.Time{}, false
+		}
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if age, ok := cc[directive]; ok {
+			secs, err := strconv.Atoi(age)
+			if err != nil || secs <= 0 {
+				return time.Time{}, false
+			}
+			return now.Add(time.Duration(secs) * time.Second), true
+		}
+	}
+
+	if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			// honor the origin's notion of how long the response is fresh for
+			expires = now.Add(expires.Sub(date))
+		}
+		return expires, expires.After(now)
+	}
+	return time.Time{}, false
+}
+
+func cacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, value := range h["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				cc[strings.ToLower(directive[:i])] = strings.Trim(directive[i+1:], `"`)
+			} else {
+				cc[strings.ToLower(directive)] = ""
+			}
+		}
+	}
+	return cc
+}
+
+// varyHeaders returns the canonical, sorted header names a response varies on.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, value := range h["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func varyKey(url string) string {
+	return "vary " + url
+}
+
+func responseKey(url string, vary []string, h http.Header) string {
+	var key strings.Builder
+	key.WriteString("resp ")
+	key.WriteString(url)
+	for _, name := range vary {
+		key.WriteByte('\n')
+		key.WriteString(name)
+		key.WriteByte(':')
+		key.WriteString(strings.Join(h[name], ","))
+	}
+	return key.String()
+}
+
+// encode serializes a response as its expiry followed by its HTTP/1.1 wire format.
+func encode(resp *http.Response, body []byte, expires time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, expires.UnixNano())
+
+	stored := *resp
+	stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	if err := stored.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(entry []byte, req *http.Request) (*http.Response, time.Time, error) {
+	if len(entry) < 8 {
+		return nil, time.Time{}, errCorruptEntry
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(entry[:8])))
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry[8:])), req)
+	return resp, expires, err
+}
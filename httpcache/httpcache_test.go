@@ -0,0 +1,145 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func get(t *testing.T, client *http.Client, url string, header http.Header) (int, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestTransportCaches(t *testing.T) {
+	srv, requests := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("X-Custom", "yes")
+		w.Write([]byte("hello " + r.URL.Path))
+	})
+	client := &http.Client{Transport: NewTransport(1<<20, nil)}
+
+	for i := 0; i < 3; i++ {
+		if status, body := get(t, client, srv.URL+"/a", nil); status != http.StatusOK || body != "hello /a" {
+			t.Fatalf("bad response: %d %q", status, body)
+		}
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("response should have been served from the cache: %d upstream requests", n)
+	}
+
+	get(t, client, srv.URL+"/b", nil)
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("different URLs should not share entries: %d upstream requests", n)
+	}
+}
+
+func TestTransportVary(t *testing.T) {
+	srv, requests := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	})
+	client := &http.Client{Transport: NewTransport(1<<20, nil)}
+
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	for i := 0; i < 2; i++ {
+		if _, body := get(t, client, srv.URL, en); body != "en" {
+			t.Errorf("bad body for en: %q", body)
+		}
+		if _, body := get(t, client, srv.URL, fr); body != "fr" {
+			t.Errorf("bad body for fr: %q", body)
+		}
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("expected one upstream request per language: %d", n)
+	}
+}
+
+func TestTransportVaryDropped(t *testing.T) {
+	var vary int32 = 1
+	srv, requests := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if atomic.LoadInt32(&vary) == 1 {
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		w.Write([]byte("x"))
+	})
+	client := &http.Client{Transport: NewTransport(1<<20, nil)}
+
+	get(t, client, srv.URL, http.Header{"Accept-Language": {"en"}})
+	atomic.StoreInt32(&vary, 0)
+	get(t, client, srv.URL, http.Header{"Accept-Language": {"fr"}})
+	get(t, client, srv.URL, http.Header{"Accept-Language": {"de"}})
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Errorf("responses should stop varying once Vary is dropped: %d upstream requests", n)
+	}
+}
+
+func TestTransportUncacheable(t *testing.T) {
+	srv, requests := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/error":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("x"))
+	})
+	client := &http.Client{Transport: NewTransport(1<<20, nil)}
+
+	for _, path := range []string{"/private", "/error", "/no-freshness"} {
+		get(t, client, srv.URL+path, nil)
+		get(t, client, srv.URL+path, nil)
+	}
+	if n := atomic.LoadInt32(requests); n != 6 {
+		t.Errorf("uncacheable responses should always go upstream: %d", n)
+	}
+}
+
+func TestTransportExpires(t *testing.T) {
+	now := time.Now()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	resp.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	resp.Header.Set("Expires", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+
+	expires, ok := freshUntil(resp, now)
+	if !ok || expires.Sub(now) < 59*time.Minute {
+		t.Errorf("bad expiry from Expires header: %v, %v", expires, ok)
+	}
+
+	resp.Header.Set("Cache-Control", "max-age=10, s-maxage=20")
+	if expires, ok := freshUntil(resp, now); !ok || expires.Sub(now) != 20*time.Second {
+		t.Errorf("s-maxage should take precedence: %v, %v", expires, ok)
+	}
+}
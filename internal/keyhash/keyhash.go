@@ -0,0 +1,78 @@
+// Package keyhash hashes cache keys to stable 64 bit values, the same across
+// processes and platforms, for summaries of keys such as diagnostics and
+// filters shared between peers.
+package keyhash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// Sum returns the FNV-1a hash of key.  Strings and byte slices hash their
+// contents, integers and floats their value, and other keys their %v
+// formatting, each prefixed by a tag for its kind so 1 and "1" differ.
+func Sum(key interface{}) uint64 {
+	h := fnv.New64a()
+	var buf [9]byte
+	switch k := key.(type) {
+	case string:
+		h.Write([]byte{'s'})
+		h.Write([]byte(k))
+	case []byte:
+		h.Write([]byte{'s'})
+		h.Write(k)
+	case int:
+		buf[0] = 'i'
+		binary.BigEndian.PutUint64(buf[1:], uint64(k))
+		h.Write(buf[:])
+	case int8, int16, int32, int64:
+		buf[0] = 'i'
+		binary.BigEndian.PutUint64(buf[1:], uint64(toInt64(k)))
+		h.Write(buf[:])
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		buf[0] = 'u'
+		binary.BigEndian.PutUint64(buf[1:], toUint64(k))
+		h.Write(buf[:])
+	case float32:
+		buf[0] = 'f'
+		binary.BigEndian.PutUint64(buf[1:], math.Float64bits(float64(k)))
+		h.Write(buf[:])
+	case float64:
+		buf[0] = 'f'
+		binary.BigEndian.PutUint64(buf[1:], math.Float64bits(k))
+		h.Write(buf[:])
+	default:
+		fmt.Fprintf(h, "v%T:%v", key, key)
+	}
+	return h.Sum64()
+}
+
+func toInt64(key interface{}) int64 {
+	switch k := key.(type) {
+	case int8:
+		return int64(k)
+	case int16:
+		return int64(k)
+	case int32:
+		return int64(k)
+	}
+	return key.(int64)
+}
+
+func toUint64(key interface{}) uint64 {
+	switch k := key.(type) {
+	case uint:
+		return uint64(k)
+	case uint8:
+		return uint64(k)
+	case uint16:
+		return uint64(k)
+	case uint32:
+		return uint64(k)
+	case uintptr:
+		return uint64(k)
+	}
+	return key.(uint64)
+}
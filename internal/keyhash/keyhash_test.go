@@ -0,0 +1,24 @@
+package keyhash
+
+import "testing"
+
+type point struct{ x, y int }
+
+func TestSum(t *testing.T) {
+	// stable across processes and releases
+	if got := Sum("a"); got != 0x08d92f07b57922d9 {
+		t.Errorf("hash of a string changed: %#x", got)
+	}
+	if Sum("abc") != Sum([]byte("abc")) {
+		t.Errorf("strings and byte slices should hash alike")
+	}
+	if Sum(1) != Sum(int64(1)) || Sum(uint8(1)) != Sum(uint64(1)) || Sum(float32(1.5)) != Sum(1.5) {
+		t.Errorf("numbers should hash by value")
+	}
+	if Sum(1) == Sum("1") || Sum(1) == Sum(uint(1)) || Sum(-1) == Sum(1) {
+		t.Errorf("different keys should hash differently")
+	}
+	if Sum(point{1, 2}) != Sum(point{1, 2}) || Sum(point{1, 2}) == Sum(point{2, 1}) {
+		t.Errorf("other keys should hash by their formatting")
+	}
+}
@@ -0,0 +1,61 @@
+// Package singleflight shares one call of a function between concurrent
+// callers asking for the same key, so a missing cache entry is only loaded once
+// however many goroutines miss on it.
+package singleflight
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPanicked is returned to the callers waiting on a call which panicked.
+var ErrPanicked = errors.New("lfuda: load panicked")
+
+// call is an in-flight call shared by concurrent callers.
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Group shares calls by key.  The zero Group is ready to use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[interface{}]*call
+}
+
+// Do calls fn and returns its results, unless a call for key is already in
+// flight, in which case it waits for that call and returns its results.  If fn
+// panics the panic propagates to Do's caller, the callers waiting on it get
+// ErrPanicked, and the next Do for key calls fn again.
+func (g *Group) Do(key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if cl, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		cl.wg.Wait()
+		return cl.value, cl.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[interface{}]*call)
+	}
+	cl := &call{err: ErrPanicked}
+	cl.wg.Add(1)
+	g.calls[key] = cl
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		cl.wg.Done()
+	}()
+	cl.value, cl.err = fn()
+	return cl.value, cl.err
+}
+
+// InFlight returns the number of calls in flight.
+func (g *Group) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.calls)
+}
@@ -0,0 +1,76 @@
+package singleflight
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do("a", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "value", nil
+			})
+			if v != "value" || err != nil {
+				t.Errorf("bad result: %v, %v", v, err)
+			}
+		}()
+	}
+	for g.InFlight() == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	if calls < 1 || g.InFlight() != 0 {
+		t.Errorf("calls should finish: %d", calls)
+	}
+
+	errFailed := errors.New("failed")
+	if _, err := g.Do("a", func() (interface{}, error) { return nil, errFailed }); err != errFailed {
+		t.Errorf("errors should be returned: %v", err)
+	}
+}
+
+func TestDoPanic(t *testing.T) {
+	var g Group
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer func() { recover() }()
+		g.Do("a", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("a")
+		})
+	}()
+	<-started
+	done := make(chan error)
+	go func() {
+		_, err := g.Do("a", func() (interface{}, error) { return nil, nil })
+		done <- err
+	}()
+	// give the waiter a chance to join the panicking call
+	for i := 0; i < 100; i++ {
+		runtime.Gosched()
+	}
+	close(release)
+	if err := <-done; err != ErrPanicked && err != nil {
+		t.Errorf("waiters should get ErrPanicked: %v", err)
+	}
+	if g.InFlight() != 0 {
+		t.Errorf("panicking calls should be cleaned up")
+	}
+	if v, err := g.Do("a", func() (interface{}, error) { return "b", nil }); v != "b" || err != nil {
+		t.Errorf("the key should be callable again: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package keyfilter provides a bloom filter of cache keys which can be shipped
+// to peers, so they can cheaply ask "might this node have the key?" before
+// forwarding a request to it.
+//
+// Keys are hashed with a hash which is stable across processes and platforms,
+// so a filter built on one node can be queried on any other.
+package keyfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/bparli/lfuda-go/internal/keyhash"
+)
+
+// ErrInvalid is returned when unmarshaling malformed filter data.
+var ErrInvalid = errors.New("keyfilter: invalid filter data")
+
+// Filter is a bloom filter of keys.  It has no false negatives: MayContain is
+// always true for added keys.
+type Filter struct {
+	bits []uint64
+	m    uint64
+	k    uint32
+}
+
+// New creates a Filter sized for n keys with the given false positive rate.
+func New(n int, falsePositiveRate float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &Filter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add adds a key to the filter.
+func (f *Filter) Add(key interface{}) {
+	h1, h2 := hashes(key)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain reports whether the key may have been added.  False means the key
+// was definitely not added.
+func (f *Filter) MayContain(key interface{}) bool {
+	h1, h2 := hashes(key)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalBinary encodes the filter for sending to peers.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 12+8*len(f.bits))
+	binary.BigEndian.PutUint32(data, f.k)
+	binary.BigEndian.PutUint64(data[4:], f.m)
+	for i, word := range f.bits {
+		binary.BigEndian.PutUint64(data[12+8*i:], word)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return ErrInvalid
+	}
+	k := binary.BigEndian.Uint32(data)
+	m := binary.BigEndian.Uint64(data[4:])
+	words := (m + 63) / 64
+	if k == 0 || m == 0 || uint64(len(data)-12) != 8*words {
+		return ErrInvalid
+	}
+	bits := make([]uint64, words)
+	for i := range bits {
+		bits[i] = binary.BigEndian.Uint64(data[12+8*i:])
+	}
+	f.bits, f.m, f.k = bits, m, k
+	return nil
+}
+
+// hashes derives the two hashes for double hashing from a key's 64 bit hash.
+func hashes(key interface{}) (uint64, uint64) {
+	h := keyhash.Sum(key)
+	// an odd second hash never cycles back to the first bit early
+	return h >> 32, h&0xffffffff | 1
+}
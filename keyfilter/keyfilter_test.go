@@ -0,0 +1,52 @@
+package keyfilter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(i)
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.MayContain(i) {
+			t.Fatalf("added key %d should be found", i)
+		}
+	}
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if f.MayContain(i) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Errorf("false positive rate too high: %d in 10000", falsePositives)
+	}
+	if f.MayContain("0") && f.MayContain("1") && f.MayContain("2") {
+		t.Errorf("string keys should not collide with ints")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	f := New(100, 0.01)
+	for i := 0; i < 100; i++ {
+		f.Add(fmt.Sprint("key", i))
+	}
+	data, _ := f.MarshalBinary()
+
+	var g Filter
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if !g.MayContain(fmt.Sprint("key", i)) {
+			t.Fatalf("decoded filter is missing key%d", i)
+		}
+	}
+
+	if err := g.UnmarshalBinary(data[:len(data)-1]); err != ErrInvalid {
+		t.Errorf("truncated data should be rejected: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package lfuda
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bparli/lfuda-go/accesslog"
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// LazyHits wraps a Cache so that Get records hits in sharded counters instead of
+// promoting the entry under the write lock on every access.  Pending hits are
+// folded into the priority structure in batches, so a burst of Gets on one hot
+// key costs a single promotion rather than one per access.
+//
+// Gets are logged to the access log and reported to metrics and hooks as they
+// happen, but only counted in Stats once folded.  Until a batch is folded,
+// priorities, and therefore eviction choices, lag behind the most recent Gets.
+// Set, Keys and Stats fold pending hits first.  Folding hits doesn't refresh
+// the Keys snapshot, as hits through Cache.Get don't.
+type LazyHits struct {
+	*Cache
+	shards []hitShard
+	next   uint32
+	// Gets since the last fold, and those which missed
+	pending int64
+	misses  int64
+	batch   int64
+}
+
+type hitShard struct {
+	sync.Mutex
+	hits map[interface{}]float64
+	// keep shards on separate cache lines
+	_ [40]byte
+}
+
+// NewLazyHits wraps c, folding recorded hits into the cache once batchSize hits
+// are pending.
+func NewLazyHits(c *Cache, batchSize int) *LazyHits {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	l := &LazyHits{
+		Cache:  c,
+		shards: make([]hitShard, runtime.GOMAXPROCS(0)),
+		batch:  int64(batchSize),
+	}
+	for i := range l.shards {
+		l.shards[i].hits = make(map[interface{}]float64)
+	}
+	return l
+}
+
+// Get looks up a key's value from the cache, recording the hit to be applied
+// with the next batch.
+func (l *LazyHits) Get(key interface{}) (value interface{}, ok bool) {
+	c := l.Cache
+	start := c.hookStart()
+	c.lock.RLock()
+	value, ok = c.lfuda.Peek(key)
+	if ok {
+		c.logAccess(key, accesslog.Hit)
+	} else {
+		c.logAccess(key, accesslog.Miss)
+	}
+	c.lock.RUnlock()
+	c.metrics.get(ok)
+	c.hookGet(key, ok, start)
+
+	if ok {
+		shard := &l.shards[atomic.AddUint32(&l.next, 1)%uint32(len(l.shards))]
+		shard.Lock()
+		shard.hits[key]++
+		shard.Unlock()
+	} else {
+		atomic.AddInt64(&l.misses, 1)
+	}
+
+	if atomic.AddInt64(&l.pending, 1) >= l.batch {
+		l.FlushHits()
+	}
+	if !ok {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set folds pending hits and then adds a value to the cache.  Returns true if
+// an eviction occurred.
+func (l *LazyHits) Set(key, value interface{}) bool {
+	l.FlushHits()
+	return l.Cache.Set(key, value)
+}
+
+// Keys folds pending hits and then returns a slice of the keys in the cache,
+// served from the snapshot as Cache.Keys does.
+func (l *LazyHits) Keys() []interface{} {
+	l.FlushHits()
+	return l.Cache.Keys()
+}
+
+// Stats folds pending hits and then returns the cache's counts, as Cache.Stats
+// does.
+func (l *LazyHits) Stats() simplelfuda.Stats {
+	l.FlushHits()
+	return l.Cache.Stats()
+}
+
+// FlushHits folds all pending hits into the cache, counting them and any misses
+// in Stats.
+func (l *LazyHits) FlushHits() {
+	if atomic.LoadInt64(&l.pending) == 0 {
+		return
+	}
+
+	l.Cache.writeLock("LazyHits.FlushHits", nil)
+	atomic.StoreInt64(&l.pending, 0)
+	var hits float64
+	for i := range l.shards {
+		shard := &l.shards[i]
+		shard.Lock()
+		pending := shard.hits
+		shard.hits = make(map[interface{}]float64, len(pending))
+		shard.Unlock()
+
+		for key, n := range pending {
+			l.Cache.lfuda.AddHits(key, n)
+			hits += n
+		}
+	}
+	l.Cache.lfuda.CountGets(uint64(hits), uint64(atomic.SwapInt64(&l.misses, 0)))
+	l.Cache.publishHits()
+	l.Cache.writeUnlock()
+}
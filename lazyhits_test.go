@@ -0,0 +1,77 @@
+package lfuda
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLazyHits(t *testing.T) {
+	l := NewLazyHits(New(2), 100)
+	l.Set(1, 1)
+	l.Set(2, 2)
+
+	for i := 0; i < 10; i++ {
+		if v, ok := l.Get(1); !ok || v != 1 {
+			t.Fatalf("bad value: %v, %v", v, ok)
+		}
+	}
+
+	// hits stay pending until a batch is folded
+	if l.pending != 10 {
+		t.Errorf("hits should still be pending: %d", l.pending)
+	}
+
+	if l.Keys()[0] != 1 {
+		t.Errorf("key 1 should be the most frequently used once hits are folded")
+	}
+
+	// 2 is now the least frequently used
+	l.Set(3, 3)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Errorf("key 2 should have been evicted")
+	}
+}
+
+func TestLazyHitsBatch(t *testing.T) {
+	l := NewLazyHits(New(100), 8)
+	l.Set(1, 1)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.Get(1)
+				l.Get(2)
+			}
+		}()
+	}
+	wg.Wait()
+	l.FlushHits()
+
+	if l.pending != 0 {
+		t.Errorf("no hits should be pending after FlushHits: %d", l.pending)
+	}
+}
+
+func TestLazyHitsAccounting(t *testing.T) {
+	hooks := &recordingHooks{}
+	l := NewLazyHits(New(100, WithHooks(hooks)), 100)
+	l.Set(1, 1)
+	l.Get(1)
+	l.Get(1)
+	l.Get(2)
+	if len(hooks.hits) != 2 || len(hooks.misses) != 1 {
+		t.Errorf("Gets should be reported to hooks as they happen: %+v", hooks)
+	}
+
+	generation := l.generation
+	l.FlushHits()
+	if l.generation != generation {
+		t.Errorf("folding hits shouldn't invalidate Locals or the Keys snapshot")
+	}
+	if stats := l.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("folded Gets should be counted: %+v", stats)
+	}
+}
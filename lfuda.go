@@ -1,91 +1,422 @@
 package lfuda
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/bparli/lfuda-go/accesslog"
+	"github.com/bparli/lfuda-go/internal/keyhash"
+	"github.com/bparli/lfuda-go/internal/singleflight"
+	"github.com/bparli/lfuda-go/keyfilter"
 	"github.com/bparli/lfuda-go/simplelfuda"
 )
 
 // Cache is a thread-safe fixed size lfuda cache.
 type Cache struct {
-	lfuda simplelfuda.LFUDACache
+	lfuda *simplelfuda.LFUDA
 	lock  sync.RWMutex
+
+	// summary published by writers so Keys, Len and Size never take the write lock
+	// bumped by every change which may change keys or values, invalidating
+	// Locals and the Keys snapshot
+	generation uint64
+	length     int64
+	size       uint64
+	keys       atomic.Value
+	// held while rebuilding the Keys snapshot
+	keysLock sync.Mutex
+
+	// nil unless configured WithMetrics
+	metrics *instruments
+	// nil unless configured WithLockGuard
+	guard *lockGuard
+	// nil unless configured WithLoader
+	prefetch *prefetcher
+	// nil unless configured WithAccessLog
+	accessLog *accesslog.Writer
+	// nil unless configured WithSlowOpHook
+	slow *slowTracer
+	// called with the key of each Get miss, if managed
+	onMiss func(key interface{})
+	// hits restored by RestoreMetadata for keys not yet set
+	restored map[interface{}]float64
+	// nil unless configured WithHooks
+	hooks Hooks
+
+	// nil unless configured WithEvictionChannel, with the evictions waiting
+	// for the lock to be released to be sent
+	evictions        chan<- Eviction
+	pendingEvictions []Eviction
+	// how the cache was created, so Split can create more like it
+	policy    Policy
+	onEvicted func(key interface{}, value interface{})
+	opts      []Option
+	// loads in flight for GetOrLoad
+	loads singleflight.Group
 }
 
+type keysSnapshot struct {
+	generation uint64
+	keys       []interface{}
+}
+
+// number of keys copied per hold of the read lock when rebuilding the Keys
+// snapshot
+const keysChunk = 1024
+
+// rebuilds of the Keys snapshot cut short by writers before the read lock is
+// held for a whole one
+const keysAttempts = 3
+
 // New creates an lfuda of the given size.
-func New(size float64) *Cache {
-	return newWithEvict(size, "LFUDA", nil)
+func New(size float64, opts ...Option) *Cache {
+	return newWithEvict(size, PolicyLFUDA, nil, opts)
 }
 
 // NewGDSF creates an lfuda of the given size and the GDSF cache policy.
-func NewGDSF(size float64) *Cache {
-	return newWithEvict(size, "GDSF", nil)
+func NewGDSF(size float64, opts ...Option) *Cache {
+	return newWithEvict(size, PolicyGDSF, nil, opts)
+}
+
+// NewLFU creates an lfuda of the given size and the plain LFU cache policy:
+// entries are evicted by hit count alone, with no dynamic aging.
+func NewLFU(size float64, opts ...Option) *Cache {
+	return newWithEvict(size, PolicyLFU, nil, opts)
 }
 
-// NewLFU creates an lfuda of the given size.
-func NewLFU(size float64) *Cache {
-	return newWithEvict(size, "LFU", nil)
+// NewLRU creates an lfuda of the given size and the LRU cache policy, so a cache
+// can start out with plain LRU semantics and switch policy later by changing
+// only its constructor.
+func NewLRU(size float64, opts ...Option) *Cache {
+	return newWithEvict(size, PolicyLRU, nil, opts)
+}
+
+// NewMRU creates an lfuda of the given size and the MRU cache policy, which
+// evicts the most recently used entry and suits large cyclic scans.
+func NewMRU(size float64, opts ...Option) *Cache {
+	return newWithEvict(size, PolicyMRU, nil, opts)
 }
 
 // NewWithEvict constructs a fixed size LFUDA cache with the given eviction
 // callback.
-func NewWithEvict(size float64, onEvicted func(key interface{}, value interface{})) *Cache {
-	return newWithEvict(size, "LFUDA", onEvicted)
+func NewWithEvict(size float64, onEvicted func(key interface{}, value interface{}), opts ...Option) *Cache {
+	return newWithEvict(size, PolicyLFUDA, onEvicted, opts)
 }
 
 // NewGDSFWithEvict constructs a fixed GDSF size cache with the given eviction
 // callback.
-func NewGDSFWithEvict(size float64, onEvicted func(key interface{}, value interface{})) *Cache {
-	return newWithEvict(size, "GDSF", onEvicted)
+func NewGDSFWithEvict(size float64, onEvicted func(key interface{}, value interface{}), opts ...Option) *Cache {
+	return newWithEvict(size, PolicyGDSF, onEvicted, opts)
 }
 
 // NewLFUWithEvict constructs a fixed size LFU cache with the given eviction
 // callback.
-func NewLFUWithEvict(size float64, onEvicted func(key interface{}, value interface{})) *Cache {
-	return newWithEvict(size, "LFU", onEvicted)
+func NewLFUWithEvict(size float64, onEvicted func(key interface{}, value interface{}), opts ...Option) *Cache {
+	return newWithEvict(size, PolicyLFU, onEvicted, opts)
+}
+
+// NewLRUWithEvict constructs a fixed size LRU cache with the given eviction
+// callback.
+func NewLRUWithEvict(size float64, onEvicted func(key interface{}, value interface{}), opts ...Option) *Cache {
+	return newWithEvict(size, PolicyLRU, onEvicted, opts)
+}
+
+// NewMRUWithEvict constructs a fixed size MRU cache with the given eviction
+// callback.
+func NewMRUWithEvict(size float64, onEvicted func(key interface{}, value interface{}), opts ...Option) *Cache {
+	return newWithEvict(size, PolicyMRU, onEvicted, opts)
+}
+
+// NewWithOptions creates an lfuda configured entirely by options: its policy
+// with WithPolicy, LFUDA by default, its eviction callback with
+// WithEvictCallback and its capacity with WithSizeBytes, WithMaxEntries or
+// both, at least one of which must be given.  Panics if neither is, or the
+// policy isn't one of the Policy constants, as such a cache would be unusable.
+func NewWithOptions(opts ...Option) *Cache {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	policy := cfg.policy
+	switch policy {
+	case "":
+		policy = PolicyLFUDA
+	case PolicyLFUDA, PolicyGDSF, PolicyLFU, PolicyLRU, PolicyMRU:
+	default:
+		panic(fmt.Sprintf("lfuda: unknown policy %q", policy))
+	}
+	size := cfg.size
+	if size <= 0 && cfg.maxEntries <= 0 {
+		panic("lfuda: NewWithOptions needs a positive WithSizeBytes or WithMaxEntries")
+	}
+	if size <= 0 {
+		size = float64(cfg.maxEntries)
+		// copied rather than appended in place, which could overwrite the
+		// spare capacity of the caller's slice
+		opts = append(opts[:len(opts):len(opts)], WithEntryCounting())
+	}
+	return newWithEvict(size, policy, cfg.onEvicted, opts)
 }
 
-func newWithEvict(size float64, policy string, onEvicted func(key interface{}, value interface{})) *Cache {
-	if policy == "GDSF" {
-		gdsf := simplelfuda.NewGDSF(size, simplelfuda.EvictCallback(onEvicted))
-		return &Cache{
-			lfuda: gdsf,
+func newWithEvict(size float64, policy Policy, onEvicted func(key interface{}, value interface{}), opts []Option) *Cache {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c := &Cache{accessLog: cfg.accessLog, onMiss: cfg.onMiss, hooks: cfg.hooks}
+	c.policy, c.onEvicted, c.opts = policy, onEvicted, append([]Option(nil), opts...)
+	if cfg.lockThreshold > 0 && cfg.onError != nil {
+		c.guard = newLockGuard(cfg.lockThreshold, cfg.onError)
+	}
+	if cfg.slowHook != nil {
+		c.slow = &slowTracer{threshold: cfg.slowThreshold, hook: cfg.slowHook}
+	}
+	if cfg.loader != nil {
+		c.prefetch = &prefetcher{
+			load:     cfg.loader,
+			onError:  cfg.onError,
+			inFlight: make(map[interface{}]struct{}),
+		}
+	}
+	observe := cfg.onVictim
+	if cfg.hooks != nil {
+		if observe == nil {
+			observe = cfg.hooks.OnEvict
+		} else {
+			onVictim := observe
+			observe = func(v simplelfuda.Victim) {
+				onVictim(v)
+				cfg.hooks.OnEvict(v)
+			}
 		}
-	} else if policy == "LFU" {
-		lfu := simplelfuda.NewLFU(size, simplelfuda.EvictCallback(onEvicted))
-		return &Cache{
-			lfuda: lfu,
+	}
+	if cfg.instruments != nil {
+		c.metrics = cfg.instruments
+	} else if cfg.metrics != nil {
+		c.metrics = newInstruments(cfg.metrics)
+	}
+	if c.metrics != nil {
+		if observe == nil {
+			observe = c.metrics.evicted
+		} else {
+			onVictim := observe
+			observe = func(v simplelfuda.Victim) {
+				onVictim(v)
+				c.metrics.evicted(v)
+			}
+		}
+	}
+	if observe != nil {
+		cfg.lfuda = append(cfg.lfuda, simplelfuda.WithVictimObserver(observe))
+	}
+	if cfg.maxEntries > 0 {
+		cfg.lfuda = append(cfg.lfuda, simplelfuda.WithMaxEntries(cfg.maxEntries))
+	}
+	if cfg.evictions != nil {
+		c.evictions = cfg.evictions
+		callback := onEvicted
+		onEvicted = func(key, value interface{}) {
+			if callback != nil {
+				callback(key, value)
+			}
+			c.pendingEvictions = append(c.pendingEvictions, Eviction{Key: key, Value: value})
 		}
 	}
-	lfuda := simplelfuda.NewLFUDA(size, simplelfuda.EvictCallback(onEvicted))
-	return &Cache{
-		lfuda: lfuda,
+
+	if policy == PolicyGDSF {
+		c.lfuda = simplelfuda.NewGDSF(size, simplelfuda.EvictCallback(onEvicted), cfg.lfuda...)
+	} else if policy == PolicyLFU {
+		c.lfuda = simplelfuda.NewLFU(size, simplelfuda.EvictCallback(onEvicted), cfg.lfuda...)
+	} else if policy == PolicyLRU {
+		c.lfuda = simplelfuda.NewLRU(size, simplelfuda.EvictCallback(onEvicted), cfg.lfuda...)
+	} else if policy == PolicyMRU {
+		c.lfuda = simplelfuda.NewMRU(size, simplelfuda.EvictCallback(onEvicted), cfg.lfuda...)
+	} else {
+		c.lfuda = simplelfuda.NewLFUDA(size, simplelfuda.EvictCallback(onEvicted), cfg.lfuda...)
 	}
+	c.publish()
+	return c
+}
+
+// Resize changes the size of the cache, evicting the lowest priority entries
+// until they fit if it shrinks, so a control plane can tune it without purging
+// and rebuilding it.  Returns the entries evicted, lowest priority first.  The
+// size of a cache owned by a Manager is the Manager's to change.
+func (c *Cache) Resize(size float64) (victims []simplelfuda.Victim) {
+	c.writeLock("Resize", nil)
+	victims = c.lfuda.Resize(size)
+	c.publish()
+	c.writeUnlock()
+	return victims
 }
 
 // Purge is used to completely clear the cache.
 func (c *Cache) Purge() {
-	c.lock.Lock()
+	c.writeLock("Purge", nil)
 	c.lfuda.Purge()
-	c.lock.Unlock()
+	c.restored = nil
+	c.publish()
+	c.writeUnlock()
 }
 
-// Set adds a value to the cache. Returns true if an eviction occurred.
+// Set adds a value to the cache. Returns true if an eviction occurred.  A nil
+// value is stored like any other, sized as 0 bytes like an empty value.
 func (c *Cache) Set(key, value interface{}) (ok bool) {
-	c.lock.Lock()
-	ok = c.lfuda.Set(key, value)
-	c.lock.Unlock()
+	start := c.hookStart()
+	c.writeLock("Set", key)
+	ok = c.set(key, value)
+	c.publish()
+	c.writeUnlock()
+	c.hookSet(key, start)
 	return ok
 }
 
-// Get looks up a key's value from the cache.
+// set sets a value and records it as Set does, for Set and Buffered.  Must be
+// called with the write lock held.
+func (c *Cache) set(key, value interface{}) bool {
+	ok := c.lfuda.Set(key, value)
+	c.adoptRestored(key)
+	c.logAccess(key, accesslog.Set)
+	return ok
+}
+
+// SetWithBoost adds a value to the cache with its hits weighted by boost when
+// calculating its priority, so business-critical keys outlast equally popular
+// ordinary keys without being pinned.  Returns true if an eviction occurred.
+func (c *Cache) SetWithBoost(key, value interface{}, boost float64) (ok bool) {
+	start := c.hookStart()
+	c.writeLock("SetWithBoost", key)
+	ok = c.lfuda.SetWithBoost(key, value, boost)
+	c.adoptRestored(key)
+	c.logAccess(key, accesslog.Set)
+	c.publish()
+	c.writeUnlock()
+	c.hookSet(key, start)
+	return ok
+}
+
+// SetWithClass adds a value to the cache in the given priority class, such as
+// critical, normal and best-effort.  Entries in lower classes are always evicted
+// before those in higher ones, with LFUDA ordering within each class.  Entries
+// are in class 0 unless set otherwise.  Returns true if an eviction occurred.
+func (c *Cache) SetWithClass(key, value interface{}, class int) (ok bool) {
+	start := c.hookStart()
+	c.writeLock("SetWithClass", key)
+	ok = c.lfuda.SetWithClass(key, value, class)
+	c.adoptRestored(key)
+	c.logAccess(key, accesslog.Set)
+	c.publish()
+	c.writeUnlock()
+	c.hookSet(key, start)
+	return ok
+}
+
+// SetWithSize adds a value to the cache with an explicit size in bytes rather
+// than one derived from the value.  Returns true if an eviction occurred.
+func (c *Cache) SetWithSize(key, value interface{}, size float64) (ok bool) {
+	start := c.hookStart()
+	c.writeLock("SetWithSize", key)
+	ok = c.lfuda.SetWithSize(key, value, size)
+	c.adoptRestored(key)
+	c.logAccess(key, accesslog.Set)
+	c.publish()
+	c.writeUnlock()
+	c.hookSet(key, start)
+	return ok
+}
+
+// SetWithTTL adds a value to the cache which expires after ttl, on top of being
+// evicted as usual.  Get treats expired entries as missing and removes them;
+// RemoveExpired or RunJanitor reclaim the space of those never fetched again.
+// Returns true if an eviction occurred.
+func (c *Cache) SetWithTTL(key, value interface{}, ttl time.Duration) (ok bool) {
+	start := c.hookStart()
+	c.writeLock("SetWithTTL", key)
+	ok = c.lfuda.SetWithTTL(key, value, ttl)
+	c.adoptRestored(key)
+	c.logAccess(key, accesslog.Set)
+	c.publish()
+	c.writeUnlock()
+	c.hookSet(key, start)
+	return ok
+}
+
+// SetWithVictims adds a value to the cache, returning whether it was set and the
+// entries evicted to make room for it, lowest priority first.
+func (c *Cache) SetWithVictims(key, value interface{}) (set bool, victims []simplelfuda.Victim) {
+	start := c.hookStart()
+	c.writeLock("SetWithVictims", key)
+	set, victims = c.lfuda.SetWithVictims(key, value)
+	c.adoptRestored(key)
+	c.logAccess(key, accesslog.Set)
+	c.publish()
+	c.writeUnlock()
+	c.hookSet(key, start)
+	return set, victims
+}
+
+// Get looks up a key's value from the cache.  nil is a valid value, so a key set
+// to nil is found with a nil value; check ok, not the value, for misses.
 func (c *Cache) Get(key interface{}) (value interface{}, ok bool) {
-	c.lock.Lock()
-	value, ok = c.lfuda.Get(key)
-	c.lock.Unlock()
+	value, _, ok = c.get("Get", key, false)
 	return value, ok
 }
 
+// get implements Get, also looking up the key's expiry if withExpiry, with op
+// naming the operation for the lock guard and slow op hook.
+func (c *Cache) get(op string, key interface{}, withExpiry bool) (value interface{}, expires time.Time, ok bool) {
+	start := c.hookStart()
+	c.writeLock(op, key)
+	if withExpiry {
+		value, expires, ok = c.lfuda.GetWithExpiry(key)
+	} else {
+		value, ok = c.lfuda.Get(key)
+	}
+	c.metrics.get(ok)
+	if ok {
+		c.logAccess(key, accesslog.Hit)
+	} else {
+		c.logAccess(key, accesslog.Miss)
+		if c.onMiss != nil {
+			c.onMiss(key)
+		}
+	}
+	_, load := c.restored[key]
+	if !ok && int64(c.lfuda.Len()) != atomic.LoadInt64(&c.length) {
+		// the key had expired and was removed
+		c.publish()
+	} else {
+		c.publishHits()
+	}
+	c.writeUnlock()
+	c.hookGet(key, ok, start)
+	if load && !ok {
+		c.Prefetch([]interface{}{key})
+	}
+	return value, expires, ok
+}
+
+// GetWithExpiry looks up a key's value like Get, also returning when it
+// expires, or the zero time if it doesn't.
+func (c *Cache) GetWithExpiry(key interface{}) (value interface{}, expires time.Time, ok bool) {
+	return c.get("GetWithExpiry", key, true)
+}
+
+// Touch makes a key expire ttl from now, or never if ttl isn't positive,
+// without counting a hit, for sliding expiration.  Returns false if the key
+// isn't in the cache or has already expired.
+func (c *Cache) Touch(key interface{}, ttl time.Duration) (ok bool) {
+	c.writeLock("Touch", key)
+	ok = c.lfuda.Touch(key, ttl)
+	c.writeUnlock()
+	return ok
+}
+
 // Contains checks if a key is in the cache, without updating the
 // recent-ness or deleting it for being stale.
 func (c *Cache) Contains(key interface{}) bool {
@@ -95,6 +426,16 @@ func (c *Cache) Contains(key interface{}) bool {
 	return containKey
 }
 
+// ContainsMulti checks whether each of keys is in the cache under one lock
+// acquisition, without updating their recent-ness.  The result is in the same
+// order as keys.
+func (c *Cache) ContainsMulti(keys []interface{}) (present []bool) {
+	c.lock.RLock()
+	present = c.lfuda.ContainsMulti(keys)
+	c.lock.RUnlock()
+	return present
+}
+
 // Peek returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
@@ -104,17 +445,28 @@ func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
 	return value, ok
 }
 
+// PeekMulti looks up many keys in one locked pass without updating their
+// recent-ness, returning the values of those present.
+func (c *Cache) PeekMulti(keys []interface{}) (values map[interface{}]interface{}) {
+	c.lock.RLock()
+	values = c.lfuda.PeekMulti(keys)
+	c.lock.RUnlock()
+	return values
+}
+
 // ContainsOrSet checks if a key is in the cache without updating the
 // recent-ness or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether the key/value was set or not.
 func (c *Cache) ContainsOrSet(key, value interface{}) (ok, set bool) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.writeLock("ContainsOrSet", key)
+	defer c.writeUnlock()
 
 	if c.lfuda.Contains(key) {
 		return true, false
 	}
 	set = c.lfuda.Set(key, value)
+	c.adoptRestored(key)
+	c.publish()
 	return false, set
 }
 
@@ -122,8 +474,8 @@ func (c *Cache) ContainsOrSet(key, value interface{}) (ok, set bool) {
 // hits or deleting it for being stale, and if not, adds the value.
 // Returns whether found and whether the key/value was set or not.
 func (c *Cache) PeekOrSet(key, value interface{}) (previous interface{}, ok, set bool) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.writeLock("PeekOrSet", key)
+	defer c.writeUnlock()
 
 	previous, ok = c.lfuda.Peek(key)
 	if ok {
@@ -131,45 +483,391 @@ func (c *Cache) PeekOrSet(key, value interface{}) (previous interface{}, ok, set
 	}
 
 	set = c.lfuda.Set(key, value)
+	c.adoptRestored(key)
+	c.publish()
 	return nil, false, set
 }
 
 // Remove removes the provided key from the cache.
 func (c *Cache) Remove(key interface{}) (present bool) {
-	c.lock.Lock()
+	c.writeLock("Remove", key)
 	present = c.lfuda.Remove(key)
-	c.lock.Unlock()
+	c.publish()
+	c.writeUnlock()
 	return
 }
 
-// Keys returns a slice of the keys in the cache, from oldest to newest.
-func (c *Cache) Keys() []interface{} {
+// RemoveExpired removes every expired entry, calling the eviction callback for
+// each, and returns how many were removed.  It takes time proportional to the
+// size of the cache.
+func (c *Cache) RemoveExpired() (n int) {
+	c.writeLock("RemoveExpired", nil)
+	n = c.lfuda.RemoveExpired()
+	if n > 0 {
+		c.publish()
+	}
+	c.writeUnlock()
+	return n
+}
+
+// RunJanitor calls RemoveExpired every interval until ctx is done.
+func (c *Cache) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.RemoveExpired()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Evict evicts the n lowest priority entries on demand, returning them lowest
+// priority first, for example to free memory ahead of a known traffic spike.
+func (c *Cache) Evict(n int) []simplelfuda.Victim {
+	c.writeLock("Evict", nil)
+	victims := c.lfuda.Evict(n)
+	c.publish()
+	c.writeUnlock()
+	return victims
+}
+
+// PurgePercent evicts the lowest priority p percent of the entries, rounded up,
+// returning them lowest priority first, to quickly shed memory under pressure
+// without losing the hot core of the cache.
+func (c *Cache) PurgePercent(p float64) []simplelfuda.Victim {
+	c.writeLock("PurgePercent", nil)
+	victims := c.lfuda.PurgePercent(p)
+	c.publish()
+	c.writeUnlock()
+	return victims
+}
+
+// PurgePercentBytes evicts the lowest priority entries until at least p percent
+// of the bytes in use have been freed, returning them lowest priority first.
+func (c *Cache) PurgePercentBytes(p float64) []simplelfuda.Victim {
+	c.writeLock("PurgePercentBytes", nil)
+	victims := c.lfuda.PurgePercentBytes(p)
+	c.publish()
+	c.writeUnlock()
+	return victims
+}
+
+// WouldSet reports whether setting key to value would be admitted, how many
+// bytes would have to be evicted and which entries would go, without changing
+// the cache, for admission-aware upstream logic.
+func (c *Cache) WouldSet(key, value interface{}) (plan simplelfuda.SetPlan) {
+	c.lock.RLock()
+	plan = c.lfuda.WouldSet(key, value)
+	c.lock.RUnlock()
+	return plan
+}
+
+// Explain reports why setting key to a value of the given size would or
+// wouldn't keep it cached: whether it's too large, which entries it would
+// evict, and whether its priority against the cache age would make it the next
+// entry evicted.
+func (c *Cache) Explain(key interface{}, size float64) (x simplelfuda.Explanation) {
+	c.lock.RLock()
+	x = c.lfuda.Explain(key, size)
+	c.lock.RUnlock()
+	return x
+}
+
+// NextVictims returns the n entries which would be evicted next, lowest
+// priority first, without evicting them, so applications can persist or
+// refresh their values pre-emptively.
+func (c *Cache) NextVictims(n int) (victims []simplelfuda.Victim) {
 	c.lock.RLock()
-	keys := c.lfuda.Keys()
+	victims = c.lfuda.NextVictims(n)
 	c.lock.RUnlock()
+	return victims
+}
+
+// EvictBytes evicts the lowest priority entries until at least n bytes of the
+// cache are free, returning them lowest priority first, so room can be cleared
+// ahead of a known-size incoming value.
+func (c *Cache) EvictBytes(n float64) []simplelfuda.Victim {
+	c.writeLock("EvictBytes", nil)
+	victims := c.lfuda.EvictBytes(n)
+	c.publish()
+	c.writeUnlock()
+	return victims
+}
+
+// Renormalize scales every entry's hits and priority, and the cache age, by
+// factor between 0 and 1, preserving relative priorities while shedding the
+// weight of stale history, for example after partially purging the cache.
+func (c *Cache) Renormalize(factor float64) {
+	c.writeLock("Renormalize", nil)
+	c.lfuda.Renormalize(factor)
+	c.publish()
+	c.writeUnlock()
+}
+
+// Keys returns a slice of the keys in the cache in priority order, highest
+// first, the reverse of the order they would be evicted in.
+// The keys are served from a snapshot which is only rebuilt once a writer has
+// changed the cache's keys or values since it was taken, so hits alone don't
+// reorder it.  Keys whose priority changes while it is rebuilt may be out of
+// place.
+func (c *Cache) Keys() []interface{} {
+	snap, ok := c.keys.Load().(*keysSnapshot)
+	if !ok || snap.generation != atomic.LoadUint64(&c.generation) {
+		snap = c.snapshotKeys()
+	}
+
+	keys := make([]interface{}, len(snap.keys))
+	copy(keys, snap.keys)
 	return keys
 }
 
-// Len returns the number of items in the cache.
-func (c *Cache) Len() (length int) {
+// snapshotKeys rebuilds the Keys snapshot.  The keys are copied a chunk at a
+// time, releasing the read lock in between so writers aren't held up for the
+// whole walk; should one change the keys meanwhile the walk starts over, and
+// after keysAttempts the lock is held throughout.
+func (c *Cache) snapshotKeys() *keysSnapshot {
+	c.keysLock.Lock()
+	defer c.keysLock.Unlock()
+	// another caller may have rebuilt it while this one waited
+	if snap, ok := c.keys.Load().(*keysSnapshot); ok && snap.generation == atomic.LoadUint64(&c.generation) {
+		return snap
+	}
+	var snap *keysSnapshot
+	for i := 0; i < keysAttempts && snap == nil; i++ {
+		snap = c.walkKeys(keysChunk)
+	}
+	if snap == nil {
+		snap = c.walkKeys(math.MaxInt)
+	}
+	c.keys.Store(snap)
+	return snap
+}
+
+// walkKeys copies the keys chunk at a time, returning nil if a writer changes
+// them in between.  Opening and closing the walk under the read lock is safe
+// as only one caller at a time holds keysLock, and writers hold the write lock.
+func (c *Cache) walkKeys(chunk int) *keysSnapshot {
 	c.lock.RLock()
-	length = c.lfuda.Len()
+	generation := atomic.LoadUint64(&c.generation)
+	w := c.lfuda.WalkKeys()
+	keys := make([]interface{}, 0, c.lfuda.Len())
+	for done := false; ; {
+		if keys, done = w.Next(keys, chunk); done {
+			break
+		}
+		c.lock.RUnlock()
+		c.lock.RLock()
+		if atomic.LoadUint64(&c.generation) != generation {
+			w.Close()
+			c.lock.RUnlock()
+			return nil
+		}
+	}
+	if !w.Clean() {
+		// entries were promoted past the walk, or visited twice
+		keys = uniqueKeys(append(keys, w.Moved()...))
+	}
+	w.Close()
 	c.lock.RUnlock()
-	return length
+	return &keysSnapshot{generation: generation, keys: keys}
 }
 
-// Size returns the current size of the cache in bytes.
-func (c *Cache) Size() (size float64) {
+// uniqueKeys drops all but the first of any repeated keys, in place.
+func uniqueKeys(keys []interface{}) []interface{} {
+	seen := make(map[interface{}]struct{}, len(keys))
+	unique := keys[:0]
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
+
+// Ascend calls fn for each entry from the lowest priority to the highest, the
+// order they would be evicted in, until fn returns false, so tooling can cheaply
+// preview the next entries to be evicted.  fn is called with the cache read
+// locked and must not call back into the cache.
+func (c *Cache) Ascend(fn func(key, value interface{}) bool) {
+	c.lock.RLock()
+	t := c.guard.startRead()
+	c.lfuda.Ascend(fn)
+	if t != nil {
+		t.Stop()
+	}
+	c.lock.RUnlock()
+}
+
+// Inspect returns a key's hits, priority, last access and other metadata without
+// counting it as a hit.
+func (c *Cache) Inspect(key interface{}) (info simplelfuda.EntryInfo, ok bool) {
 	c.lock.RLock()
-	size = c.lfuda.Size()
+	info, ok = c.lfuda.Inspect(key)
 	c.lock.RUnlock()
-	return size
+	return info, ok
+}
+
+// Entries returns every entry in the cache with its value and metadata, highest
+// priority first.  Entries sharing a priority are ordered most recently
+// accessed first.
+func (c *Cache) Entries() (entries []simplelfuda.EntryInfo) {
+	c.lock.RLock()
+	entries = c.lfuda.Entries()
+	c.lock.RUnlock()
+	return entries
+}
+
+// KeyFilter builds a bloom filter of the keys currently in the cache with the
+// given false positive rate, which can be marshaled and shipped to peers.
+func (c *Cache) KeyFilter(falsePositiveRate float64) *keyfilter.Filter {
+	keys := c.Keys()
+	f := keyfilter.New(len(keys), falsePositiveRate)
+	for _, key := range keys {
+		f.Add(key)
+	}
+	return f
 }
 
-// Age returns the cache's current age
+// Len returns the number of items in the cache.  It never takes the lock.
+func (c *Cache) Len() (length int) {
+	return int(atomic.LoadInt64(&c.length))
+}
+
+// Size returns the current size of the cache in bytes, or in entries if it was
+// created WithEntryCounting or WithMaxEntries.  It never takes the lock, so
+// monitoring can poll it freely.
+func (c *Cache) Size() (size float64) {
+	return math.Float64frombits(atomic.LoadUint64(&c.size))
+}
+
+// Age returns the cache's current age, the priority of the last entry evicted,
+// under the read lock.
 func (c *Cache) Age() (age float64) {
 	c.lock.RLock()
 	age = c.lfuda.Age()
 	c.lock.RUnlock()
 	return age
 }
+
+// AgeHistory returns the aging events recorded when the cache was created
+// WithAgeHistory, oldest first.
+func (c *Cache) AgeHistory() (events []simplelfuda.AgeEvent) {
+	c.lock.RLock()
+	events = c.lfuda.AgeHistory()
+	c.lock.RUnlock()
+	return events
+}
+
+// Residency returns the histogram of how long evicted entries were resident, and
+// false unless the cache was created WithResidencyHistogram.
+func (c *Cache) Residency() (h simplelfuda.Histogram, ok bool) {
+	c.lock.RLock()
+	h, ok = c.lfuda.Residency()
+	c.lock.RUnlock()
+	return h, ok
+}
+
+// Stats returns the cache's hit, miss, eviction, expiration and rejection
+// counts since it was created or ResetStats was last called, with its current
+// age.  Gets made through LazyHits are counted once their hits are folded, and
+// those made through a Local aren't counted.
+func (c *Cache) Stats() (stats simplelfuda.Stats) {
+	c.lock.RLock()
+	stats = c.lfuda.Stats()
+	c.lock.RUnlock()
+	return stats
+}
+
+// ResetStats zeroes the counts returned by Stats and StatsDelta.
+func (c *Cache) ResetStats() {
+	c.writeLock("ResetStats", nil)
+	c.lfuda.ResetStats()
+	c.writeUnlock()
+}
+
+// StatsDelta returns the counts since StatsDelta was last called, or since the
+// cache was created or ResetStats was last called if later, with the current
+// age, so metric scrapers can compute rates without keeping the previous Stats.
+// Only one scraper should call it, as each call starts a new delta.
+func (c *Cache) StatsDelta() (delta simplelfuda.Stats) {
+	c.writeLock("StatsDelta", nil)
+	delta = c.lfuda.StatsDelta()
+	c.writeUnlock()
+	return delta
+}
+
+// WindowStats returns the counts over roughly the last d, rounded to the
+// intervals of the window the cache was created WithStatsWindow, with the
+// current age.  It returns false for caches created without one.  Gets made
+// through LazyHits are counted once folded, and through a Local not at all.
+func (c *Cache) WindowStats(d time.Duration) (stats simplelfuda.Stats, ok bool) {
+	c.writeLock("WindowStats", nil)
+	stats, ok = c.lfuda.WindowStats(d)
+	c.writeUnlock()
+	return stats, ok
+}
+
+// Health checks the cache's invariants and reports its utilization and recent
+// rate of sets refused for being larger than the cache, for readiness probes.
+// It holds the read lock while visiting every entry.
+func (c *Cache) Health() (h simplelfuda.Health) {
+	c.lock.RLock()
+	h = c.lfuda.Health()
+	c.lock.RUnlock()
+	return h
+}
+
+// logAccess records an access to the access log, if any.  Sets are only
+// recorded if the value was stored.  Must be called with the write lock held.
+func (c *Cache) logAccess(key interface{}, op accesslog.Op) {
+	if c.accessLog == nil {
+		return
+	}
+	r := accesslog.Record{Time: time.Now(), Op: op, KeyHash: keyhash.Sum(key)}
+	if info, ok := c.lfuda.Inspect(key); ok {
+		r.Size = info.Size
+	} else if op == accesslog.Set {
+		return
+	}
+	c.accessLog.Write(r)
+}
+
+// Overhead estimates the memory the cache spends on bookkeeping per entry and in
+// total, beyond the keys and values themselves.
+func (c *Cache) Overhead() (o simplelfuda.Overhead) {
+	c.lock.RLock()
+	o = c.lfuda.Overhead()
+	c.lock.RUnlock()
+	return o
+}
+
+// Shape returns statistics about the cache's frequency list, such as the number
+// of nodes and the average length of promotion paths, to detect workloads which
+// degrade it.
+func (c *Cache) Shape() (s simplelfuda.Shape) {
+	c.lock.RLock()
+	s = c.lfuda.Shape()
+	c.lock.RUnlock()
+	return s
+}
+
+// publish refreshes the summary read by Keys, Len and Size and invalidates
+// Locals.  Must be called with the write lock held after any change to the
+// cache.
+func (c *Cache) publish() {
+	atomic.AddUint64(&c.generation, 1)
+	c.publishHits()
+}
+
+// publishHits refreshes the summary after a change to hits alone, which leaves
+// the values Locals hold and the Keys snapshot valid.  Must be called with the
+// write lock held.
+func (c *Cache) publishHits() {
+	atomic.StoreInt64(&c.length, int64(c.lfuda.Len()))
+	atomic.StoreUint64(&c.size, math.Float64bits(c.lfuda.Size()))
+	c.metrics.update(c.lfuda.Len(), c.lfuda.Size(), c.lfuda.Age())
+}
@@ -1,9 +1,17 @@
 package lfuda
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"math"
 	"math/rand"
 	"testing"
+	"time"
+
+	"github.com/bparli/lfuda-go/accesslog"
+	"github.com/bparli/lfuda-go/internal/keyhash"
+	"github.com/bparli/lfuda-go/simplelfuda"
 )
 
 func BenchmarkLFUDA(b *testing.B) {
@@ -182,6 +190,238 @@ func TestGDSF(t *testing.T) {
 	}
 }
 
+func TestPurgePercent(t *testing.T) {
+	l := New(100)
+	for i := 0; i < 4; i++ {
+		l.SetWithSize(i, i, 10)
+	}
+	l.Get(0)
+	if victims := l.PurgePercent(50); len(victims) != 2 || l.Len() != 2 || !l.Contains(0) {
+		t.Errorf("half the entries should have been evicted, keeping 0: %v", victims)
+	}
+	if victims := l.PurgePercentBytes(10); len(victims) != 1 || l.Size() != 10 || !l.Contains(0) {
+		t.Errorf("one entry should have been evicted: %v", victims)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	var evicted []interface{}
+	l := NewWithOptions(
+		WithPolicy(PolicyLRU),
+		WithSizeBytes(24),
+		WithEvictCallback(func(key, value interface{}) {
+			evicted = append(evicted, key)
+		}),
+	)
+	for i := 0; i < 3; i++ {
+		l.Set(i, int64(i))
+	}
+	l.Get(0)
+	l.Set(3, int64(3))
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Errorf("the least recently used key should have been evicted: %v", evicted)
+	}
+
+	l = NewWithOptions(WithSizeBytes(100), WithMaxEntries(2))
+	l.SetWithSize("a", "a", 10)
+	l.SetWithSize("b", "b", 10)
+	l.Get("a")
+	l.SetWithSize("c", "c", 10)
+	if l.Len() != 2 || l.Size() != 20 || !l.Contains("a") {
+		t.Errorf("should be capped at 2 entries: %v", l.Keys())
+	}
+
+	l = NewWithOptions(WithMaxEntries(2))
+	l.Set("a", make([]byte, 1000))
+	l.Set("b", "b")
+	l.Get("a")
+	l.Set("c", "c")
+	if l.Len() != 2 || l.Size() != 2 || !l.Contains("a") {
+		t.Errorf("should hold the 2 most valuable entries: %v", l.Keys())
+	}
+
+	opts := make([]Option, 1, 2)
+	opts[0] = WithMaxEntries(2)
+	spare := append(opts, WithSizeBytes(100))
+	NewWithOptions(opts...)
+	if l := NewWithOptions(spare...); l.Set("a", make([]byte, 10)) || l.Size() != 10 {
+		t.Errorf("the caller's options shouldn't be changed: %v", l.Size())
+	}
+
+	for _, opts := range [][]Option{nil, {WithSizeBytes(100), WithPolicy("LRFU")}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("unusable options should panic: %v", opts)
+				}
+			}()
+			NewWithOptions(opts...)
+		}()
+	}
+}
+
+func TestEntryCounting(t *testing.T) {
+	l := NewGDSF(2, WithEntryCounting())
+	l.Set("a", make([]byte, 1000))
+	l.SetWithSize("b", "b", 1000)
+	l.Set("c", "c")
+	if l.Len() != 2 || l.Size() != 2 {
+		t.Errorf("should hold 2 entries whatever their size: %v", l.Keys())
+	}
+}
+
+func TestLFU(t *testing.T) {
+	var evicted []interface{}
+	l := NewLFUWithEvict(24, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	for i := 0; i < 3; i++ {
+		l.Set(i, int64(i))
+	}
+	for i := 0; i < 5; i++ {
+		l.Get(0)
+	}
+	l.Get(1)
+	l.Set(3, int64(3))
+	l.Set(4, int64(4))
+	// without aging 3 never catches up with 1, which LFUDA would evict instead
+	if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != 3 {
+		t.Errorf("the least frequently used keys should have been evicted: %v", evicted)
+	}
+}
+
+func TestResize(t *testing.T) {
+	l := New(40)
+	for i := 0; i < 4; i++ {
+		l.SetWithSize(i, i, 10)
+	}
+	l.Get(0)
+	if victims := l.Resize(20); len(victims) != 2 || l.Size() != 20 || !l.Contains(0) {
+		t.Errorf("the coldest entries should have been evicted: %v", victims)
+	}
+	victims := l.Resize(100)
+	if evicted := l.Set(4, make([]byte, 60)); len(victims) != 0 || evicted || l.Len() != 3 {
+		t.Errorf("growing should make room without evicting: %v", victims)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	l := New(100)
+	l.SetWithTTL("a", "a", time.Millisecond)
+	l.SetWithTTL("b", "b", time.Millisecond)
+	l.SetWithTTL("c", "c", time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := l.Get("a"); ok || l.Len() != 2 || l.Size() != 2 {
+		t.Errorf("a should have expired: %v", l.Keys())
+	}
+
+	if !l.Touch("c", 2*time.Hour) {
+		t.Errorf("c should be touched")
+	}
+	if _, expires, ok := l.GetWithExpiry("c"); !ok || time.Until(expires) < time.Hour {
+		t.Errorf("c's expiry should have been extended: %v", expires)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.RunJanitor(ctx, time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for l.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if l.Len() != 1 || !l.Contains("c") {
+		t.Errorf("the janitor should have removed b: %v", l.Keys())
+	}
+}
+
+func TestTTLWeight(t *testing.T) {
+	l := New(2, WithTTLWeight(5, time.Hour))
+	l.SetWithTTL("hot", "h", time.Minute)
+	l.Get("hot")
+	l.Get("hot")
+	l.Set("cold", "c")
+	l.Set("new", "n")
+	if l.Contains("hot") || !l.Contains("cold") {
+		t.Errorf("the entry about to expire should be evicted: %v", l.Keys())
+	}
+}
+
+func TestStats(t *testing.T) {
+	l := New(2)
+	l.Set("a", "a")
+	l.Get("a")
+	l.Get("b")
+	l.Set("b", "b")
+	l.Set("c", "c")
+	if stats := l.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Age != 1 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	l.ResetStats()
+	if stats := l.Stats(); stats.Hits != 0 || stats.Evictions != 0 {
+		t.Errorf("stats should be reset: %+v", stats)
+	}
+	l.Get("c")
+	if delta := l.StatsDelta(); delta.Hits != 1 || delta.Age != 1 {
+		t.Errorf("bad delta: %+v", delta)
+	}
+	if delta := l.StatsDelta(); delta.Hits != 0 || l.Stats().Hits != 1 {
+		t.Errorf("delta should restart but not the counts: %+v", delta)
+	}
+}
+
+func TestWindowStats(t *testing.T) {
+	l := New(10, WithStatsWindow(time.Minute, time.Hour))
+	l.Set("a", "a")
+	l.Get("a")
+	l.Get("b")
+	if stats, ok := l.WindowStats(5 * time.Minute); !ok || stats.Hits != 1 || stats.HitRatio() != 0.5 {
+		t.Errorf("bad window stats: %+v, %v", stats, ok)
+	}
+	if _, ok := New(10).WindowStats(time.Minute); ok {
+		t.Errorf("caches without a window shouldn't report one")
+	}
+}
+
+func TestLRU(t *testing.T) {
+	var evicted []interface{}
+	l := NewLRUWithEvict(24, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	for i := 0; i < 3; i++ {
+		l.Set(i, int64(i))
+	}
+	for i := 0; i < 5; i++ {
+		l.Get(0)
+	}
+	l.Get(1)
+	l.Set(3, int64(3))
+	l.Set(4, int64(4))
+	if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != 0 {
+		t.Errorf("the least recently used keys should have been evicted: %v", evicted)
+	}
+}
+
+func TestMRU(t *testing.T) {
+	var evicted []interface{}
+	l := NewMRUWithEvict(24, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	for i := 0; i < 3; i++ {
+		l.Set(i, int64(i))
+	}
+	l.Get(0)
+	l.Set(3, int64(3))
+	l.Set(4, int64(4))
+	if len(evicted) != 2 || evicted[0] != 0 || evicted[1] != 3 {
+		t.Errorf("the most recently used keys should have been evicted: %v", evicted)
+	}
+}
+
 // test that Set returns true/false
 func TestLFUDASet(t *testing.T) {
 	evictCounter := 0
@@ -361,3 +601,447 @@ func TestLFUDASize(t *testing.T) {
 		t.Errorf("Cache size should be reset to 0 (but it wasn't)")
 	}
 }
+
+func TestLFUDASetWithSize(t *testing.T) {
+	l := New(10)
+	l.SetWithSize("a", "a", 6)
+	l.SetWithSize("b", "b", 4)
+	if l.Size() != 10 || l.Len() != 2 {
+		t.Errorf("bad summary: %v, %v", l.Size(), l.Len())
+	}
+	l.Get("b")
+	if !l.SetWithSize("c", "c", 5) || l.Contains("a") {
+		t.Errorf("a should have been evicted to make room for c")
+	}
+	if l.Size() != 9 {
+		t.Errorf("bad size: %v", l.Size())
+	}
+}
+
+func TestLFUDASetWithVictims(t *testing.T) {
+	l := New(3)
+	l.Set(1, 1)
+	l.Set(2, 2)
+	l.Get(2)
+	set, victims := l.SetWithVictims(3, "xx")
+	if !set || len(victims) != 1 || victims[0].Key != 1 || victims[0].Value != 1 {
+		t.Errorf("1 should have been the only victim: %v", victims)
+	}
+	if l.Len() != 2 || l.Size() != 3 {
+		t.Errorf("bad summary: %v, %v", l.Len(), l.Size())
+	}
+}
+
+func TestLFUDAKeysSnapshot(t *testing.T) {
+	l := New(100)
+	l.Set(1, 1)
+	l.Set(2, 2)
+
+	keys := l.Keys()
+	if len(keys) != 2 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	// callers own the returned slice
+	keys[0] = 42
+	if l.Keys()[0] == 42 {
+		t.Errorf("Keys should return a copy of the snapshot")
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Get(1)
+	}
+	if l.Keys()[0] != 2 {
+		t.Errorf("hits alone shouldn't refresh the snapshot")
+	}
+	l.Set(3, 3)
+	if keys := l.Keys(); len(keys) != 3 || keys[0] != 1 {
+		t.Errorf("snapshot should be refreshed after a write: %v", keys)
+	}
+
+	l.Remove(1)
+	l.Remove(3)
+	if l.Len() != 1 || len(l.Keys()) != 1 || l.Size() != 1 {
+		t.Errorf("bad summary after Remove: %v, %v, %v", l.Len(), l.Keys(), l.Size())
+	}
+}
+
+func TestLFUDAConcurrentKeys(t *testing.T) {
+	l := New(800)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			l.Set(i, i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if n := len(l.Keys()); n > 100 {
+			t.Errorf("too many keys: %d", n)
+		}
+		if l.Len() > 100 || l.Size() > 800 {
+			t.Errorf("bad summary: %v, %v", l.Len(), l.Size())
+		}
+	}
+	<-done
+}
+
+func TestLFUDAKeysChunked(t *testing.T) {
+	n := 3 * keysChunk
+	l := New(float64(10 * n))
+	for i := 0; i < n; i++ {
+		l.Set(i, i)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i = (i + 7) % n {
+			select {
+			case <-stop:
+				return
+			default:
+				l.Get(i)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		// force a rebuild while hits move entries around
+		l.Set(0, 0)
+		keys := l.Keys()
+		seen := make(map[interface{}]bool, len(keys))
+		for _, key := range keys {
+			if seen[key] {
+				t.Fatalf("%v repeated", key)
+			}
+			seen[key] = true
+		}
+		if len(keys) != n {
+			t.Fatalf("expected %d keys, got %d", n, len(keys))
+		}
+	}
+	close(stop)
+	<-done
+}
+
+func TestLFUDAEvict(t *testing.T) {
+	l := New(10)
+	for i := 0; i < 5; i++ {
+		l.Set(i, i)
+	}
+	l.Get(4)
+	if victims := l.Evict(4); len(victims) != 4 || l.Len() != 1 || !l.Contains(4) {
+		t.Errorf("4 should be the only key left: %v", l.Keys())
+	}
+}
+
+func TestLFUDAEvictBytes(t *testing.T) {
+	l := New(10)
+	for i := 0; i < 10; i++ {
+		l.Set(i, i)
+	}
+	l.EvictBytes(3)
+	if l.Size() != 7 || l.Len() != 7 {
+		t.Errorf("3 bytes should have been freed: %v", l.Size())
+	}
+}
+
+func TestLFUDASetWithBoost(t *testing.T) {
+	l := New(2)
+	l.SetWithBoost(1, 1, 10)
+	l.Set(2, 2)
+	for i := 0; i < 5; i++ {
+		l.Get(2)
+	}
+	l.Set(3, 3)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Errorf("boosted key 1 should have been kept: %v", l.Keys())
+	}
+}
+
+func TestLFUDASetWithClass(t *testing.T) {
+	l := New(2)
+	l.SetWithClass(1, 1, 1)
+	for i := 2; i < 10; i++ {
+		l.Set(i, i)
+		l.Get(i)
+	}
+	if !l.Contains(1) {
+		t.Errorf("the higher class key should never be evicted by class 0 keys")
+	}
+}
+
+func TestLFUDAScanResistance(t *testing.T) {
+	l := New(2, WithScanResistance())
+	l.Set(1, 1)
+	l.Get(1)
+	l.Get(1)
+
+	// scan through keys which are each read once
+	for i := 10; i < 12; i++ {
+		l.Set(i, "x")
+		l.Get(i)
+	}
+	if !l.Contains(1) {
+		t.Errorf("1 should have survived the scan: %v", l.Keys())
+	}
+}
+
+func TestLFUDAProbabilisticHits(t *testing.T) {
+	l := New(2, WithProbabilisticHits(5))
+	l.Set(1, 1)
+	l.Set(2, 2)
+	for i := 0; i < 1000; i++ {
+		l.Get(1)
+	}
+	l.Set(3, 3)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Errorf("1 should still be the most popular: %v", l.Keys())
+	}
+}
+
+func TestLFUDALogCounters(t *testing.T) {
+	l := New(2, WithLogCounters(2))
+	l.Set(1, 1)
+	for i := 0; i < 1000; i++ {
+		l.Get(1)
+	}
+	// 1's lead is only logarithmic, so a short run of evictions ages it out
+	for i := 2; i < 40; i++ {
+		l.Set(i, "x")
+		l.Get(i)
+	}
+	if l.Contains(1) {
+		t.Errorf("1 should have aged out")
+	}
+}
+
+func TestLFUDARenormalize(t *testing.T) {
+	l := New(2)
+	l.Set(1, 1)
+	for i := 0; i < 100; i++ {
+		l.Get(1)
+	}
+	l.Set(2, 2)
+	l.Renormalize(0.01)
+	// 1's long history no longer outweighs a few recent hits
+	for i := 0; i < 3; i++ {
+		l.Get(2)
+	}
+	l.Set(3, 3)
+	if l.Contains(1) {
+		t.Errorf("1 should have been evicted: %v", l.Keys())
+	}
+}
+
+func TestLFUDAAscend(t *testing.T) {
+	l := New(10)
+	for i := 0; i < 5; i++ {
+		l.Set(i, i)
+		for j := 0; j < i; j++ {
+			l.Get(i)
+		}
+	}
+	next := 0
+	l.Ascend(func(key, value interface{}) bool {
+		if key != next {
+			t.Errorf("expected %d next: %v", next, key)
+		}
+		next++
+		return next < 3
+	})
+	if next != 3 {
+		t.Errorf("Ascend should have stopped after 3 keys")
+	}
+}
+
+func TestLFUDAAgeHistory(t *testing.T) {
+	l := New(1, WithAgeHistory(10))
+	l.Set(1, 1)
+	l.Set(2, 2)
+	if events := l.AgeHistory(); len(events) != 1 || events[0].NewAge != 1 {
+		t.Errorf("bad age history: %v", events)
+	}
+}
+
+func TestLFUDAResidency(t *testing.T) {
+	l := New(1, WithResidencyHistogram())
+	l.Set(1, 1)
+	l.Set(2, 2)
+	if h, ok := l.Residency(); !ok || h.Count != 1 || h.Counts[0] != 1 {
+		t.Errorf("bad residency histogram: %+v", h)
+	}
+}
+
+func TestLFUDAEntries(t *testing.T) {
+	l := New(10)
+	l.Set(1, 1)
+	l.Set(2, 2)
+	l.Get(1)
+	if info, ok := l.Inspect(1); !ok || info.Hits != 2 {
+		t.Errorf("bad info: %+v", info)
+	}
+	if entries := l.Entries(); len(entries) != 2 || entries[0].Key != 1 || entries[0].LastAccess <= entries[1].LastAccess {
+		t.Errorf("bad entries: %+v", entries)
+	}
+}
+
+func TestLFUDAPeekMulti(t *testing.T) {
+	l := New(10)
+	l.Set(1, 1)
+	l.Set(2, 2)
+	if values := l.PeekMulti([]interface{}{1, 3}); len(values) != 1 || values[1] != 1 {
+		t.Errorf("bad values: %v", values)
+	}
+}
+
+func TestLFUDAContainsMulti(t *testing.T) {
+	l := New(10)
+	l.Set(1, 1)
+	if present := l.ContainsMulti([]interface{}{2, 1}); len(present) != 2 || present[0] || !present[1] {
+		t.Errorf("bad result: %v", present)
+	}
+}
+
+func TestLFUDAKeyFilter(t *testing.T) {
+	l := New(100)
+	for i := 0; i < 50; i++ {
+		l.Set(i, "x")
+	}
+	f := l.KeyFilter(0.001)
+	for i := 0; i < 50; i++ {
+		if !f.MayContain(i) {
+			t.Fatalf("filter is missing %d", i)
+		}
+	}
+	if f.MayContain(50) && f.MayContain(51) && f.MayContain(52) {
+		t.Errorf("filter should not contain keys which were never set")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	c := New(4)
+	c.Set("a", "x")
+	c.Set("b", "too big")
+	if h := c.Health(); !h.Healthy() || h.Utilization != 0.25 || h.RefusalRate != 0.5 {
+		t.Errorf("bad health: %+v", h)
+	}
+}
+
+func TestErrorHook(t *testing.T) {
+	var errs []error
+	c := NewWithEvict(1, func(key, value interface{}) { panic("boom") }, WithErrorHook(func(err error) { errs = append(errs, err) }))
+	c.Set("a", "x")
+	c.Set("b", "x")
+	if len(errs) != 1 || !c.Contains("b") {
+		t.Errorf("panic should be passed to the error hook: %v", errs)
+	}
+}
+
+func TestNilValue(t *testing.T) {
+	c := New(10)
+	c.Set("a", nil)
+	if v, ok := c.Get("a"); v != nil || !ok || c.Size() != 0 {
+		t.Errorf("nil values should be found: %v, %v", v, ok)
+	}
+	if v, ok, set := c.PeekOrSet("a", "x"); v != nil || !ok || set {
+		t.Errorf("nil values should count as present: %v, %v, %v", v, ok, set)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	w := accesslog.NewWriter(&buf)
+	c := New(10, WithAccessLog(w))
+	c.Get("a")
+	c.Set("a", "xyz")
+	c.Get("a")
+	c.Set("b", "much too big for the cache")
+	w.Flush()
+
+	r := accesslog.NewReader(&buf)
+	for _, want := range []accesslog.Record{{Op: accesslog.Miss}, {Op: accesslog.Set, Size: 3}, {Op: accesslog.Hit, Size: 3}} {
+		got, err := r.Read()
+		if err != nil || got.Op != want.Op || got.Size != want.Size || got.KeyHash != keyhash.Sum("a") {
+			t.Errorf("got %+v, %v, want %+v", got, err, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("refused sets should not be logged: %v", err)
+	}
+}
+
+func TestRejectCallback(t *testing.T) {
+	var rejected []interface{}
+	c := New(3, WithRejectCallback(func(key, value interface{}, reason simplelfuda.RejectReason) {
+		rejected = append(rejected, key)
+	}))
+	c.Set("a", "abc")
+	if c.Set("big", "toobig") || len(rejected) != 1 || rejected[0] != "big" {
+		t.Errorf("refused sets should be reported: %v", rejected)
+	}
+}
+
+func TestEvictionChannel(t *testing.T) {
+	ch := make(chan Eviction, 1)
+	called := 0
+	c := NewWithEvict(2, func(key, value interface{}) { called++ }, WithEvictionChannel(ch))
+	c.Set("a", "x")
+	c.Set("b", "x")
+	c.Get("b")
+	c.Set("c", "x")
+	if e := <-ch; e.Key != "a" || e.Value != "x" || called != 1 {
+		t.Errorf("bad eviction: %+v, %d", e, called)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range ch {
+			if e.Key == "c" {
+				return
+			}
+		}
+	}()
+	c.Remove("b")
+	c.Remove("c")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("evictions should be sent until the channel is read")
+	}
+}
+
+func TestEvictionChannelReentrant(t *testing.T) {
+	ch := make(chan Eviction)
+	c := New(2, WithEvictionChannel(ch))
+	c.Set("a", "x")
+	c.Set("b", "x")
+
+	// nothing reads ch yet, so this Set waits to send its eviction
+	set := make(chan struct{})
+	go func() {
+		c.Set("c", "x")
+		close(set)
+	}()
+	got := make(chan bool)
+	go func() {
+		_, ok := c.Get("b")
+		got <- ok
+	}()
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatalf("a full channel shouldn't hold up other operations")
+	}
+
+	// the reader uses the cache while the Set is waiting on it
+	select {
+	case e := <-ch:
+		if e.Key != "a" || c.Contains("a") || c.Len() != 2 {
+			t.Errorf("bad eviction: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the eviction should be sent")
+	}
+	<-set
+}
@@ -0,0 +1,221 @@
+// Package lfudadebug serves a summary of lfuda caches over HTTP for quick human
+// inspection, in the style of net/http/pprof.
+//
+// Importing the package registers its handler at /debug/lfuda/ on
+// http.DefaultServeMux, listing the caches in lfuda's process-wide registry.  Each cache's
+// page shows its size, age, the distribution of entries over priorities and its
+// highest priority keys.  Append ?format=json, or send Accept: application/json,
+// for the same report as JSON.  Handler serves a single cache's report for use
+// with other muxes.
+//
+// Reports include keys, so like pprof the handler should only be exposed on an
+// internal port.
+package lfudadebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bparli/lfuda-go"
+)
+
+const prefix = "/debug/lfuda/"
+
+func init() {
+	http.HandleFunc(prefix, Index)
+}
+
+// Register adds a cache to the index under name, replacing any cache already
+// registered under it.  It's lfuda.Register, so the cache is also visible to
+// anything else enumerating the registry.
+func Register(name string, c *lfuda.Cache) {
+	lfuda.Register(name, c)
+}
+
+// Unregister removes the cache registered under name.
+func Unregister(name string) {
+	lfuda.Unregister(name)
+}
+
+// Report summarizes a cache.
+type Report struct {
+	Name         string
+	Len          int
+	Size         float64
+	Age          float64
+	Distribution []Bucket
+	Top          []Entry
+}
+
+// Bucket counts the entries with priorities in [Min, Max).
+type Bucket struct {
+	Min     float64
+	Max     float64
+	Entries int
+	Bytes   float64
+}
+
+// Entry describes one of a cache's highest priority entries.
+type Entry struct {
+	Key      string
+	Hits     float64
+	Priority float64
+	Size     float64
+}
+
+// NewReport summarizes c, spreading its entries over up to buckets priority
+// buckets and listing its top highest priority entries.
+func NewReport(name string, c *lfuda.Cache, buckets, top int) Report {
+	entries := c.Entries()
+	r := Report{
+		Name: name,
+		Len:  len(entries),
+		Size: c.Size(),
+		Age:  c.Age(),
+	}
+	for i, e := range entries {
+		if i == top {
+			break
+		}
+		r.Top = append(r.Top, Entry{
+			Key:      fmt.Sprint(e.Key),
+			Hits:     e.Hits,
+			Priority: e.Priority,
+			Size:     e.Size,
+		})
+	}
+	if len(entries) == 0 || buckets < 1 {
+		return r
+	}
+
+	// entries are highest priority first
+	lo, hi := entries[len(entries)-1].Priority, entries[0].Priority
+	width := (hi - lo) / float64(buckets)
+	if width == 0 || math.IsNaN(width) || math.IsInf(width, 0) {
+		buckets, width = 1, 1
+	}
+	r.Distribution = make([]Bucket, buckets)
+	for i := range r.Distribution {
+		r.Distribution[i].Min = lo + float64(i)*width
+		r.Distribution[i].Max = lo + float64(i+1)*width
+	}
+	for _, e := range entries {
+		// priorities which aren't finite go in the first bucket rather than
+		// indexing out of range
+		i := 0
+		if x := (e.Priority - lo) / width; x > 0 {
+			i = int(math.Min(float64(buckets-1), x))
+		}
+		r.Distribution[i].Entries++
+		r.Distribution[i].Bytes += e.Size
+	}
+	return r
+}
+
+// Handler serves the report for c, with the number of buckets and top entries
+// set by the buckets and top query parameters.
+func Handler(name string, c *lfuda.Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := NewReport(name, c, intParam(r, "buckets", 20), intParam(r, "top", 50))
+		if wantsJSON(r) {
+			writeJSON(w, report)
+			return
+		}
+		render(w, reportTemplate, report)
+	})
+}
+
+// Index lists the registered caches, or serves the report for the cache named
+// by the rest of the path.
+func Index(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, prefix)
+	if name != "" {
+		c, ok := lfuda.Lookup(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		Handler(name, c).ServeHTTP(w, r)
+		return
+	}
+
+	type summary struct {
+		Name string
+		Len  int
+		Size float64
+		Age  float64
+	}
+	caches := lfuda.Registered()
+	list := make([]summary, 0, len(caches))
+	for name, c := range caches {
+		list = append(list, summary{Name: name, Len: c.Len(), Size: c.Size(), Age: c.Age()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	if wantsJSON(r) {
+		writeJSON(w, list)
+		return
+	}
+	render(w, indexTemplate, list)
+}
+
+func intParam(r *http.Request, name string, def int) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil && n >= 0 {
+		return n
+	}
+	return def
+}
+
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func render(w http.ResponseWriter, t *template.Template, v interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<html>
+<head><title>/debug/lfuda/</title></head>
+<body>
+<p>/debug/lfuda/</p>
+<table>
+<tr><th>cache</th><th>entries</th><th>bytes</th><th>age</th></tr>
+{{range .}}<tr><td><a href="{{.Name}}">{{.Name}}</a></td><td>{{.Len}}</td><td>{{.Size}}</td><td>{{.Age}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var reportTemplate = template.Must(template.New("report").Parse(`<html>
+<head><title>/debug/lfuda/{{.Name}}</title></head>
+<body>
+<p>/debug/lfuda/{{.Name}}: {{.Len}} entries, {{.Size}} bytes, age {{.Age}}</p>
+<p>priority distribution</p>
+<table>
+<tr><th>priority</th><th>entries</th><th>bytes</th></tr>
+{{range .Distribution}}<tr><td>{{printf "%.2f" .Min}} - {{printf "%.2f" .Max}}</td><td>{{.Entries}}</td><td>{{.Bytes}}</td></tr>
+{{end}}</table>
+<p>top keys</p>
+<table>
+<tr><th>key</th><th>hits</th><th>priority</th><th>bytes</th></tr>
+{{range .Top}}<tr><td>{{.Key}}</td><td>{{.Hits}}</td><td>{{.Priority}}</td><td>{{.Size}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,69 @@
+package lfudadebug
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+)
+
+func TestNewReport(t *testing.T) {
+	c := lfuda.New(100)
+	for i := 0; i < 10; i++ {
+		c.Set(i, "x")
+		for j := 0; j < i; j++ {
+			c.Get(i)
+		}
+	}
+	r := NewReport("test", c, 3, 2)
+	if r.Len != 10 || r.Size != 10 || len(r.Top) != 2 || r.Top[0].Key != "9" || r.Top[0].Hits != 10 {
+		t.Errorf("bad report: %+v", r)
+	}
+	total := 0
+	for _, b := range r.Distribution {
+		total += b.Entries
+	}
+	if len(r.Distribution) != 3 || total != 10 || r.Distribution[0].Min != 1 || r.Distribution[2].Max != 10 {
+		t.Errorf("bad distribution: %+v", r.Distribution)
+	}
+
+	if r := NewReport("empty", lfuda.New(1), 3, 2); r.Distribution != nil || r.Top != nil {
+		t.Errorf("empty caches should have empty reports: %+v", r)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	c := lfuda.New(100)
+	c.Set("<b>", "x")
+	Register("test", c)
+	defer Unregister("test")
+	srv := httptest.NewServer(http.DefaultServeMux)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/debug/lfuda/?format=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []struct{ Name string }
+	json.NewDecoder(res.Body).Decode(&list)
+	res.Body.Close()
+	if len(list) != 1 || list[0].Name != "test" {
+		t.Errorf("bad index: %+v", list)
+	}
+
+	res, _ = http.Get(srv.URL + "/debug/lfuda/test")
+	page, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "&lt;b&gt;") || strings.Contains(string(page), "<b>") {
+		t.Errorf("keys should be escaped in HTML: %s", page)
+	}
+
+	res, _ = http.Get(srv.URL + "/debug/lfuda/missing")
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown caches should 404: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,76 @@
+package lfudaotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/bparli/lfuda-go/simplelfuda"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Hooks implements lfuda.Hooks, counting hits, misses, sets and evictions and
+// recording the latencies of Gets and Sets in seconds:
+//
+//	c := lfuda.New(size, lfuda.WithHooks(lfudaotel.NewHooks(otel.Meter("myapp"), "sessions")))
+type Hooks struct {
+	hits, misses, sets, evictions metric.Int64Counter
+	getDuration, setDuration      metric.Float64Histogram
+}
+
+var _ lfuda.Hooks = (*Hooks)(nil)
+
+// NewHooks creates Hooks creating instruments from meter, named prefix.name.
+// Errors creating instruments are passed to otel.Handle.
+func NewHooks(meter metric.Meter, prefix string) *Hooks {
+	m := &Metrics{meter: meter, prefix: prefix}
+	counter := func(name, help string) metric.Int64Counter {
+		c, err := meter.Int64Counter(m.name(name), metric.WithDescription(help))
+		if err != nil {
+			otel.Handle(err)
+		}
+		return c
+	}
+	histogram := func(name, help string) metric.Float64Histogram {
+		h, err := meter.Float64Histogram(m.name(name), metric.WithDescription(help), metric.WithUnit("s"))
+		if err != nil {
+			otel.Handle(err)
+		}
+		return h
+	}
+	return &Hooks{
+		hits:        counter("hits", "Gets which found their key."),
+		misses:      counter("misses", "Gets which did not find their key."),
+		sets:        counter("sets", "Sets of any kind."),
+		evictions:   counter("evictions", "Entries evicted to make room."),
+		getDuration: histogram("get.duration", "Latency of Gets, including waiting for the lock."),
+		setDuration: histogram("set.duration", "Latency of Sets, including waiting for the lock."),
+	}
+}
+
+// OnHit implements lfuda.Hooks.
+func (h *Hooks) OnHit(key interface{}, d time.Duration) {
+	ctx := context.Background()
+	h.hits.Add(ctx, 1)
+	h.getDuration.Record(ctx, d.Seconds())
+}
+
+// OnMiss implements lfuda.Hooks.
+func (h *Hooks) OnMiss(key interface{}, d time.Duration) {
+	ctx := context.Background()
+	h.misses.Add(ctx, 1)
+	h.getDuration.Record(ctx, d.Seconds())
+}
+
+// OnSet implements lfuda.Hooks.
+func (h *Hooks) OnSet(key interface{}, d time.Duration) {
+	ctx := context.Background()
+	h.sets.Add(ctx, 1)
+	h.setDuration.Record(ctx, d.Seconds())
+}
+
+// OnEvict implements lfuda.Hooks.
+func (h *Hooks) OnEvict(v simplelfuda.Victim) {
+	h.evictions.Add(context.Background(), 1)
+}
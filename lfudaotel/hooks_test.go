@@ -0,0 +1,40 @@
+package lfudaotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestHooks(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	c := lfuda.New(2, lfuda.WithHooks(NewHooks(provider.Meter("test"), "cache")))
+	c.Set("a", "x")
+	c.Get("a")
+	c.Get("b")
+	c.Set("b", "yy")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				got[m.Name] = data.DataPoints[0].Value
+			case metricdata.Histogram[float64]:
+				got[m.Name] = int64(data.DataPoints[0].Count)
+			}
+		}
+	}
+	if got["cache.hits"] != 1 || got["cache.misses"] != 1 || got["cache.sets"] != 2 || got["cache.evictions"] != 1 ||
+		got["cache.get.duration"] != 2 || got["cache.set.duration"] != 2 {
+		t.Errorf("bad metrics: %v", got)
+	}
+}
@@ -0,0 +1,71 @@
+// Package lfudaotel reports lfuda cache metrics to OpenTelemetry, through
+// lfuda.WithMetrics or, with latencies, lfuda.WithHooks.
+//
+//	c := lfuda.New(size, lfuda.WithMetrics(lfudaotel.New(otel.Meter("myapp"), "sessions")))
+package lfudaotel
+
+import (
+	"context"
+
+	"github.com/bparli/lfuda-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Metrics creates OpenTelemetry instruments for a cache.
+type Metrics struct {
+	meter  metric.Meter
+	prefix string
+}
+
+// New creates lfuda.Metrics creating instruments from meter, named
+// prefix.name.  Errors creating instruments are passed to otel.Handle.
+func New(meter metric.Meter, prefix string) *Metrics {
+	return &Metrics{meter: meter, prefix: prefix}
+}
+
+func (m *Metrics) name(name string) string {
+	if m.prefix == "" {
+		return name
+	}
+	return m.prefix + "." + name
+}
+
+// Counter implements lfuda.Metrics.
+func (m *Metrics) Counter(name, help string) lfuda.Counter {
+	c, err := m.meter.Float64Counter(m.name(name), metric.WithDescription(help))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return counter{c}
+}
+
+// Gauge implements lfuda.Metrics.
+func (m *Metrics) Gauge(name, help string) lfuda.Gauge {
+	g, err := m.meter.Float64Gauge(m.name(name), metric.WithDescription(help))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return gauge{g}
+}
+
+// Histogram implements lfuda.Metrics.
+func (m *Metrics) Histogram(name, help string) lfuda.Histogram {
+	h, err := m.meter.Float64Histogram(m.name(name), metric.WithDescription(help))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return histogram{h}
+}
+
+type counter struct{ metric.Float64Counter }
+
+func (c counter) Add(delta float64) { c.Float64Counter.Add(context.Background(), delta) }
+
+type gauge struct{ metric.Float64Gauge }
+
+func (g gauge) Set(value float64) { g.Float64Gauge.Record(context.Background(), value) }
+
+type histogram struct{ metric.Float64Histogram }
+
+func (h histogram) Observe(value float64) { h.Float64Histogram.Record(context.Background(), value) }
@@ -0,0 +1,39 @@
+package lfudaotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	c := lfuda.New(2, lfuda.WithMetrics(New(provider.Meter("test"), "cache")))
+	c.Set("a", "x")
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[float64]:
+				got[m.Name] = data.DataPoints[0].Value
+			case metricdata.Gauge[float64]:
+				got[m.Name] = data.DataPoints[0].Value
+			}
+		}
+	}
+	if got["cache.hits"] != 2 || got["cache.misses"] != 1 || got["cache.entries"] != 1 {
+		t.Errorf("bad metrics: %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package lfudaprom
+
+import (
+	"github.com/bparli/lfuda-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector reading caches' sizes, ages and Stats
+// whenever it is scraped, so caches can be monitored without configuring them
+// WithMetrics.  Its counters come from Stats, so don't call ResetStats on the
+// caches it collects.
+type Collector struct {
+	caches func() map[string]*lfuda.Cache
+	// whether the metrics are labelled by cache name
+	labelled bool
+
+	bytes, entries, age, hitRatio *prometheus.Desc
+	hits, misses, evictions       *prometheus.Desc
+	evictedBytes, expirations     *prometheus.Desc
+	rejected                      *prometheus.Desc
+}
+
+// NewCollector creates a Collector for c, naming its metrics
+// namespace_subsystem_name.
+func NewCollector(c *lfuda.Cache, namespace, subsystem string) *Collector {
+	return newCollector(func() map[string]*lfuda.Cache {
+		return map[string]*lfuda.Cache{"": c}
+	}, false, namespace, subsystem)
+}
+
+// NewRegistryCollector creates a Collector for every cache registered with
+// lfuda.Register at the time of each scrape, labelling their metrics with the
+// names they are registered under as "cache".
+func NewRegistryCollector(namespace, subsystem string) *Collector {
+	return newCollector(lfuda.Registered, true, namespace, subsystem)
+}
+
+func newCollector(caches func() map[string]*lfuda.Cache, labelled bool, namespace, subsystem string) *Collector {
+	var labels []string
+	if labelled {
+		labels = []string{"cache"}
+	}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+	}
+	return &Collector{
+		caches:       caches,
+		labelled:     labelled,
+		bytes:        desc("bytes", "Total size of the entries in the cache."),
+		entries:      desc("entries", "Entries in the cache."),
+		age:          desc("age", "The cache age."),
+		hitRatio:     desc("hit_ratio", "Fraction of Gets which found their key."),
+		hits:         desc("hits_total", "Gets which found their key."),
+		misses:       desc("misses_total", "Gets which did not find their key."),
+		evictions:    desc("evictions_total", "Entries evicted to make room."),
+		evictedBytes: desc("evicted_bytes_total", "Total size of the entries evicted."),
+		expirations:  desc("expirations_total", "Expired entries removed."),
+		rejected:     desc("rejected_total", "Sets refused for being larger than the cache."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.bytes, c.entries, c.age, c.hitRatio, c.hits, c.misses,
+		c.evictions, c.evictedBytes, c.expirations, c.rejected,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for name, cache := range c.caches() {
+		var labels []string
+		if c.labelled {
+			labels = []string{name}
+		}
+		gauge := func(d *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, labels...)
+		}
+		counter := func(d *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, labels...)
+		}
+
+		stats := cache.Stats()
+		gauge(c.bytes, cache.Size())
+		gauge(c.entries, float64(cache.Len()))
+		gauge(c.age, stats.Age)
+		gauge(c.hitRatio, stats.HitRatio())
+		counter(c.hits, float64(stats.Hits))
+		counter(c.misses, float64(stats.Misses))
+		counter(c.evictions, float64(stats.Evictions))
+		counter(c.evictedBytes, stats.EvictedBytes)
+		counter(c.expirations, float64(stats.Expirations))
+		counter(c.rejected, float64(stats.Rejected))
+	}
+}
@@ -0,0 +1,48 @@
+package lfudaprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := lfuda.New(2)
+	c.Set("a", "x")
+	c.Get("a")
+	c.Get("b")
+	c.Set("b", "yy")
+
+	expected := `
+# HELP test_cache_entries Entries in the cache.
+# TYPE test_cache_entries gauge
+test_cache_entries 1
+# HELP test_cache_evictions_total Entries evicted to make room.
+# TYPE test_cache_evictions_total counter
+test_cache_evictions_total 1
+# HELP test_cache_hit_ratio Fraction of Gets which found their key.
+# TYPE test_cache_hit_ratio gauge
+test_cache_hit_ratio 0.5
+`
+	err := testutil.CollectAndCompare(NewCollector(c, "test", "cache"), strings.NewReader(expected),
+		"test_cache_entries", "test_cache_evictions_total", "test_cache_hit_ratio")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistryCollector(t *testing.T) {
+	lfuda.Register("first", lfuda.New(10))
+	lfuda.Register("second", lfuda.New(10))
+	defer lfuda.Unregister("first")
+	defer lfuda.Unregister("second")
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewRegistryCollector("test", "caches"))
+	if n, err := testutil.GatherAndCount(reg, "test_caches_bytes"); err != nil || n != 2 {
+		t.Errorf("every registered cache should be collected: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package lfudaprom reports lfuda cache metrics to Prometheus, either as they
+// happen through lfuda.WithMetrics:
+//
+//	c := lfuda.New(size, lfuda.WithMetrics(lfudaprom.New(prometheus.DefaultRegisterer, "myapp", "sessions")))
+//
+// or when scraped, by a Collector reading an existing cache:
+//
+//	prometheus.MustRegister(lfudaprom.NewCollector(c, "myapp", "sessions"))
+package lfudaprom
+
+import (
+	"github.com/bparli/lfuda-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics creates Prometheus collectors for a cache and registers them.
+type Metrics struct {
+	reg       prometheus.Registerer
+	namespace string
+	subsystem string
+	buckets   []float64
+}
+
+// New creates lfuda.Metrics registering collectors with reg, named
+// namespace_subsystem_name.  Counter names get a _total suffix.
+func New(reg prometheus.Registerer, namespace, subsystem string) *Metrics {
+	return &Metrics{
+		reg:       reg,
+		namespace: namespace,
+		subsystem: subsystem,
+		buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+	}
+}
+
+// WithBuckets sets the buckets of the histograms created from m.  The default
+// is powers of 2 from 1 to 32768.
+func (m *Metrics) WithBuckets(buckets []float64) *Metrics {
+	m.buckets = buckets
+	return m
+}
+
+// Counter implements lfuda.Metrics.
+func (m *Metrics) Counter(name, help string) lfuda.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Subsystem: m.subsystem,
+		Name:      name + "_total",
+		Help:      help,
+	})
+	m.reg.MustRegister(c)
+	return c
+}
+
+// Gauge implements lfuda.Metrics.
+func (m *Metrics) Gauge(name, help string) lfuda.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: m.namespace,
+		Subsystem: m.subsystem,
+		Name:      name,
+		Help:      help,
+	})
+	m.reg.MustRegister(g)
+	return g
+}
+
+// Histogram implements lfuda.Metrics.
+func (m *Metrics) Histogram(name, help string) lfuda.Histogram {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Subsystem: m.subsystem,
+		Name:      name,
+		Help:      help,
+		Buckets:   m.buckets,
+	})
+	m.reg.MustRegister(h)
+	return h
+}
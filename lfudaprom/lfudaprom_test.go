@@ -0,0 +1,28 @@
+package lfudaprom
+
+import (
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := lfuda.New(2, lfuda.WithMetrics(New(reg, "test", "cache")))
+	c.Set("a", "x")
+	c.Get("a")
+	c.Get("b")
+
+	n, err := testutil.GatherAndCount(reg, "test_cache_hits_total", "test_cache_misses_total", "test_cache_entries")
+	if err != nil || n != 3 {
+		t.Fatalf("metrics should be registered: %d, %v", n, err)
+	}
+	mfs, _ := reg.Gather()
+	for _, mf := range mfs {
+		if mf.GetName() == "test_cache_hits_total" && mf.GetMetric()[0].GetCounter().GetValue() != 1 {
+			t.Errorf("bad hits: %v", mf)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package lfuda
+
+import "github.com/bparli/lfuda-go/internal/singleflight"
+
+// ErrLoadPanicked is returned to GetOrLoad callers waiting on a load which
+// panicked.
+var ErrLoadPanicked = singleflight.ErrPanicked
+
+// GetOrLoad returns the value of key, or loads it with load and adds it if it's
+// missing.  Concurrent calls for the same missing key share a single load, so
+// a popular key expiring or being evicted doesn't send a stampede of loads to
+// the backend.  The loaded value is subject to the usual admission and never
+// replaces a value set while it was loading.  Errors are returned to every
+// caller sharing the load and nothing is cached.  If load panics the panic
+// propagates to its caller and the others get ErrLoadPanicked.
+func (c *Cache) GetOrLoad(key interface{}, load func() (interface{}, error)) (interface{}, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	return c.loads.Do(key, func() (interface{}, error) {
+		// a load may have finished since the Get
+		if value, ok := c.Peek(key); ok {
+			return value, nil
+		}
+		value, err := load()
+		if err == nil {
+			c.ContainsOrSet(key, value)
+		}
+		return value, err
+	})
+}
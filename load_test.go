@@ -0,0 +1,55 @@
+package lfuda
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	c := New(100)
+	var loads int32
+	release := make(chan struct{})
+	load := func() (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrLoad("a", load); v != "value" || err != nil {
+				t.Errorf("bad load: %v, %v", v, err)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&loads) == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	if loads != 1 || !c.Contains("a") {
+		t.Errorf("concurrent misses should share one load: %d", loads)
+	}
+	if v, err := c.GetOrLoad("a", load); v != "value" || err != nil || loads != 1 {
+		t.Errorf("hits shouldn't load: %v, %v", v, err)
+	}
+
+	errLoad := errors.New("failed")
+	if _, err := c.GetOrLoad("b", func() (interface{}, error) { return nil, errLoad }); err != errLoad || c.Contains("b") {
+		t.Errorf("errors should be returned and not cached: %v", err)
+	}
+
+	func() {
+		defer func() { recover() }()
+		c.GetOrLoad("c", func() (interface{}, error) { panic("c") })
+	}()
+	if c.loads.InFlight() != 0 {
+		t.Errorf("panicking loads should be cleaned up")
+	}
+}
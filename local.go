@@ -0,0 +1,58 @@
+package lfuda
+
+import "sync/atomic"
+
+// Local is a tiny front cache for a single goroutine, typically for the length
+// of one request, absorbing repeated reads of the same keys without touching the
+// shared cache's lock.  Any change to the shared cache's values invalidates it.
+// Repeated reads served by a Local count as a single hit in the shared cache.
+//
+// A Local must not be used by more than one goroutine at a time.
+type Local struct {
+	cache      *Cache
+	generation uint64
+	entries    []localEntry
+	next       int
+}
+
+type localEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// Local creates a front cache of c holding up to n values.
+func (c *Cache) Local(n int) *Local {
+	if n < 1 {
+		n = 1
+	}
+	return &Local{cache: c, entries: make([]localEntry, 0, n)}
+}
+
+// Get looks up a key's value, from the Local if it holds it and otherwise from
+// the shared cache.
+func (l *Local) Get(key interface{}) (value interface{}, ok bool) {
+	generation := atomic.LoadUint64(&l.cache.generation)
+	if generation != l.generation {
+		l.entries = l.entries[:0]
+		l.generation = generation
+	}
+	for _, e := range l.entries {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+
+	value, ok = l.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	// if the cache changed since loading the generation, the next Get sees
+	// the new one and clears the entry
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, localEntry{key, value})
+	} else {
+		l.entries[l.next] = localEntry{key, value}
+		l.next = (l.next + 1) % len(l.entries)
+	}
+	return value, true
+}
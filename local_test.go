@@ -0,0 +1,36 @@
+package lfuda
+
+import "testing"
+
+func TestLocal(t *testing.T) {
+	c := New(100)
+	c.Set("a", "x")
+	c.Set("b", "y")
+	c.Set("c", "z")
+	l := c.Local(2)
+
+	for i := 0; i < 3; i++ {
+		if v, ok := l.Get("a"); v != "x" || !ok {
+			t.Fatalf("bad value: %v, %v", v, ok)
+		}
+	}
+	if info, _ := c.Inspect("a"); info.Hits != 2 {
+		t.Errorf("repeated reads should count once: %f", info.Hits)
+	}
+
+	l.Get("b")
+	l.Get("c")
+	l.Get("a")
+	if info, _ := c.Inspect("a"); info.Hits != 3 {
+		t.Errorf("a should have been replaced in the Local: %f", info.Hits)
+	}
+
+	c.Set("a", "new")
+	if v, _ := l.Get("a"); v != "new" {
+		t.Errorf("writes should invalidate the Local: %v", v)
+	}
+	c.Remove("a")
+	if _, ok := l.Get("a"); ok {
+		t.Errorf("removals should invalidate the Local")
+	}
+}
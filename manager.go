@@ -0,0 +1,192 @@
+package lfuda
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bparli/lfuda-go/internal/keyhash"
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// Manager owns several named caches sharing one byte budget, moving capacity
+// from the caches which would lose the least by shrinking to those which would
+// gain the most by growing, so services with many caches don't have to size
+// each by hand.
+//
+// The gain is estimated with a shadow of each cache: the keys of its most
+// recently evicted step bytes of entries.  A Get which misses on a key in the
+// shadow would have hit had the cache been step bytes larger.  Hit curves
+// flatten as caches grow, so a cache's shadow hits are also taken as an upper
+// bound on what it would lose by shrinking.
+type Manager struct {
+	mu     sync.Mutex
+	budget float64
+	step   float64
+	caches map[string]*managed
+	// names in the order the caches were added
+	names []string
+}
+
+type managed struct {
+	cache *Cache
+	size  float64
+	// guarded by the cache's lock, as it's updated from its callbacks
+	shadow *shadow
+}
+
+// NewManager creates a Manager sharing budget bytes between its caches,
+// rebalancing step bytes at a time.
+func NewManager(budget, step float64) *Manager {
+	return &Manager{
+		budget: budget,
+		step:   step,
+		caches: make(map[string]*managed),
+	}
+}
+
+// New creates a cache managed under name with newCache, such as New or NewGDSF,
+// and the given options, then shares the budget equally between every cache.  If
+// name is already managed its cache is returned and nothing else is done.
+func (m *Manager) New(name string, newCache func(size float64, opts ...Option) *Cache, opts ...Option) *Cache {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mc, ok := m.caches[name]; ok {
+		return mc.cache
+	}
+
+	share := m.budget / float64(len(m.caches)+1)
+	// shrink the others first so the budget is never exceeded
+	for _, other := range m.names {
+		mc := m.caches[other]
+		mc.size = share
+		mc.cache.Resize(share)
+	}
+
+	mc := &managed{size: share, shadow: newShadow(m.step)}
+	opts = append(opts, func(c *config) {
+		c.onVictim = mc.shadow.add
+		c.onMiss = mc.shadow.miss
+	})
+	mc.cache = newCache(share, opts...)
+	m.caches[name] = mc
+	m.names = append(m.names, name)
+	return mc.cache
+}
+
+// Cache returns the cache managed under name.
+func (m *Manager) Cache(name string) (*Cache, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mc, ok := m.caches[name]
+	if !ok {
+		return nil, false
+	}
+	return mc.cache, true
+}
+
+// Sizes returns the bytes currently allotted to each cache.
+func (m *Manager) Sizes() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sizes := make(map[string]float64, len(m.caches))
+	for name, mc := range m.caches {
+		sizes[name] = mc.size
+	}
+	return sizes
+}
+
+// Rebalance moves step bytes to the cache whose shadow has seen the most hits
+// since the last Rebalance from the one whose shadow has seen the fewest,
+// provided the first has seen more and the second can spare them.  Returns
+// whether capacity was moved.  Call it periodically, or use Run.
+func (m *Manager) Rebalance() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var from, to *managed
+	var fromHits, toHits uint64
+	for _, name := range m.names {
+		mc := m.caches[name]
+		mc.cache.lock.Lock()
+		hits := mc.shadow.hits
+		mc.shadow.hits = 0
+		mc.cache.lock.Unlock()
+
+		if to == nil || hits > toHits {
+			to, toHits = mc, hits
+		}
+		// leave every cache at least one step
+		if mc.size >= 2*m.step && (from == nil || hits < fromHits) {
+			from, fromHits = mc, hits
+		}
+	}
+	if from == nil || from == to || toHits <= fromHits {
+		return false
+	}
+	from.size -= m.step
+	from.cache.Resize(from.size)
+	to.size += m.step
+	to.cache.Resize(to.size)
+	return true
+}
+
+// Run calls Rebalance every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Rebalance()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// shadow remembers the keys of a cache's most recently evicted entries, up to
+// limit bytes of them, and counts the misses on them.
+type shadow struct {
+	limit float64
+	size  float64
+	// evicted keys' hashes and sizes, most recently evicted at the front
+	order *list.List
+	keys  map[uint64]*list.Element
+	hits  uint64
+}
+
+type shadowEntry struct {
+	hash uint64
+	size float64
+}
+
+func newShadow(limit float64) *shadow {
+	return &shadow{limit: limit, order: list.New(), keys: make(map[uint64]*list.Element)}
+}
+
+func (s *shadow) add(v simplelfuda.Victim) {
+	h := keyhash.Sum(v.Key)
+	if _, ok := s.keys[h]; ok {
+		return
+	}
+	s.keys[h] = s.order.PushFront(shadowEntry{h, v.Size})
+	s.size += v.Size
+	for s.size > s.limit && s.order.Len() > 0 {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *shadow) miss(key interface{}) {
+	if el, ok := s.keys[keyhash.Sum(key)]; ok {
+		s.remove(el)
+		s.hits++
+	}
+}
+
+func (s *shadow) remove(el *list.Element) {
+	e := s.order.Remove(el).(shadowEntry)
+	delete(s.keys, e.hash)
+	s.size -= e.size
+}
@@ -0,0 +1,65 @@
+package lfuda
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(200, 20)
+	scans := m.New("scans", New)
+	if sizes := m.Sizes(); sizes["scans"] != 200 {
+		t.Errorf("a lone cache should get the whole budget: %v", sizes)
+	}
+	hot := m.New("hot", NewGDSF)
+	if m.New("hot", New) != hot {
+		t.Errorf("the existing cache should be returned")
+	}
+	if sizes := m.Sizes(); sizes["scans"] != 100 || sizes["hot"] != 100 {
+		t.Errorf("the budget should be shared equally: %v", sizes)
+	}
+
+	// scans cycles through 110 keys, so just misses the 10 last evicted,
+	// while hot's 10 keys fit easily
+	access := func(c *Cache, key int) {
+		if _, ok := c.Get(key); !ok {
+			c.SetWithSize(key, key, 1)
+		}
+	}
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 110; i++ {
+			access(scans, i)
+			access(hot, i%10)
+		}
+	}
+	if !m.Rebalance() {
+		t.Fatalf("capacity should have moved to scans")
+	}
+	if sizes := m.Sizes(); sizes["scans"] != 120 || sizes["hot"] != 80 {
+		t.Errorf("a step should have moved to scans: %v", sizes)
+	}
+	if m.Rebalance() {
+		t.Errorf("nothing was accessed since the last rebalance")
+	}
+
+	// now all of scans' keys fit
+	for i := 0; i < 110; i++ {
+		access(scans, i)
+	}
+	if scans.Len() != 110 {
+		t.Errorf("scans should hold every key: %d", scans.Len())
+	}
+	if c, ok := m.Cache("hot"); !ok || c != hot {
+		t.Errorf("hot should be managed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	cancel()
+	<-done
+}
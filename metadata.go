@@ -0,0 +1,151 @@
+package lfuda
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// metadataMagic starts every metadata snapshot, versioning the format.
+var metadataMagic = []byte("lfm\x01")
+
+// maxMetadataKey is the longest encoded key ReadMetadata accepts, so a corrupt
+// length can't make it allocate without bound.
+const maxMetadataKey = 1 << 20
+
+// WriteMetadata writes the key, size and hits of every entry, highest priority
+// first, to w in a compact binary format, leaving out the values.  Keys are
+// encoded with keys, such as StringCodec.  A restarting or newly deployed
+// instance can read the snapshot with ReadMetadata and restore it with
+// RestoreMetadata to start out with realistic frequencies, fetching the values
+// themselves as they're needed.
+func (c *Cache) WriteMetadata(w io.Writer, keys Codec) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(metadataMagic); err != nil {
+		return err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	writeUvarint := func(x uint64) error {
+		_, err := bw.Write(buf[:binary.PutUvarint(buf[:], x)])
+		return err
+	}
+	for _, info := range c.Entries() {
+		key, err := keys.Marshal(info.Key)
+		if err != nil {
+			return err
+		}
+		if err := writeUvarint(uint64(len(key))); err != nil {
+			return err
+		}
+		if _, err := bw.Write(key); err != nil {
+			return err
+		}
+		if err := writeUvarint(packFloat(info.Size)); err != nil {
+			return err
+		}
+		if err := writeUvarint(packFloat(info.Hits)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadMetadata reads a snapshot written by WriteMetadata, decoding keys with
+// keys.  Only the Key, Size and Hits of the entries are set.  Encoded keys over
+// 1MiB are reported as corrupt.
+func ReadMetadata(r io.Reader, keys Codec) (entries []simplelfuda.EntryInfo, err error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(metadataMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != string(metadataMagic) {
+		return nil, errors.New("lfuda: not a metadata snapshot")
+	}
+	for {
+		n, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if n > maxMetadataKey {
+			return nil, errors.New("lfuda: metadata key too long")
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, truncated(err)
+		}
+		key, err := keys.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, truncated(err)
+		}
+		hits, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, truncated(err)
+		}
+		entries = append(entries, simplelfuda.EntryInfo{Key: key, Size: unpackFloat(size), Hits: unpackFloat(hits)})
+	}
+}
+
+// RestoreMetadata restores the hits of entries read with ReadMetadata.  Hits of
+// keys already in the cache are added straight away.  The rest are held until
+// their keys are set, by the caller after a miss or by the loader, and then
+// added, so values are fetched lazily rather than all at startup.  If the cache
+// was created WithLoader a Get which misses on a held key prefetches it.  Purge
+// drops the held entries.
+func (c *Cache) RestoreMetadata(entries []simplelfuda.EntryInfo) {
+	c.writeLock("RestoreMetadata", nil)
+	if c.restored == nil {
+		c.restored = make(map[interface{}]float64, len(entries))
+	}
+	for _, info := range entries {
+		if c.lfuda.AddHits(info.Key, info.Hits) {
+			continue
+		}
+		c.restored[info.Key] += info.Hits
+	}
+	c.publish()
+	c.writeUnlock()
+}
+
+// adoptRestored adds the restored hits held for a key which has just been set,
+// less the hit counted by setting it.  Must be called with the write lock held.
+func (c *Cache) adoptRestored(key interface{}) {
+	hits, ok := c.restored[key]
+	if !ok || !c.lfuda.Contains(key) {
+		return
+	}
+	delete(c.restored, key)
+	if hits > 1 {
+		c.lfuda.AddHits(key, hits-1)
+	}
+}
+
+// truncated reports an EOF part way through an entry as unexpected.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// packFloat reverses the bytes of f's bits so the zero low bytes of the mantissa
+// of whole numbers like sizes and hit counts become high bytes, which a uvarint
+// leaves out.
+func packFloat(f float64) uint64 {
+	return bits.ReverseBytes64(math.Float64bits(f))
+}
+
+func unpackFloat(x uint64) float64 {
+	return math.Float64frombits(bits.ReverseBytes64(x))
+}
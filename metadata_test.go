@@ -0,0 +1,102 @@
+package lfuda
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	c := New(100)
+	c.Set("a", "aaaa")
+	c.Set("b", "b")
+	c.SetWithSize("c", "c", 2.5)
+	for i := 0; i < 3; i++ {
+		c.Get("a")
+	}
+	c.Get("c")
+
+	var buf bytes.Buffer
+	if err := c.WriteMetadata(&buf, StringCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	// a length, a single byte key and two floats of at most 3 bytes each
+	if buf.Len() > len(metadataMagic)+3*8 {
+		t.Errorf("snapshot should be compact: %d bytes", buf.Len())
+	}
+	entries, err := ReadMetadata(bytes.NewReader(buf.Bytes()), StringCodec{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("bad read: %v, %v", entries, err)
+	}
+	if e := entries[0]; e.Key != "a" || e.Size != 4 || e.Hits != 4 {
+		t.Errorf("bad entry: %+v", e)
+	}
+	if e := entries[1]; e.Key != "c" || e.Size != 2.5 || e.Hits != 2 {
+		t.Errorf("bad entry: %+v", e)
+	}
+	if _, err := ReadMetadata(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), StringCodec{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated snapshots should be reported: %v", err)
+	}
+	if _, err := ReadMetadata(bytes.NewReader([]byte("nope")), StringCodec{}); err == nil {
+		t.Errorf("other data should be rejected")
+	}
+	huge := append(append([]byte{}, metadataMagic...), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f)
+	if _, err := ReadMetadata(bytes.NewReader(huge), StringCodec{}); err == nil || err == io.ErrUnexpectedEOF {
+		t.Errorf("corrupt key lengths should be rejected: %v", err)
+	}
+
+	loaded := make(chan interface{}, 1)
+	fresh := New(100, WithLoader(func(key interface{}) (interface{}, error) {
+		loaded <- key
+		return "loaded", nil
+	}))
+	fresh.Set("b", "b")
+	fresh.RestoreMetadata(entries)
+	if info, _ := fresh.Inspect("b"); info.Hits != 2 || fresh.Len() != 1 {
+		t.Errorf("hits of present keys should be added straight away: %+v", info)
+	}
+
+	// a is loaded when it's first missed, c when the caller sets it
+	if _, ok := fresh.Get("a"); ok {
+		t.Errorf("values shouldn't be restored")
+	}
+	select {
+	case key := <-loaded:
+		if key != "a" {
+			t.Errorf("loaded the wrong key: %v", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("a miss should load the value")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !fresh.Contains("a") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if info, _ := fresh.Inspect("a"); info.Hits != 4 {
+		t.Errorf("a's hits should be restored once loaded: %+v", info)
+	}
+	fresh.Set("c", "c")
+	if info, _ := fresh.Inspect("c"); info.Hits != 2 {
+		t.Errorf("c's hits should be restored once set: %+v", info)
+	}
+	if len(fresh.restored) != 0 {
+		t.Errorf("restored hits should only be added once: %v", fresh.restored)
+	}
+}
+
+func FuzzReadMetadata(f *testing.F) {
+	c := New(100)
+	c.Set("a", "aaaa")
+	c.Set("b", "b")
+	var buf bytes.Buffer
+	if err := c.WriteMetadata(&buf, StringCodec{}); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+	f.Add(append(append([]byte{}, metadataMagic...), 0xff, 0xff, 0xff, 0xff, 0x0f))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// corrupt snapshots should be reported, not panic or exhaust memory
+		ReadMetadata(bytes.NewReader(data), StringCodec{})
+	})
+}
@@ -0,0 +1,118 @@
+package lfuda
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bparli/lfuda-go/accesslog"
+)
+
+type testMetrics struct {
+	mu     sync.Mutex
+	values map[string]float64
+	counts map[string]int
+}
+
+type testInstrument struct {
+	m    *testMetrics
+	name string
+}
+
+func (i testInstrument) Add(delta float64) {
+	i.m.mu.Lock()
+	i.m.values[i.name] += delta
+	i.m.mu.Unlock()
+}
+
+func (i testInstrument) Set(value float64) {
+	i.m.mu.Lock()
+	i.m.values[i.name] = value
+	i.m.mu.Unlock()
+}
+
+func (i testInstrument) Observe(value float64) {
+	i.m.mu.Lock()
+	i.m.values[i.name] += value
+	i.m.counts[i.name]++
+	i.m.mu.Unlock()
+}
+
+func (m *testMetrics) Counter(name, help string) Counter     { return testInstrument{m, name} }
+func (m *testMetrics) Gauge(name, help string) Gauge         { return testInstrument{m, name} }
+func (m *testMetrics) Histogram(name, help string) Histogram { return testInstrument{m, name} }
+
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{values: make(map[string]float64), counts: make(map[string]int)}
+	c := New(3, WithMetrics(m))
+	c.Set("a", "x")
+	c.Set("b", "x")
+	c.Get("a")
+	c.Get("a")
+	c.Get("z")
+	c.Set("c", "x")
+	c.Set("d", "x")
+	c.Remove("a")
+
+	want := map[string]float64{
+		"hits":             2,
+		"misses":           1,
+		"evictions":        1,
+		"entries":          2,
+		"bytes":            2,
+		"age":              1,
+		"evicted_priority": 1,
+	}
+	for name, v := range want {
+		if m.values[name] != v {
+			t.Errorf("%s should be %v: %v", name, v, m.values[name])
+		}
+	}
+	if m.counts["evicted_priority"] != 1 {
+		t.Errorf("removals should not be observed as evictions")
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Counter(name, help string) Counter     { return nopMetrics{} }
+func (nopMetrics) Gauge(name, help string) Gauge         { return nopMetrics{} }
+func (nopMetrics) Histogram(name, help string) Histogram { return nopMetrics{} }
+func (nopMetrics) Add(float64)                           {}
+func (nopMetrics) Set(float64)                           {}
+func (nopMetrics) Observe(float64)                       {}
+
+// benchmarkInstrumentation runs the same mixed workload as BenchmarkLFUDA, to
+// compare a bare cache with one with every hook registered.
+func benchmarkInstrumentation(b *testing.B, opts ...Option) {
+	l := New(8192, opts...)
+	trace := make([]int64, b.N)
+	for i := range trace {
+		trace[i] = rand.Int63() % 16384
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			l.Set(trace[i], trace[i])
+		} else {
+			l.Get(trace[i-1])
+		}
+	}
+}
+
+func BenchmarkBare(b *testing.B) {
+	benchmarkInstrumentation(b)
+}
+
+func BenchmarkInstrumented(b *testing.B) {
+	benchmarkInstrumentation(b,
+		WithMetrics(nopMetrics{}),
+		WithErrorHook(func(error) {}),
+		WithLockGuard(time.Minute),
+		WithSlowOpHook(time.Minute, func(SlowOp) {}),
+		WithAccessLog(accesslog.NewWriter(ioutil.Discard)),
+	)
+}
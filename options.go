@@ -0,0 +1,307 @@
+package lfuda
+
+import (
+	"time"
+
+	"github.com/bparli/lfuda-go/accesslog"
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// Option configures a Cache.
+type Option func(*config)
+
+type config struct {
+	// options passed through to the underlying simplelfuda cache
+	lfuda []simplelfuda.Option
+
+	metrics Metrics
+	// set by Split to share a cache's instruments with its parts, rather than
+	// registering the same metrics again
+	instruments *instruments
+
+	onError       func(error)
+	lockThreshold time.Duration
+	loader        Loader
+	accessLog     *accesslog.Writer
+	slowThreshold time.Duration
+	slowHook      func(SlowOp)
+	hooks         Hooks
+	evictions     chan<- Eviction
+
+	// only used by NewWithOptions
+	policy     Policy
+	onEvicted  func(key interface{}, value interface{})
+	size       float64
+	maxEntries int
+
+	// set by a Manager to estimate the cache's marginal gain
+	onVictim func(simplelfuda.Victim)
+	onMiss   func(key interface{})
+}
+
+// Policy names a cache policy, selected with WithPolicy.
+type Policy string
+
+const (
+	// PolicyLFUDA is LFU with dynamic aging, as created by New
+	PolicyLFUDA Policy = "LFUDA"
+	// PolicyGDSF is Greedy Dual Size Frequency, as created by NewGDSF
+	PolicyGDSF Policy = "GDSF"
+	// PolicyLFU is plain LFU, as created by NewLFU
+	PolicyLFU Policy = "LFU"
+	// PolicyLRU is LRU, as created by NewLRU
+	PolicyLRU Policy = "LRU"
+	// PolicyMRU is MRU, as created by NewMRU
+	PolicyMRU Policy = "MRU"
+)
+
+// WithPolicy sets the policy of a cache created with NewWithOptions.  The other
+// constructors choose their policy themselves and ignore it.
+func WithPolicy(policy Policy) Option {
+	return func(c *config) {
+		c.policy = policy
+	}
+}
+
+// WithEvictCallback sets the eviction callback of a cache created with
+// NewWithOptions.  The other constructors ignore it.
+func WithEvictCallback(onEvicted func(key interface{}, value interface{})) Option {
+	return func(c *config) {
+		c.onEvicted = onEvicted
+	}
+}
+
+// Eviction is an entry removed from a cache, as sent by WithEvictionChannel.
+type Eviction struct {
+	Key, Value interface{}
+}
+
+// WithEvictionChannel sends each entry passed to the eviction callback to ch,
+// so slow handling of evictions can run on the caller's goroutines, as many as
+// it likes reading ch, rather than with the cache locked.  Evictions are queued
+// while the cache is locked and sent once it's unlocked, by the goroutine whose
+// operation caused them, so a full ch only holds up that operation and readers
+// of ch may use the cache.  Any eviction callback is still called, with the
+// cache locked, before the eviction is queued.
+func WithEvictionChannel(ch chan<- Eviction) Option {
+	return func(c *config) {
+		c.evictions = ch
+	}
+}
+
+// WithSizeBytes sizes a cache created with NewWithOptions to hold size bytes.
+// The other constructors take their size as an argument and ignore it.
+func WithSizeBytes(size float64) Option {
+	return func(c *config) {
+		c.size = size
+	}
+}
+
+// WithDefaultTTL makes entries set without a TTL of their own expire after ttl,
+// so every Set gets an expiry without changing its call sites.  Expired entries
+// are skipped by Get, Peek and Contains, and their space reclaimed by Get,
+// RemoveExpired and RunJanitor, or by setting their keys again.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithDefaultTTL(ttl))
+	}
+}
+
+// WithTTLWeight lowers the priority of entries close to expiring by up to
+// weight, in proportion to how much of horizon their TTL has used up when they
+// are set or hit, so they are evicted before hot entries which will live on.
+func WithTTLWeight(weight float64, horizon time.Duration) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithTTLWeight(weight, horizon))
+	}
+}
+
+// WithSizer sizes values with fn rather than the default calculation, for
+// values whose formatted size says little about the memory they hold.  Values
+// implementing simplelfuda.Sizer size themselves unless fn is given.
+func WithSizer(fn func(key, value interface{}) float64) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithSizer(fn))
+	}
+}
+
+// WithMaxEntries caps a cache at n entries.  Alongside WithSizeBytes, or with
+// the other constructors, entries are evicted whenever either the byte budget or
+// the cap would be exceeded.  Alone, with NewWithOptions, the cache holds n
+// entries whatever the size of their values, which then aren't sized at all.
+func WithMaxEntries(n int) Option {
+	return func(c *config) {
+		c.maxEntries = n
+	}
+}
+
+// WithEntryCounting makes the cache's size a number of entries rather than
+// bytes, with any constructor: every entry counts as 1 whatever its value or an
+// explicit size, and values are never sized.  New(1000, WithEntryCounting())
+// holds at most 1000 entries.
+func WithEntryCounting() Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithEntryCounting())
+	}
+}
+
+// WithScanResistance makes an entry's first Get after insertion not increment
+// its hits, so one-pass scans don't inflate priorities and push out entries
+// which are genuinely reused.
+func WithScanResistance() Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithScanResistance())
+	}
+}
+
+// WithProbabilisticHits makes Gets of keys whose priority is more than threshold
+// above the cache age count probabilistically, with the same expected hits, so
+// a viral key doesn't reshuffle the frequency structure on every access.
+func WithProbabilisticHits(threshold float64) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithProbabilisticHits(threshold))
+	}
+}
+
+// WithLogCounters replaces exact hit counts with approximate logarithmic (Morris)
+// counters in the given base, bounding how far long-lived hot keys can pull
+// ahead of the cache age.  base must be greater than 1.
+func WithLogCounters(base float64) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithLogCounters(base))
+	}
+}
+
+// WithAgeHistory records the last n aging events, readable with AgeHistory, so
+// sudden jumps in age can be diagnosed after the fact.
+func WithAgeHistory(n int) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithAgeHistory(n))
+	}
+}
+
+// WithResidencyHistogram records how long each evicted entry was resident in a
+// histogram with the given bucket bounds, or simplelfuda.DefaultResidencyBounds,
+// readable with Residency.
+func WithResidencyHistogram(bounds ...time.Duration) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithResidencyHistogram(bounds...))
+	}
+}
+
+// WithErrorHook recovers panics in the eviction callback, passing them to fn as
+// *simplelfuda.CallbackPanic errors, so one buggy callback can't take down the
+// process or leave the cache locked.  fn is also passed errors detected by
+// WithLockGuard.
+func WithErrorHook(fn func(error)) Option {
+	return func(c *config) {
+		c.onError = fn
+		c.lfuda = append(c.lfuda, simplelfuda.WithErrorHook(fn))
+	}
+}
+
+// WithAccessLog records every Get, and every Set which stores its value, to w
+// for offline analysis.  Records are written with the cache's lock held, so w
+// is buffered; the caller flushes it.
+func WithAccessLog(w *accesslog.Writer) Option {
+	return func(c *config) {
+		c.accessLog = w
+	}
+}
+
+// WithSizeUnit makes GDSF divide hits by sizes in the given unit, such as 1024
+// for KB, rather than bytes, so the frequency term of large entries isn't
+// swamped by the cache age.  Has no effect on other policies.
+func WithSizeUnit(unit float64) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithSizeUnit(unit))
+	}
+}
+
+// WithSizeNormalization makes GDSF divide hits by fn(size) rather than size.
+// Has no effect on other policies.
+func WithSizeNormalization(fn func(size float64) float64) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithSizeNormalization(fn))
+	}
+}
+
+// WithPriorityBuckets rounds priorities down to multiples of width, bounding the
+// length of the frequency list under GDSF's near-unique priorities at the cost
+// of a coarser eviction order.
+func WithPriorityBuckets(width float64) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithPriorityBuckets(width))
+	}
+}
+
+// WithExpectedEntries presizes the cache's index for n entries, avoiding
+// repeated growth while a large cache warms up.
+func WithExpectedEntries(n int) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithExpectedEntries(n))
+	}
+}
+
+// WithAgeObserver calls fn whenever the cache age increases, with the old and new
+// ages and the victim whose eviction raised it, so unexpected age spikes which
+// change the admission of every later Set can be seen.  fn is called with the
+// cache locked and must not call back into the cache.
+func WithAgeObserver(fn func(simplelfuda.AgeEvent, simplelfuda.Victim)) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithAgeObserver(fn))
+	}
+}
+
+// WithEvictionVeto asks fn about each entry chosen to be evicted to make room,
+// sparing it and choosing the next candidate if fn returns true, up to maxVetoes
+// times per Set.  fn is called with the cache locked and must not call back into
+// the cache.
+func WithEvictionVeto(fn func(simplelfuda.Victim) bool, maxVetoes int) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithEvictionVeto(fn, maxVetoes))
+	}
+}
+
+// WithRejectCallback calls fn with the key, value and reason of each Set which
+// is refused, as values larger than the whole cache are.  fn is called with the
+// cache locked and must not call back into the cache.
+func WithRejectCallback(fn func(key, value interface{}, reason simplelfuda.RejectReason)) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithRejectCallback(fn))
+	}
+}
+
+// WithSecondChance spares each entry the first time it's chosen to be evicted to
+// make room, lowering its priority by a hit's worth instead, so borderline hot
+// entries are only evicted if chosen again.
+func WithSecondChance() Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithSecondChance())
+	}
+}
+
+// WithHitWindow counts only the hits of the last epochs periods of the given
+// length, so priorities reflect recent demand rather than all-time popularity.
+func WithHitWindow(epoch time.Duration, epochs int) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithHitWindow(epoch, epochs))
+	}
+}
+
+// WithStatsWindow keeps the Stats counters at the start of each interval of
+// the given length over the last span, so WindowStats can report hit ratios
+// over recent periods such as the last minute or hour.
+func WithStatsWindow(interval, span time.Duration) Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithStatsWindow(interval, span))
+	}
+}
+
+// WithResetOnSet makes setting a key already in the cache replace the entry as if
+// it were new, resetting its hits, rather than counting the set as a hit.
+func WithResetOnSet() Option {
+	return func(c *config) {
+		c.lfuda = append(c.lfuda, simplelfuda.WithResetOnSet())
+	}
+}
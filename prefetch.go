@@ -0,0 +1,65 @@
+package lfuda
+
+import "sync"
+
+// Loader loads the value of a key missing from the cache.
+type Loader func(key interface{}) (interface{}, error)
+
+// WithLoader sets the loader used by Prefetch.  Errors it returns are passed to
+// the error hook, if any.
+func WithLoader(load Loader) Option {
+	return func(c *config) {
+		c.loader = load
+	}
+}
+
+// prefetcher tracks the keys being prefetched so each is only loaded once at a
+// time.
+type prefetcher struct {
+	load    Loader
+	onError func(error)
+
+	mu       sync.Mutex
+	inFlight map[interface{}]struct{}
+}
+
+// Prefetch asynchronously loads whichever of keys are missing from the cache
+// with the loader and adds them, so predictable upcoming traffic finds them
+// already cached.  Loaded values are subject to the usual admission and never
+// replace a value set while they were loading.  Does nothing unless the cache
+// was created WithLoader.
+func (c *Cache) Prefetch(keys []interface{}) {
+	p := c.prefetch
+	if p == nil {
+		return
+	}
+	var missing []interface{}
+	present := c.ContainsMulti(keys)
+	p.mu.Lock()
+	for i, key := range keys {
+		if _, ok := p.inFlight[key]; !ok && !present[i] {
+			p.inFlight[key] = struct{}{}
+			missing = append(missing, key)
+		}
+	}
+	p.mu.Unlock()
+	if len(missing) == 0 {
+		return
+	}
+
+	go func() {
+		for _, key := range missing {
+			value, err := p.load(key)
+			if err != nil {
+				if p.onError != nil {
+					p.onError(err)
+				}
+			} else {
+				c.ContainsOrSet(key, value)
+			}
+			p.mu.Lock()
+			delete(p.inFlight, key)
+			p.mu.Unlock()
+		}
+	}()
+}
@@ -0,0 +1,46 @@
+package lfuda
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+	var mu sync.Mutex
+	loads := make(map[interface{}]int)
+	errs := make(chan error, 1)
+	c := New(100, WithLoader(func(key interface{}) (interface{}, error) {
+		mu.Lock()
+		loads[key]++
+		mu.Unlock()
+		if key == "bad" {
+			return nil, errors.New("boom")
+		}
+		return key.(string) + "!", nil
+	}), WithErrorHook(func(err error) { errs <- err }))
+	c.Set("a", "cached")
+
+	c.Prefetch([]interface{}{"a", "b", "c", "bad"})
+	select {
+	case err := <-errs:
+		if err.Error() != "boom" {
+			t.Errorf("bad error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("load error should be passed to the error hook")
+	}
+
+	// keys are loaded in order, so b and c are done
+	if v, _ := c.Peek("b"); v != "b!" || !c.Contains("c") || c.Contains("bad") {
+		t.Errorf("missing keys should have been loaded: %v", v)
+	}
+	mu.Lock()
+	if v, _ := c.Peek("a"); v != "cached" || loads["a"] != 0 || loads["b"] != 1 {
+		t.Errorf("cached keys should not be loaded")
+	}
+	mu.Unlock()
+
+	New(100).Prefetch([]interface{}{"a"})
+}
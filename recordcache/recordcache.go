@@ -0,0 +1,127 @@
+// Package recordcache adapts lfuda for records which carry their own TTL from
+// their source, such as DNS answers or service discovery lookups.
+//
+// Each record expires once its TTL has passed, independently of the LFUDA
+// capacity eviction which keeps the most valuable records when the cache is
+// full.  Expired records are dropped lazily when they are next looked up.
+package recordcache
+
+import (
+	"time"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/bparli/lfuda-go/internal/singleflight"
+)
+
+// Cache caches records with per-record TTLs.
+type Cache struct {
+	cache *lfuda.Cache
+	now   func() time.Time
+	calls singleflight.Group
+}
+
+type record struct {
+	value   interface{}
+	expires int64
+}
+
+// ErrFetchPanicked is returned to Lookups waiting on a fetch which panicked.
+var ErrFetchPanicked = singleflight.ErrPanicked
+
+// result of a Lookup fetch shared by concurrent callers
+type fetched struct {
+	value interface{}
+	ttl   time.Duration
+}
+
+// New creates a Cache of the given size in bytes using the LFUDA policy.
+func New(size float64) *Cache {
+	return NewWithCache(lfuda.New(size))
+}
+
+// NewWithCache creates a Cache storing records in the given cache.
+func NewWithCache(cache *lfuda.Cache) *Cache {
+	return &Cache{
+		cache: cache,
+		now:   time.Now,
+	}
+}
+
+// Set adds a record which expires after ttl.  Records with a non-positive TTL
+// are not cached.  Returns true if an eviction occurred.
+func (c *Cache) Set(key, value interface{}, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	return c.cache.Set(key, record{
+		value:   value,
+		expires: c.now().Add(ttl).UnixNano(),
+	})
+}
+
+// Store adds a record which expires after ttl like Set, but returns whether it
+// was stored.  Records with a non-positive TTL and those larger than the whole
+// cache aren't.
+func (c *Cache) Store(key, value interface{}, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	set, _ := c.cache.SetWithVictims(key, record{
+		value:   value,
+		expires: c.now().Add(ttl).UnixNano(),
+	})
+	return set
+}
+
+// Get looks up a record, returning its value and remaining TTL.
+func (c *Cache) Get(key interface{}) (value interface{}, ttl time.Duration, ok bool) {
+	stored, ok := c.cache.Get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	rec := stored.(record)
+	if ttl = time.Duration(rec.expires - c.now().UnixNano()); ttl <= 0 {
+		c.cache.Remove(key)
+		return nil, 0, false
+	}
+	return rec.value, ttl, true
+}
+
+// Lookup returns the cached record for key, or fetches and caches it with the
+// TTL returned by fetch.  Concurrent lookups of the same missing key share a
+// single fetch.  If fetch panics the panic propagates to the Lookup which
+// called it, and the others get ErrFetchPanicked.
+func (c *Cache) Lookup(key interface{}, fetch func() (value interface{}, ttl time.Duration, err error)) (interface{}, time.Duration, error) {
+	if value, ttl, ok := c.Get(key); ok {
+		return value, ttl, nil
+	}
+
+	res, err := c.calls.Do(key, func() (interface{}, error) {
+		value, ttl, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.Set(key, value, ttl)
+		return fetched{value, ttl}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	f := res.(fetched)
+	return f.value, f.ttl, nil
+}
+
+// Remove removes a record from the cache.
+func (c *Cache) Remove(key interface{}) bool {
+	return c.cache.Remove(key)
+}
+
+// Purge removes every record from the cache.
+func (c *Cache) Purge() {
+	c.cache.Purge()
+}
+
+// Cache returns the underlying lfuda cache.
+func (c *Cache) Cache() *lfuda.Cache {
+	return c.cache
+}
@@ -0,0 +1,108 @@
+package recordcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecordTTL(t *testing.T) {
+	now := time.Now()
+	c := New(1000)
+	c.now = func() time.Time { return now }
+
+	c.Set("a.example.com", "10.0.0.1", 30*time.Second)
+	if v, ttl, ok := c.Get("a.example.com"); !ok || v != "10.0.0.1" || ttl != 30*time.Second {
+		t.Errorf("bad record: %v, %v, %v", v, ttl, ok)
+	}
+
+	now = now.Add(10 * time.Second)
+	if _, ttl, ok := c.Get("a.example.com"); !ok || ttl != 20*time.Second {
+		t.Errorf("remaining TTL should count down: %v, %v", ttl, ok)
+	}
+
+	now = now.Add(20 * time.Second)
+	if _, _, ok := c.Get("a.example.com"); ok {
+		t.Errorf("record should have expired")
+	}
+	if c.Cache().Len() != 0 {
+		t.Errorf("expired record should have been removed")
+	}
+
+	if c.Set("b.example.com", "10.0.0.2", 0) || c.Cache().Contains("b.example.com") {
+		t.Errorf("records without a TTL should not be cached")
+	}
+	if !c.Store("c.example.com", "10.0.0.3", time.Second) || c.Store("d.example.com", make([]byte, 2000), time.Second) {
+		t.Errorf("Store should report whether the record was stored")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c := New(1000)
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func() (interface{}, time.Duration, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "10.0.0.1", time.Minute, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, _, err := c.Lookup("a", fetch); err != nil || v != "10.0.0.1" {
+				t.Errorf("bad lookup: %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("concurrent lookups should share a fetch: %d", n)
+	}
+	if _, _, ok := c.Get("a"); !ok {
+		t.Errorf("fetched record should be cached")
+	}
+}
+
+func TestLookupError(t *testing.T) {
+	c := New(1000)
+	fail := errors.New("nxdomain")
+	if _, _, err := c.Lookup("a", func() (interface{}, time.Duration, error) { return nil, 0, fail }); err != fail {
+		t.Errorf("fetch errors should be returned: %v", err)
+	}
+	if c.Cache().Len() != 0 {
+		t.Errorf("failed fetches should not be cached")
+	}
+}
+
+func TestLookupPanic(t *testing.T) {
+	c := New(1000)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("the fetch's panic should propagate")
+			}
+		}()
+		c.Lookup("a", func() (interface{}, time.Duration, error) { panic("a") })
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, _, err := c.Lookup("a", func() (interface{}, time.Duration, error) { return "b", time.Minute, nil }); v != "b" || err != nil {
+			t.Errorf("bad lookup: %v, %v", v, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("lookups after a panicking fetch shouldn't block")
+	}
+}
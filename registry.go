@@ -0,0 +1,44 @@
+package lfuda
+
+import "sync"
+
+// the process-wide registry of named caches
+var registry = struct {
+	sync.RWMutex
+	caches map[string]*Cache
+}{caches: make(map[string]*Cache)}
+
+// Register adds a cache to the process-wide registry under name, replacing any
+// cache already registered under it, so exporters and admin handlers can find
+// and report on every cache in the process without being handed each one.
+func Register(name string, c *Cache) {
+	registry.Lock()
+	registry.caches[name] = c
+	registry.Unlock()
+}
+
+// Unregister removes the cache registered under name.
+func Unregister(name string) {
+	registry.Lock()
+	delete(registry.caches, name)
+	registry.Unlock()
+}
+
+// Lookup returns the cache registered under name.
+func Lookup(name string) (c *Cache, ok bool) {
+	registry.RLock()
+	c, ok = registry.caches[name]
+	registry.RUnlock()
+	return c, ok
+}
+
+// Registered returns every registered cache by name.
+func Registered() map[string]*Cache {
+	registry.RLock()
+	caches := make(map[string]*Cache, len(registry.caches))
+	for name, c := range registry.caches {
+		caches[name] = c
+	}
+	registry.RUnlock()
+	return caches
+}
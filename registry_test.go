@@ -0,0 +1,32 @@
+package lfuda
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	a, b := New(10), New(10)
+	Register("a", a)
+	Register("b", b)
+	defer Unregister("b")
+
+	if c, ok := Lookup("a"); !ok || c != a {
+		t.Errorf("a should be registered")
+	}
+	caches := Registered()
+	if caches["a"] != a || caches["b"] != b {
+		t.Errorf("both caches should be registered: %v", caches)
+	}
+	// the result is a copy
+	delete(caches, "a")
+
+	Register("a", b)
+	if c, _ := Lookup("a"); c != b {
+		t.Errorf("registering again should replace the cache")
+	}
+	Unregister("a")
+	if _, ok := Lookup("a"); ok {
+		t.Errorf("a should have been unregistered")
+	}
+	if _, ok := Registered()["b"]; !ok {
+		t.Errorf("b should still be registered")
+	}
+}
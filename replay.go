@@ -0,0 +1,92 @@
+package lfuda
+
+import (
+	"io"
+
+	"github.com/bparli/lfuda-go/accesslog"
+)
+
+// Replay replays an access log recorded WithAccessLog into the cache, typically
+// a fresh one at startup, so it starts out with realistic frequencies.  The log
+// only holds key hashes, so resolve maps them back to keys, reporting false for
+// keys no longer of interest, whose records are skipped; accesslog.Hash hashes
+// keys for building an index.
+//
+// Set records, and hits of keys not yet in the cache, add the key with its value
+// from the loader.  Load errors are passed to the error hook, if any, and the
+// key skipped.  Other hits are added to the key's hits.  In metadata only mode,
+// or without a loader, values aren't loaded: the hits of keys not in the cache
+// are held as RestoreMetadata holds them, so Gets still miss, prefetching the key
+// if the cache has a loader, and the hits are added once the key is set.
+// Replayed records aren't logged to the access log or reported to hooks again.
+// Returns the number of records replayed.
+func (c *Cache) Replay(r *accesslog.Reader, resolve func(hash uint64) (key interface{}, ok bool), metadataOnly bool) (n int, err error) {
+	var load Loader
+	var onError func(error)
+	if p := c.prefetch; p != nil && !metadataOnly {
+		load, onError = p.load, p.onError
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if rec.Op == accesslog.Miss {
+			continue
+		}
+		key, ok := resolve(rec.KeyHash)
+		if !ok {
+			continue
+		}
+		n++
+
+		if rec.Op == accesslog.Hit && c.replayHit(key) {
+			continue
+		}
+		if load == nil {
+			c.holdHit(key)
+			continue
+		}
+		value, err := load(key)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		c.replaySet(key, value)
+	}
+}
+
+// replayHit adds a replayed hit to a key in the cache, returning false if it
+// isn't.
+func (c *Cache) replayHit(key interface{}) bool {
+	c.writeLock("Replay", key)
+	ok := c.lfuda.AddHits(key, 1)
+	if ok {
+		c.publishHits()
+	}
+	c.writeUnlock()
+	return ok
+}
+
+// holdHit holds a replayed hit for a key not in the cache until it is set.
+func (c *Cache) holdHit(key interface{}) {
+	c.writeLock("Replay", key)
+	if c.restored == nil {
+		c.restored = make(map[interface{}]float64)
+	}
+	c.restored[key]++
+	c.writeUnlock()
+}
+
+// replaySet sets a key's loaded value, adding any hits held for it.
+func (c *Cache) replaySet(key, value interface{}) {
+	c.writeLock("Replay", key)
+	c.lfuda.Set(key, value)
+	c.adoptRestored(key)
+	c.publish()
+	c.writeUnlock()
+}
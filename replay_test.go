@@ -0,0 +1,85 @@
+package lfuda
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/bparli/lfuda-go/accesslog"
+)
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	w := accesslog.NewWriter(&buf)
+	c := New(100, WithAccessLog(w))
+	c.Set("a", "aaa")
+	c.Set("b", "b")
+	c.Set("gone", "x")
+	for i := 0; i < 3; i++ {
+		c.Get("a")
+	}
+	c.Get("missing")
+	w.Flush()
+	log := buf.Bytes()
+
+	index := make(map[uint64]interface{})
+	for _, key := range []interface{}{"a", "b", "bad"} {
+		index[accesslog.Hash(key)] = key
+	}
+	resolve := func(hash uint64) (interface{}, bool) {
+		key, ok := index[hash]
+		return key, ok
+	}
+
+	loads := 0
+	var errs []error
+	fresh := New(100, WithLoader(func(key interface{}) (interface{}, error) {
+		loads++
+		if key == "b" {
+			return nil, errors.New("boom")
+		}
+		return "loaded", nil
+	}), WithErrorHook(func(err error) { errs = append(errs, err) }))
+	n, err := fresh.Replay(accesslog.NewReader(bytes.NewReader(log)), resolve, false)
+	if err != nil || n != 5 || loads != 2 || len(errs) != 1 {
+		t.Fatalf("bad replay: %d, %v, %d loads", n, err, loads)
+	}
+	if info, _ := fresh.Inspect("a"); info.Hits != 4 {
+		t.Errorf("hits should be replayed: %+v", info)
+	}
+	if v, _ := fresh.Peek("a"); v != "loaded" || fresh.Contains("b") || fresh.Contains("gone") {
+		t.Errorf("values should come from the loader")
+	}
+
+	var logged bytes.Buffer
+	metaLog := accesslog.NewWriter(&logged)
+	hooks := &recordingHooks{}
+	meta := New(100, WithAccessLog(metaLog), WithHooks(hooks))
+	meta.Replay(accesslog.NewReader(bytes.NewReader(log)), resolve, true)
+	if _, ok := meta.Get("a"); ok || meta.Len() != 0 {
+		t.Errorf("metadata only replay shouldn't add entries without values")
+	}
+	meta.Set("a", "aaa")
+	if info, _ := meta.Inspect("a"); info.Hits != 4 {
+		t.Errorf("replayed hits should be added once the key is set: %+v", info)
+	}
+	metaLog.Flush()
+	if ops := countRecords(t, logged.Bytes()); ops != 2 || len(hooks.hits)+len(hooks.misses) != 1 || len(hooks.sets) != 1 {
+		t.Errorf("only the Get and Set should be logged and hooked, not the replay: %d records, %+v", ops, hooks)
+	}
+}
+
+// countRecords counts the records in an access log.
+func countRecords(t *testing.T, log []byte) int {
+	r := accesslog.NewReader(bytes.NewReader(log))
+	n := 0
+	for {
+		if _, err := r.Read(); err == io.EOF {
+			return n
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+}
@@ -0,0 +1,129 @@
+package lfuda
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replica holds an immutable copy of a Cache, refreshed periodically by a
+// background goroutine.  Lookups read the current copy without taking any lock,
+// so read-only components can query it freely without contending with the live
+// cache's writers, at the cost of seeing its contents as of the last refresh.
+// Lookups don't count as hits in the live cache.
+type Replica struct {
+	cache     *Cache
+	snapshot  atomic.Value
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+type replicaSnapshot struct {
+	values  map[interface{}]interface{}
+	keys    []interface{}
+	size    float64
+	age     float64
+	updated time.Time
+}
+
+// NewReplica copies c, refreshing the copy every interval until Close is
+// called.
+func NewReplica(c *Cache, interval time.Duration) *Replica {
+	r := &Replica{
+		cache:   c,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	r.Refresh()
+	go r.refresh(interval)
+	return r
+}
+
+// Refresh copies the cache now, rather than waiting for the next interval.  It
+// takes the cache's read lock while copying.
+func (r *Replica) Refresh() {
+	c := r.cache
+	c.lock.RLock()
+	keys := c.lfuda.Keys()
+	s := &replicaSnapshot{
+		values: make(map[interface{}]interface{}, len(keys)),
+		keys:   keys[:0],
+		size:   c.lfuda.Size(),
+		age:    c.lfuda.Age(),
+	}
+	for _, key := range keys {
+		// expired entries are left out until they are removed
+		if value, ok := c.lfuda.Peek(key); ok {
+			s.values[key] = value
+			s.keys = append(s.keys, key)
+		}
+	}
+	c.lock.RUnlock()
+	s.updated = time.Now()
+	r.snapshot.Store(s)
+}
+
+// Close stops refreshing the replica.  It can still be read.
+func (r *Replica) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.stopped
+}
+
+func (r *Replica) refresh(interval time.Duration) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Refresh()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Replica) load() *replicaSnapshot {
+	return r.snapshot.Load().(*replicaSnapshot)
+}
+
+// Get looks up a key's value as of the last refresh.
+func (r *Replica) Get(key interface{}) (value interface{}, ok bool) {
+	value, ok = r.load().values[key]
+	return value, ok
+}
+
+// Contains checks if a key was in the cache as of the last refresh.
+func (r *Replica) Contains(key interface{}) bool {
+	_, ok := r.load().values[key]
+	return ok
+}
+
+// Keys returns the keys as of the last refresh, ordered by frequency.  The
+// slice is shared and must not be modified.
+func (r *Replica) Keys() []interface{} {
+	return r.load().keys
+}
+
+// Len returns the number of entries as of the last refresh.
+func (r *Replica) Len() int {
+	return len(r.load().keys)
+}
+
+// Size returns the size of the cache in bytes as of the last refresh.
+func (r *Replica) Size() float64 {
+	return r.load().size
+}
+
+// Age returns the cache age as of the last refresh.
+func (r *Replica) Age() float64 {
+	return r.load().age
+}
+
+// Updated returns the time of the last refresh.
+func (r *Replica) Updated() time.Time {
+	return r.load().updated
+}
@@ -0,0 +1,56 @@
+package lfuda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplica(t *testing.T) {
+	c := New(100)
+	c.Set("a", "x")
+	r := NewReplica(c, 10*time.Millisecond)
+	defer r.Close()
+
+	if v, ok := r.Get("a"); v != "x" || !ok || r.Len() != 1 || r.Size() != 1 {
+		t.Errorf("replica should copy the cache: %v, %v", v, ok)
+	}
+	if info, _ := c.Inspect("a"); info.Hits != 1 {
+		t.Errorf("replica reads should not count as hits: %f", info.Hits)
+	}
+
+	c.Set("b", "x")
+	if r.Contains("b") {
+		t.Errorf("replica should not see changes until refreshed")
+	}
+	updated := r.Updated()
+	deadline := time.Now().Add(time.Second)
+	for !r.Contains("b") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !r.Contains("b") || !r.Updated().After(updated) {
+		t.Errorf("replica should have been refreshed")
+	}
+
+	r.Close()
+	c.Remove("a")
+	time.Sleep(20 * time.Millisecond)
+	if !r.Contains("a") {
+		t.Errorf("closed replicas should not be refreshed")
+	}
+}
+
+func TestReplicaExpired(t *testing.T) {
+	c := New(100)
+	c.SetWithTTL("a", "x", time.Millisecond)
+	c.Set("b", "y")
+	time.Sleep(5 * time.Millisecond)
+
+	r := NewReplica(c, time.Hour)
+	defer r.Close()
+	if v, ok := r.Get("a"); ok || r.Contains("a") || v != nil {
+		t.Errorf("expired entries shouldn't be copied: %v, %v", v, ok)
+	}
+	if keys := r.Keys(); len(keys) != 1 || keys[0] != "b" || r.Len() != 1 {
+		t.Errorf("bad keys: %v", keys)
+	}
+}
@@ -0,0 +1,95 @@
+package lfuda
+
+import (
+	"sync"
+	"time"
+)
+
+// Schedule decides when scheduled purges run.  It's satisfied by the schedules
+// of most cron libraries, such as github.com/robfig/cron's.
+type Schedule interface {
+	// Next returns the first time after t to run at, or the zero time to stop.
+	Next(t time.Time) time.Time
+}
+
+// Daily returns a schedule running every day at hour:minute in loc, such as
+// just after a dataset's nightly republication.  A nil loc means time.Local.
+func Daily(hour, minute int, loc *time.Location) Schedule {
+	if loc == nil {
+		loc = time.Local
+	}
+	return daily{hour, minute, loc}
+}
+
+type daily struct {
+	hour, minute int
+	loc          *time.Location
+}
+
+func (d daily) Next(t time.Time) time.Time {
+	t = t.In(d.loc)
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.hour, d.minute, 0, 0, d.loc)
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, d.hour, d.minute, 0, 0, d.loc)
+	}
+	return next
+}
+
+// Every returns a schedule running every interval.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// SchedulePurge purges the cache on schedule from a background goroutine, so
+// data republished at known times isn't served past the cutover.  If match isn't
+// nil only the keys it matches are removed, such as those of one namespace.
+// Removed entries are passed to the eviction callback as with Remove.  Call the
+// returned function to stop the schedule.
+func (c *Cache) SchedulePurge(s Schedule, match func(key interface{}) bool) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			next := s.Next(time.Now())
+			if next.IsZero() {
+				return
+			}
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-timer.C:
+				if match == nil {
+					c.Purge()
+				} else {
+					c.removeMatching(match)
+				}
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// removeMatching removes every key match returns true for, returning how many
+// were removed.
+func (c *Cache) removeMatching(match func(key interface{}) bool) int {
+	c.writeLock("SchedulePurge", nil)
+	removed := 0
+	for _, key := range c.lfuda.Keys() {
+		if match(key) && c.lfuda.Remove(key) {
+			removed++
+		}
+	}
+	c.publish()
+	c.writeUnlock()
+	return removed
+}
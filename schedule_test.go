@@ -0,0 +1,71 @@
+package lfuda
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// once runs a single time, right away
+type once struct{ ran bool }
+
+func (o *once) Next(t time.Time) time.Time {
+	if o.ran {
+		return time.Time{}
+	}
+	o.ran = true
+	return t
+}
+
+func TestSchedulePurge(t *testing.T) {
+	var evicted []interface{}
+	c := NewWithEvict(100, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	c.Set("news/a", "a")
+	c.Set("news/b", "b")
+	c.Set("sports/a", "a")
+
+	stop := c.SchedulePurge(&once{}, func(key interface{}) bool {
+		return strings.HasPrefix(key.(string), "news/")
+	})
+	defer stop()
+	for deadline := time.Now().Add(time.Second); c.Len() != 1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Len() != 1 || !c.Contains("sports/a") || len(evicted) != 2 {
+		t.Errorf("only the news keys should have been removed: %v, evicted %v", c.Keys(), evicted)
+	}
+
+	stop = c.SchedulePurge(&once{}, nil)
+	for deadline := time.Now().Add(time.Second); c.Len() != 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Len() != 0 {
+		t.Errorf("the cache should have been purged: %v", c.Keys())
+	}
+	stop()
+	stop()
+}
+
+func TestDaily(t *testing.T) {
+	loc := time.FixedZone("test", 3600)
+	s := Daily(2, 30, loc)
+	before := time.Date(2024, 3, 9, 1, 0, 0, 0, loc)
+	if next := s.Next(before); !next.Equal(time.Date(2024, 3, 9, 2, 30, 0, 0, loc)) {
+		t.Errorf("should run later the same day: %v", next)
+	}
+	at := time.Date(2024, 3, 9, 2, 30, 0, 0, loc)
+	if next := s.Next(at); !next.Equal(time.Date(2024, 3, 10, 2, 30, 0, 0, loc)) {
+		t.Errorf("should run the next day: %v", next)
+	}
+	if next := s.Next(time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2025, 1, 1, 2, 30, 0, 0, loc)) {
+		t.Errorf("should roll over the year: %v", next)
+	}
+	if next := Daily(2, 30, nil).Next(at); next.Location() != time.Local {
+		t.Errorf("a nil location should be local time: %v", next)
+	}
+	if next := Every(time.Hour).Next(at); !next.Equal(at.Add(time.Hour)) {
+		t.Errorf("bad interval: %v", next)
+	}
+}
@@ -0,0 +1,83 @@
+// Package sessions provides an in-memory session store backed by lfuda.
+//
+// Sessions are opaque encoded data stored by session ID.  Each session
+// expires after the max-age it was saved with, and when the store is full the
+// least valuable sessions under the LFUDA policy are evicted first.
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/bparli/lfuda-go/recordcache"
+)
+
+// ErrNotFound is returned by Get when a session doesn't exist or has expired.
+var ErrNotFound = errors.New("sessions: session not found")
+
+// ErrTooLarge is returned by Save when a session is larger than the whole store.
+var ErrTooLarge = errors.New("sessions: session too large")
+
+// Store is the interface implemented by session stores.
+type Store interface {
+	// Get returns the data of the session with the given ID.
+	Get(id string) ([]byte, error)
+
+	// Save stores the session data under id for maxAge.
+	Save(id string, data []byte, maxAge time.Duration) error
+
+	// Delete removes the session with the given ID.
+	Delete(id string) error
+}
+
+// MemoryStore is a Store keeping sessions in an lfuda cache.
+type MemoryStore struct {
+	records *recordcache.Cache
+}
+
+// NewMemoryStore creates a MemoryStore holding up to size bytes of sessions.
+func NewMemoryStore(size float64) *MemoryStore {
+	return &MemoryStore{records: recordcache.New(size)}
+}
+
+// Get returns the data of the session with the given ID.
+func (s *MemoryStore) Get(id string) ([]byte, error) {
+	data, _, ok := s.records.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data.([]byte), nil
+}
+
+// Save stores the session data under id for maxAge.  A non-positive maxAge
+// deletes the session.  A session too large to store is deleted too, so its
+// previous data isn't served, and ErrTooLarge returned.
+func (s *MemoryStore) Save(id string, data []byte, maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return s.Delete(id)
+	}
+	stored := make([]byte, len(data))
+	copy(stored, data)
+	if !s.records.Store(id, stored, maxAge) {
+		s.records.Remove(id)
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Delete removes the session with the given ID.
+func (s *MemoryStore) Delete(id string) error {
+	s.records.Remove(id)
+	return nil
+}
+
+// NewID returns a new random session ID.
+func NewID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
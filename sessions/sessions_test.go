@@ -0,0 +1,68 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(1 << 20)
+	id, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{"user":"alice"}`)
+	if err := s.Save(id, data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// the store keeps its own copy
+	data[2] = 'X'
+
+	got, err := s.Get(id)
+	if err != nil || !bytes.Equal(got, []byte(`{"user":"alice"}`)) {
+		t.Errorf("bad session: %q, %v", got, err)
+	}
+
+	s.Delete(id)
+	if _, err := s.Get(id); err != ErrNotFound {
+		t.Errorf("deleted session should not be found: %v", err)
+	}
+}
+
+func TestMemoryStoreMaxAge(t *testing.T) {
+	s := NewMemoryStore(1 << 20)
+	s.Save("a", []byte("a"), time.Millisecond)
+	s.Save("b", []byte("b"), time.Hour)
+	s.Save("b", []byte("b"), 0)
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.Get("a"); err != ErrNotFound {
+		t.Errorf("session should have expired: %v", err)
+	}
+	if _, err := s.Get("b"); err != ErrNotFound {
+		t.Errorf("saving with no max-age should delete the session: %v", err)
+	}
+}
+
+func TestMemoryStoreTooLarge(t *testing.T) {
+	s := NewMemoryStore(100)
+	s.Save("a", []byte("a"), time.Hour)
+	if err := s.Save("a", make([]byte, 200), time.Hour); err != ErrTooLarge {
+		t.Errorf("sessions larger than the store should be refused: %v", err)
+	}
+	if _, err := s.Get("a"); err != ErrNotFound {
+		t.Errorf("the refused session's old data should be gone: %v", err)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, _ := NewID()
+	b, _ := NewID()
+	if a == b || len(a) < 32 {
+		t.Errorf("IDs should be long and unique: %q %q", a, b)
+	}
+}
@@ -0,0 +1,129 @@
+package simcache
+
+import (
+	"io"
+	"sort"
+
+	"github.com/bparli/lfuda-go/accesslog"
+)
+
+// Access is one lookup in a trace.
+type Access struct {
+	Key  interface{}
+	Size float64
+}
+
+// ReadTrace reads the lookups from an access log recorded with
+// lfuda.WithAccessLog.  Misses are logged without a size, so each takes the
+// size of the key's next Set, or its last known size.
+func ReadTrace(r *accesslog.Reader) ([]Access, error) {
+	var records []accesslog.Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	// walk backwards so each miss can see the size of the following Set
+	sizes := make(map[uint64]float64)
+	var trace []Access
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		switch rec.Op {
+		case accesslog.Set:
+			sizes[rec.KeyHash] = rec.Size
+		case accesslog.Hit:
+			sizes[rec.KeyHash] = rec.Size
+			trace = append(trace, Access{Key: rec.KeyHash, Size: rec.Size})
+		case accesslog.Miss:
+			trace = append(trace, Access{Key: rec.KeyHash, Size: sizes[rec.KeyHash]})
+		}
+	}
+	for i, j := 0, len(trace)-1; i < j; i, j = i+1, j-1 {
+		trace[i], trace[j] = trace[j], trace[i]
+	}
+	return trace, nil
+}
+
+// Run replays a trace through a simulated cache, returning its stats.
+func Run(trace []Access, c *Cache) Stats {
+	for _, a := range trace {
+		c.Access(a.Key, a.Size)
+	}
+	return c.Stats()
+}
+
+// Point is a point on a miss curve.
+type Point struct {
+	Size      float64
+	MissRatio float64
+}
+
+// Recommendation is the smallest simulated capacity found to reach a target
+// hit ratio.
+type Recommendation struct {
+	// Size is the recommended capacity in bytes
+	Size float64
+	// HitRatio is the hit ratio simulated at Size
+	HitRatio float64
+	// Achieved is false if even a cache holding every key in the trace falls
+	// short of the target, in which case Size is that cache's
+	Achieved bool
+	// Curve is the miss ratio at every simulated size, smallest first
+	Curve []Point
+}
+
+// Recommend binary searches simulated capacities, created by newCache such as
+// New, for the smallest reaching the target hit ratio on trace.  The search is
+// to within 1% of the capacity needed to hold every key, and assumes the hit
+// ratio grows with the capacity, which holds for typical traces.
+func Recommend(trace []Access, target float64, newCache func(size float64) *Cache) Recommendation {
+	// a cache holding every key only misses on first accesses
+	largest := make(map[interface{}]float64)
+	hi := 0.0
+	for _, a := range trace {
+		if a.Size > largest[a.Key] {
+			hi += a.Size - largest[a.Key]
+			largest[a.Key] = a.Size
+		}
+	}
+
+	var r Recommendation
+	simulate := func(size float64) float64 {
+		ratio := Run(trace, newCache(size)).HitRatio()
+		r.Curve = append(r.Curve, Point{Size: size, MissRatio: 1 - ratio})
+		return ratio
+	}
+	// sketch the curve, then search between the points either side of the
+	// target
+	for i := 1; i <= 10; i++ {
+		simulate(hi * float64(i) / 10)
+	}
+	r.Size, r.HitRatio = hi, 1-r.Curve[len(r.Curve)-1].MissRatio
+	r.Achieved = r.HitRatio >= target
+
+	lo := 0.0
+	for _, p := range r.Curve {
+		if 1-p.MissRatio >= target {
+			r.Size, r.HitRatio = p.Size, 1-p.MissRatio
+			break
+		}
+		lo = p.Size
+	}
+	if r.Achieved {
+		for r.Size-lo > hi/100 {
+			mid := (lo + r.Size) / 2
+			if ratio := simulate(mid); ratio >= target {
+				r.Size, r.HitRatio = mid, ratio
+			} else {
+				lo = mid
+			}
+		}
+	}
+	sort.Slice(r.Curve, func(i, j int) bool { return r.Curve[i].Size < r.Curve[j].Size })
+	return r
+}
@@ -0,0 +1,58 @@
+package simcache
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/bparli/lfuda-go/accesslog"
+)
+
+func TestRecommend(t *testing.T) {
+	// 80% of accesses to 20 hot keys, the rest to 1000 cold ones
+	r := rand.New(rand.NewSource(1))
+	var trace []Access
+	keys := make(map[int]bool)
+	for i := 0; i < 20000; i++ {
+		if r.Intn(10) < 8 {
+			trace = append(trace, Access{Key: r.Intn(20), Size: 10})
+		} else {
+			trace = append(trace, Access{Key: 100 + r.Intn(1000), Size: 10})
+		}
+		keys[trace[i].Key.(int)] = true
+	}
+	all := float64(10 * len(keys))
+
+	rec := Recommend(trace, 0.75, func(size float64) *Cache { return New(size) })
+	if !rec.Achieved || rec.HitRatio < 0.75 || rec.Size > 2000 {
+		t.Errorf("hot keys should be enough: %+v", rec.Size)
+	}
+	for i := 1; i < len(rec.Curve); i++ {
+		if rec.Curve[i].Size <= rec.Curve[i-1].Size {
+			t.Fatalf("curve should be sorted by size")
+		}
+	}
+	if last := rec.Curve[len(rec.Curve)-1]; last.Size != all {
+		t.Errorf("curve should go up to every key: %+v", last)
+	}
+
+	if rec := Recommend(trace, 0.99, func(size float64) *Cache { return New(size) }); rec.Achieved || rec.Size != all {
+		t.Errorf("compulsory misses make 0.99 unachievable: %+v", rec.HitRatio)
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	var buf bytes.Buffer
+	w := accesslog.NewWriter(&buf)
+	c := lfuda.New(100, lfuda.WithAccessLog(w))
+	c.Get("a")
+	c.Set("a", "xyz")
+	c.Get("a")
+	w.Flush()
+
+	trace, err := ReadTrace(accesslog.NewReader(&buf))
+	if err != nil || len(trace) != 2 || trace[0].Size != 3 || trace[1].Size != 3 || trace[0].Key != accesslog.Hash("a") {
+		t.Errorf("bad trace: %+v, %v", trace, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package simcache simulates an lfuda cache without storing values, so a
+// service can measure how a differently sized or configured cache would do on
+// its real traffic, alongside the cache it actually uses.
+//
+// Only a hash of each key and its size and frequency are kept, so a simulated
+// cache costs a small fixed amount of memory per entry however large the real
+// values are.  Feed it every lookup with Access:
+//
+//	sim := simcache.New(2 * realSize)
+//	...
+//	v, ok := c.Get(key)
+//	sim.Access(key, size)
+package simcache
+
+import (
+	"sync/atomic"
+
+	"github.com/bparli/lfuda-go"
+	"github.com/bparli/lfuda-go/internal/keyhash"
+)
+
+// Cache is a simulated cache.  It's safe for concurrent use.
+type Cache struct {
+	cache *lfuda.Cache
+
+	hits      uint64
+	misses    uint64
+	hitBytes  uint64
+	missBytes uint64
+}
+
+// Stats summarizes a simulation.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	HitBytes  uint64
+	MissBytes uint64
+}
+
+// HitRatio is the fraction of accesses which hit.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// ByteHitRatio is the fraction of the bytes accessed which hit.
+func (s Stats) ByteHitRatio() float64 {
+	if s.HitBytes+s.MissBytes == 0 {
+		return 0
+	}
+	return float64(s.HitBytes) / float64(s.HitBytes+s.MissBytes)
+}
+
+// New simulates an LFUDA cache of the given size in bytes, configured with
+// opts.
+func New(size float64, opts ...lfuda.Option) *Cache {
+	return &Cache{cache: lfuda.New(size, opts...)}
+}
+
+// NewGDSF simulates a GDSF cache of the given size in bytes.
+func NewGDSF(size float64, opts ...lfuda.Option) *Cache {
+	return &Cache{cache: lfuda.NewGDSF(size, opts...)}
+}
+
+// NewLFU simulates an LFU cache of the given size in bytes.
+func NewLFU(size float64, opts ...lfuda.Option) *Cache {
+	return &Cache{cache: lfuda.NewLFU(size, opts...)}
+}
+
+// Access simulates a lookup of key, whose value is size bytes, adding it on a
+// miss as a read-through cache would.  Returns whether the simulated cache hit.
+func (c *Cache) Access(key interface{}, size float64) bool {
+	h := keyhash.Sum(key)
+	if _, ok := c.cache.Get(h); ok {
+		atomic.AddUint64(&c.hits, 1)
+		atomic.AddUint64(&c.hitBytes, uint64(size))
+		return true
+	}
+	c.cache.SetWithSize(h, nil, size)
+	atomic.AddUint64(&c.misses, 1)
+	atomic.AddUint64(&c.missBytes, uint64(size))
+	return false
+}
+
+// Stats returns the hits and misses since the simulation started or was Reset.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		HitBytes:  atomic.LoadUint64(&c.hitBytes),
+		MissBytes: atomic.LoadUint64(&c.missBytes),
+	}
+}
+
+// Reset zeroes the stats, keeping the simulated contents, for example once the
+// simulation has warmed up.
+func (c *Cache) Reset() {
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.hitBytes, 0)
+	atomic.StoreUint64(&c.missBytes, 0)
+}
+
+// Len returns the number of simulated entries.
+func (c *Cache) Len() int {
+	return c.cache.Len()
+}
+
+// Size returns the total size of the simulated entries in bytes.
+func (c *Cache) Size() float64 {
+	return c.cache.Size()
+}
@@ -0,0 +1,23 @@
+package simcache
+
+import "testing"
+
+func TestSimulation(t *testing.T) {
+	small, large := New(2), New(3)
+	trace := []string{"a", "b", "c", "a", "b", "c", "a", "b", "c"}
+	for _, key := range trace {
+		small.Access(key, 1)
+		large.Access(key, 1)
+	}
+	if s := large.Stats(); s.Hits != 6 || s.Misses != 3 || s.HitRatio() != 6.0/9 || s.HitBytes != 6 {
+		t.Errorf("bad large stats: %+v", s)
+	}
+	if small.Stats().Hits >= large.Stats().Hits || small.Len() != 2 || small.Size() != 2 {
+		t.Errorf("small cache should hit less: %+v", small.Stats())
+	}
+
+	large.Reset()
+	if large.Access("a", 1); large.Stats().Hits != 1 || large.Stats().ByteHitRatio() != 1 {
+		t.Errorf("Reset should keep the contents: %+v", large.Stats())
+	}
+}
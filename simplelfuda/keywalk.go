@@ -0,0 +1,106 @@
+package simplelfuda
+
+// KeyWalk visits the keys in the same order as Keys, highest priority first, a
+// few at a time, so a caller guarding the cache with a lock can release it
+// between steps rather than holding it while every key is copied.  Entries
+// which move while the walk is open may be passed over or visited twice, so
+// the walk records them; Clean reports whether any did.  Keys added while the
+// walk is open may or may not be visited.
+type KeyWalk struct {
+	l *LFUDA
+	// the next entry to visit, once started
+	next    *item
+	started bool
+	// the list was rebuilt or an entry moved since the walk was opened
+	dirty bool
+	moved map[interface{}]struct{}
+}
+
+// WalkKeys opens a walk over the keys.  The walk modifies the cache as it is
+// kept in step with it, so close it once done.
+func (l *LFUDA) WalkKeys() *KeyWalk {
+	w := &KeyWalk{l: l}
+	l.walks = append(l.walks, w)
+	return w
+}
+
+// Next appends up to n more keys to keys, returning it and whether the walk
+// is done.
+func (w *KeyWalk) Next(keys []interface{}, n int) ([]interface{}, bool) {
+	if !w.started {
+		w.started = true
+		if back := w.l.freqs.back; back != nil {
+			w.next = back.last
+		}
+	}
+	for ; n > 0 && w.next != nil; n-- {
+		keys = append(keys, w.next.key)
+		w.next = w.next.following()
+	}
+	return keys, w.next == nil
+}
+
+// Clean reports whether no entry has moved since the walk was opened, so each
+// key present throughout was visited exactly once, in order.
+func (w *KeyWalk) Clean() bool {
+	return !w.dirty
+}
+
+// Moved returns the keys still in the cache which moved since the walk was
+// opened, and so may have been passed over.
+func (w *KeyWalk) Moved() []interface{} {
+	var keys []interface{}
+	for key := range w.moved {
+		if _, ok := w.l.items[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Close stops keeping the walk in step with the cache.
+func (w *KeyWalk) Close() {
+	walks := w.l.walks
+	for i, open := range walks {
+		if open == w {
+			copy(walks[i:], walks[i+1:])
+			walks[len(walks)-1] = nil
+			w.l.walks = walks[:len(walks)-1]
+			return
+		}
+	}
+}
+
+// following returns the entry visited after e, the next older in its node or
+// else the newest in the node below.
+func (e *item) following() *item {
+	if e.prev != nil {
+		return e.prev
+	}
+	if below := e.freqNode.prev; below != nil {
+		return below.last
+	}
+	return nil
+}
+
+// moving records an entry about to leave its frequency node with every open
+// walk, stepping past it any walk which would visit it next.
+func (l *LFUDA) moving(e *item) {
+	for _, w := range l.walks {
+		if w.next == e {
+			w.next = e.following()
+		}
+		if w.moved == nil {
+			w.moved = make(map[interface{}]struct{})
+		}
+		w.moved[e.key] = struct{}{}
+		w.dirty = true
+	}
+}
+
+// restartWalks starts every open walk over after the list is rebuilt.
+func (l *LFUDA) restartWalks() {
+	for _, w := range l.walks {
+		w.next, w.started, w.dirty = nil, false, true
+	}
+}
@@ -1,9 +1,15 @@
 package simplelfuda
 
 import (
-	"container/list"
-	"encoding/binary"
 	"fmt"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"time"
+	"unsafe"
+
+	"github.com/bparli/lfuda-go/internal/keyhash"
 )
 
 /*
@@ -17,7 +23,70 @@ Differences between LFUDA and regular LFU cache:
 // EvictCallback is used to get a callback when a LFUDA entry is evicted
 type EvictCallback func(key interface{}, value interface{})
 
-type cachePolicy func(element *item, cacheAge float64) float64
+// Victim describes an entry evicted to make room for a Set.
+type Victim struct {
+	Key      interface{}
+	Value    interface{}
+	Size     float64
+	Priority float64
+}
+
+// EntryInfo describes a cached entry's standing in the cache.
+type EntryInfo struct {
+	Key      interface{}
+	Value    interface{}
+	Hits     float64
+	Priority float64
+	Size     float64
+	Boost    float64
+	Class    int
+	// position in the cache's sequence of sets and hits, larger for more
+	// recently accessed entries
+	LastAccess uint64
+	// when the entry was inserted
+	Created time.Time
+	// when the entry expires, or the zero time if it doesn't
+	Expires time.Time
+}
+
+// AgeEvent records an eviction which raised the age of a priority class.
+type AgeEvent struct {
+	OldAge float64
+	NewAge float64
+	Class  int
+	// keyhash of the evicted key, which is stable across processes
+	KeyHash uint64
+	Time    time.Time
+}
+
+// Histogram counts durations into buckets.  Counts[i] holds the durations no
+// longer than Bounds[i] and greater than the previous bound, with a final count
+// for those greater than every bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// DefaultResidencyBounds are the histogram buckets used by WithResidencyHistogram
+// when none are given.
+var DefaultResidencyBounds = []time.Duration{
+	time.Second, 10 * time.Second, time.Minute, 10 * time.Minute,
+	time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+type cachePolicy func(l *LFUDA, element *item, cacheAge float64) float64
 
 // LFUDA is a non-threadsafe fixed size LFU with Dynamic Aging Cache
 type LFUDA struct {
@@ -25,120 +94,1041 @@ type LFUDA struct {
 	size     float64
 	currSize float64
 	items    map[interface{}]*item
-	freqs    *list.List
+	freqs    freqList
 	onEvict  EvictCallback
 	age      float64
-	policy   cachePolicy
+	// ages of priority classes other than the default class 0
+	classAges map[int32]float64
+	policy    cachePolicy
+	// don't count the first Get of a new entry
+	scanResistant bool
+	// lead over the age past which Gets are counted probabilistically
+	hotThreshold float64
+	// base of approximate logarithmic hit counters, or 0 for exact counts
+	logBase float64
+	rand    *rand.Rand
+	// ring buffer of aging events, next is the oldest once it's full
+	ageHistory []AgeEvent
+	ageNext    int
+	// residency of evicted entries, if recorded
+	residency *Histogram
+	// called for each entry evicted to make room
+	observeVictim func(Victim)
+	// called whenever the age of a class increases
+	observeAge func(AgeEvent, Victim)
+	// asked whether to spare each entry chosen to make room, up to maxVetoes
+	// times per eviction
+	veto      func(Victim) bool
+	maxVetoes int
+	// spare entries the first time they're chosen to make room
+	secondChance bool
+	// recent hits per entry, if hits are counted over a sliding window
+	window *hitWindow
+	// treat sets of existing keys as new entries
+	resetOnSet bool
+	// called for each Set which is refused
+	onReject func(key, value interface{}, reason RejectReason)
+	// called with panics recovered from callbacks, which are otherwise
+	// propagated
+	onError func(error)
+	// logical clock ticked by every set and hit
+	clock uint64
+	// time spent in operations, if recorded
+	timings *Timings
+	// converts sizes to the cost GDSF divides by, if not bytes
+	normalizeSize func(float64) float64
+	// width priorities are rounded down to multiples of, if bucketed
+	bucketWidth float64
+	// number of entries the index is presized for
+	expectedEntries int
+	// size every entry as 1, so the cache's size is a number of entries
+	countEntries bool
+	// most entries held alongside the byte limit, if capped
+	maxEntries int
+	// sizes values in place of calcBytes, if set
+	sizer func(key, value interface{}) float64
+	// expiry of entries set without a TTL of their own, if any
+	defaultTTL time.Duration
+	// most priority taken off entries about to expire, and how far ahead
+	ttlWeight  float64
+	ttlHorizon time.Duration
+	// activity since creation or the last ResetStats
+	stats Stats
+	// the counters as of the last StatsDelta
+	statsMark Stats
+	// the counters at the start of recent intervals, if windowed
+	statsWindow *statsWindow
+	// promotions and the frequency nodes they stepped over
+	promotions        uint64
+	promotionSteps    int
+	maxPromotionSteps int
+	// recent sets and those refused for being larger than the cache, halved
+	// every recentSets sets
+	sets    int
+	refused int
+	// open key walks, kept in step as entries move
+	walks []*KeyWalk
 }
 
+// number of sets over which the refusal rate reported by Health is measured
+const recentSets = 1024
+
+// most entries WithSecondChance spares per eviction, bounding the work done
+// when many entries have yet to use their chance
+const maxChances = 8
+
 type item struct {
-	key         interface{}
-	value       interface{}
-	size        float64
-	hits        float64
+	key   interface{}
+	value interface{}
+	size  float64
+	hits  float64
+	boost float64
+	// unix nanos the entry was inserted at
+	created int64
+	// unix nanos the entry expires at, or 0 if it doesn't
+	expires int64
+	// logical time of the last set or hit
+	lastAccess  uint64
 	priorityKey float64
-	freqNode    *list.Element
+	// the entry's frequency node and its neighbours there
+	freqNode   *listEntry
+	prev, next *item
+	class      int32
+	// inserted with scan resistance and not yet fetched
+	unconfirmed bool
+	// already spared once by WithSecondChance
+	spared bool
 }
 
+// frequency nodes are ordered by class and then priority key, so every entry in a
+// lower class is evicted before any in a higher one.  A node's entries are
+// linked oldest first, so ties are evicted in the order they were reached.
 type listEntry struct {
-	entries     map[*item]byte
+	prev, next  *listEntry
+	first, last *item
+	len         int
+	class       int32
 	priorityKey float64
 }
 
-// NewGDSF constructs an LFUDA of the given size in bytes and uses the GDSF eviction policy
-func NewGDSF(size float64, onEvict EvictCallback) *LFUDA {
-	return &LFUDA{
-		size:     size,
-		currSize: 0,
-		items:    make(map[interface{}]*item),
-		freqs:    list.New(),
-		onEvict:  onEvict,
-		age:      0,
-		policy:   gdsfPolicy,
+// above reports whether the node sorts after the entry's position.
+func (n *listEntry) above(e *item) bool {
+	return n.class > e.class || n.class == e.class && n.priorityKey > e.priorityKey
+}
+
+// holds reports whether the entry belongs in the node.
+func (n *listEntry) holds(e *item) bool {
+	return n.class == e.class && n.priorityKey == e.priorityKey
+}
+
+// push appends the entry to the node.
+func (n *listEntry) push(e *item) {
+	e.freqNode, e.prev, e.next = n, n.last, nil
+	if n.last != nil {
+		n.last.next = e
+	} else {
+		n.first = e
 	}
+	n.last = e
+	n.len++
 }
 
-// NewLFUDA constructs an LFUDA of the given size in bytes and uses the LFUDA eviction policy
-func NewLFUDA(size float64, onEvict EvictCallback) *LFUDA {
-	return &LFUDA{
-		size:     size,
-		currSize: 0,
-		items:    make(map[interface{}]*item),
-		freqs:    list.New(),
-		onEvict:  onEvict,
-		age:      0,
-		policy:   lfudaPolicy,
+// remove unlinks the entry from the node.
+func (n *listEntry) remove(e *item) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		n.first = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		n.last = e.prev
+	}
+	e.freqNode, e.prev, e.next = nil, nil, nil
+	n.len--
+}
+
+// freqList is the doubly linked list of frequency nodes, lowest priority first.
+type freqList struct {
+	front, back *listEntry
+	len         int
+}
+
+// insertAfter links n after mark, or at the front if mark is nil.
+func (f *freqList) insertAfter(n, mark *listEntry) {
+	if mark == nil {
+		n.prev, n.next = nil, f.front
+		f.front = n
+	} else {
+		n.prev, n.next = mark, mark.next
+		mark.next = n
+	}
+	if n.next != nil {
+		n.next.prev = n
+	} else {
+		f.back = n
+	}
+	f.len++
+}
+
+func (f *freqList) remove(n *listEntry) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		f.front = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		f.back = n.prev
+	}
+	n.prev, n.next = nil, nil
+	f.len--
+}
+
+// Option configures an LFUDA.
+type Option func(*LFUDA)
+
+// WithScanResistance makes an entry's first Get after insertion not increment
+// its hits, so entries touched once by a one-pass scan don't get promoted over
+// entries which are genuinely reused.
+func WithScanResistance() Option {
+	return func(l *LFUDA) {
+		l.scanResistant = true
+	}
+}
+
+// WithProbabilisticHits makes Gets of keys whose priority is more than threshold
+// above the cache age promote them only with probability threshold/lead, adding
+// lead/threshold hits when they do.  The expected hit count is unchanged, but a
+// viral key no longer moves between frequency nodes on every access.
+func WithProbabilisticHits(threshold float64) Option {
+	return func(l *LFUDA) {
+		l.hotThreshold = threshold
+		l.initRand()
+	}
+}
+
+// WithLogCounters replaces exact hit counts with approximate logarithmic (Morris)
+// counters: a Get increments an entry's hits with probability base^-hits, so
+// hits tracks the log of the number of accesses.  Long-lived hot keys can't build
+// up astronomically large counts that the cache age would take forever to catch
+// up with.  base must be greater than 1, smaller values counting more finely.
+func WithLogCounters(base float64) Option {
+	return func(l *LFUDA) {
+		if base > 1 {
+			l.logBase = base
+			l.initRand()
+		}
+	}
+}
+
+// WithAgeHistory records the last n aging events so sudden jumps in age, which
+// gate all future admissions, can be diagnosed after the fact.
+func WithAgeHistory(n int) Option {
+	return func(l *LFUDA) {
+		if n > 0 {
+			l.ageHistory = make([]AgeEvent, 0, n)
+		}
+	}
+}
+
+// WithResidencyHistogram records how long each evicted entry was resident in a
+// histogram with the given ascending bucket bounds, or DefaultResidencyBounds,
+// showing whether the cache is large enough to give entries a useful lifetime.
+func WithResidencyHistogram(bounds ...time.Duration) Option {
+	return func(l *LFUDA) {
+		if len(bounds) == 0 {
+			bounds = DefaultResidencyBounds
+		}
+		l.residency = &Histogram{
+			Bounds: append([]time.Duration(nil), bounds...),
+			Counts: make([]uint64, len(bounds)+1),
+		}
+	}
+}
+
+// WithVictimObserver calls fn for each entry evicted to make room, or by Evict
+// and EvictBytes, before the eviction callback.  Unlike the eviction callback,
+// fn isn't called for entries removed by Remove or Purge and is passed the
+// victim's size and priority.
+func WithVictimObserver(fn func(Victim)) Option {
+	return func(l *LFUDA) {
+		l.observeVictim = fn
+	}
+}
+
+// WithAgeObserver calls fn whenever the age of a priority class increases, with
+// the old and new ages and the victim whose eviction raised it, since a jump in
+// age changes the admission of every later Set.  Raises by RaiseAge are passed a
+// zero Victim.  fn is called along with the victim observer and must not modify
+// the cache.
+func WithAgeObserver(fn func(AgeEvent, Victim)) Option {
+	return func(l *LFUDA) {
+		l.observeAge = fn
+	}
+}
+
+// WithEvictionVeto asks fn about each entry chosen to be evicted to make room,
+// sparing it and choosing the next candidate if fn returns true, as when the
+// application knows the entry is about to be needed.  At most maxVetoes entries
+// are spared per Set, after which the candidates are evicted regardless, and
+// spared entries are still evicted if there's no other way to make room.  fn
+// isn't consulted by Evict, WouldSet or Explain, and must not modify the cache.
+func WithEvictionVeto(fn func(Victim) bool, maxVetoes int) Option {
+	return func(l *LFUDA) {
+		if maxVetoes > 0 {
+			l.veto = fn
+			l.maxVetoes = maxVetoes
+		}
+	}
+}
+
+// WithSecondChance spares each entry the first time it's chosen to be evicted
+// to make room, taking a hit's worth off its priority instead, so it's only
+// evicted if chosen again.  Borderline hot entries which are still being used
+// get the chance to earn their place back.  Spared entries are still evicted
+// if there's no other way to make room, and at most a few are spared per Set.
+func WithSecondChance() Option {
+	return func(l *LFUDA) {
+		l.secondChance = true
+	}
+}
+
+// WithResetOnSet makes setting a key already in the cache replace the entry as if
+// it were new, with its hits reset so its priority falls back to the cache age
+// plus one hit, and its boost and class back to the defaults unless given.  By
+// default a set counts as a hit of the existing entry, which keeps its hits,
+// boost and class.
+func WithResetOnSet() Option {
+	return func(l *LFUDA) {
+		l.resetOnSet = true
+	}
+}
+
+// RejectReason is why a Set was refused.
+type RejectReason string
+
+// RejectTooLarge is the reason for refusing a value larger than the whole
+// cache, the only reason values are refused.
+const RejectTooLarge RejectReason = "too large"
+
+// WithRejectCallback calls fn with the key, value and reason of each Set which
+// is refused, so admission failures can be instrumented.  Sets which are stored
+// and then evicted again are not refused.  fn must not modify the cache.
+func WithRejectCallback(fn func(key, value interface{}, reason RejectReason)) Option {
+	return func(l *LFUDA) {
+		l.onReject = fn
+	}
+}
+
+// WithErrorHook recovers panics in the eviction callback, eviction veto, reject
+// callback and victim and age observers, passing them to fn as *CallbackPanic errors, so one buggy callback
+// can't take down the process.  The cache is left consistent whether or not a callback
+// panics, and the remaining callbacks of a batch of evictions are still made.
+func WithErrorHook(fn func(error)) Option {
+	return func(l *LFUDA) {
+		l.onError = fn
+	}
+}
+
+// CallbackPanic is the error passed to the error hook when a callback panics.
+type CallbackPanic struct {
+	// Callback names the callback, "eviction callback", "eviction veto",
+	// "reject callback", "victim observer" or "age observer"
+	Callback string
+	// Value is the value the callback panicked with
+	Value interface{}
+	// Stack is the callback's stack trace when it panicked
+	Stack []byte
+}
+
+func (p *CallbackPanic) Error() string {
+	return fmt.Sprintf("simplelfuda: %s panicked: %v", p.Callback, p.Value)
+}
+
+// WithSizeUnit makes GDSF divide hits by sizes in the given unit, such as 1024
+// for KB, rather than bytes.  Dividing by bytes makes the frequency term of
+// multi-MB entries vanishingly small next to the cache age, so they are
+// ordered by little more than floating point noise.  Has no effect on other
+// policies.
+func WithSizeUnit(unit float64) Option {
+	return func(l *LFUDA) {
+		if unit > 0 {
+			l.normalizeSize = func(size float64) float64 { return size / unit }
+		}
+	}
+}
+
+// WithSizeNormalization makes GDSF divide hits by fn(size) rather than size,
+// for custom cost functions such as the log of the size.  Has no effect on
+// other policies.
+func WithSizeNormalization(fn func(size float64) float64) Option {
+	return func(l *LFUDA) {
+		l.normalizeSize = fn
+	}
+}
+
+// WithPriorityBuckets rounds priorities down to multiples of width, so entries
+// with nearly equal priorities share a frequency node.  GDSF's near-unique
+// float priorities otherwise give every entry its own node, making promotions
+// linear in the number of entries.  Wider buckets mean shorter lists but
+// coarser eviction order, with ties within a bucket evicted oldest first.
+func WithPriorityBuckets(width float64) Option {
+	return func(l *LFUDA) {
+		if width > 0 {
+			l.bucketWidth = width
+		}
+	}
+}
+
+// WithTTLWeight makes entries' remaining time to live part of their priority,
+// so entries about to expire are evicted before hot ones which will live on.
+// Priorities are lowered by up to weight, in proportion to how much of horizon
+// an entry's TTL has used up; entries without a TTL, or with at least horizon
+// left, lose nothing.  weight is in the policy's units, so under LFUDA a weight
+// of 1 is worth one hit.  As with hits, the time left is only taken into account
+// when an entry is set or hit: priorities don't decay between accesses, as
+// that would mean re-ranking every entry as time passed.
+func WithTTLWeight(weight float64, horizon time.Duration) Option {
+	return func(l *LFUDA) {
+		if weight > 0 && horizon > 0 {
+			l.ttlWeight, l.ttlHorizon = weight, horizon
+		}
+	}
+}
+
+// WithExpectedEntries presizes the cache's index for n entries, so warming up a
+// large cache doesn't repeatedly grow and rehash it.  The cache still holds as
+// many entries as fit whatever n is.
+func WithExpectedEntries(n int) Option {
+	return func(l *LFUDA) {
+		if n > 0 {
+			l.expectedEntries = n
+		}
+	}
+}
+
+// WithEntryCounting makes the cache's size a number of entries rather than
+// bytes: every entry counts as 1, whatever its value or an explicit size, and
+// values are never sized.  For workloads which want at most so many entries,
+// or whose values are too costly to size.
+func WithEntryCounting() Option {
+	return func(l *LFUDA) {
+		l.countEntries = true
+	}
+}
+
+// WithDefaultTTL makes entries set without a TTL of their own expire after ttl,
+// so every Set gets an expiry without changing its call sites.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(l *LFUDA) {
+		if ttl > 0 {
+			l.defaultTTL = ttl
+		}
+	}
+}
+
+// Sizer is implemented by values which know their own size in bytes.  Set and
+// the other methods which size values use it rather than working it out.
+type Sizer interface {
+	Size() int64
+}
+
+// WithSizer sizes values with fn rather than the default calculation, for
+// values such as structs, pointers and maps whose formatted size says little
+// about the memory they hold.  Values implementing Sizer are sized by fn too.
+func WithSizer(fn func(key, value interface{}) float64) Option {
+	return func(l *LFUDA) {
+		l.sizer = fn
 	}
 }
 
+// WithMaxEntries caps the cache at n entries as well as its size in bytes,
+// evicting the lowest priority entries whenever either limit would be exceeded,
+// so caches of widely varying value sizes bound both their memory and their
+// per-entry overhead.
+func WithMaxEntries(n int) Option {
+	return func(l *LFUDA) {
+		if n > 0 {
+			l.maxEntries = n
+		}
+	}
+}
+
+// Timings breaks down the time spent in a cache's operations.
+type Timings struct {
+	// Sizing is the time spent calculating values' sizes
+	Sizing time.Duration
+	// Eviction is the time spent choosing and removing victims, excluding
+	// callbacks
+	Eviction time.Duration
+	// Callbacks is the time spent in the eviction callback and victim
+	// observer
+	Callbacks time.Duration
+}
+
+// WithTimings records the time spent sizing values, evicting and in callbacks,
+// read with TakeTimings, to find what makes slow operations slow.
+func WithTimings() Option {
+	return func(l *LFUDA) {
+		l.timings = new(Timings)
+	}
+}
+
+// Stats counts a cache's activity since it was created or ResetStats was last
+// called.
+type Stats struct {
+	// Hits is the number of Gets which found their key
+	Hits uint64
+	// Misses is the number of Gets which didn't, including those finding an
+	// expired entry
+	Misses uint64
+	// Evictions is the number of entries evicted to make room, or by Evict
+	// and the like, and EvictedBytes their total size
+	Evictions    uint64
+	EvictedBytes float64
+	// Expirations is the number of expired entries removed
+	Expirations uint64
+	// Rejected is the number of Sets refused for being larger than the cache
+	Rejected uint64
+	// Age is the current cache age, which ResetStats leaves alone
+	Age float64
+}
+
+// HitRatio returns the fraction of Gets which were hits, or 0 if there were
+// none.
+func (s Stats) HitRatio() float64 {
+	if gets := s.Hits + s.Misses; gets > 0 {
+		return float64(s.Hits) / float64(gets)
+	}
+	return 0
+}
+
+// Stats returns the cache's activity counters and current age.
+func (l *LFUDA) Stats() Stats {
+	stats := l.stats
+	stats.Age = l.age
+	return stats
+}
+
+// ResetStats zeroes the activity counters, to count from now on.
+func (l *LFUDA) ResetStats() {
+	l.stats = Stats{}
+	l.statsMark = Stats{}
+	if l.statsWindow != nil {
+		l.statsWindow.reset()
+	}
+}
+
+// CountGets counts hits and misses of Gets served without going through the
+// cache, such as from a copy of it, in Stats.
+func (l *LFUDA) CountGets(hits, misses uint64) {
+	stats := l.counters()
+	stats.Hits += hits
+	stats.Misses += misses
+}
+
+// StatsDelta returns the activity since StatsDelta was last called, or since
+// the cache was created or ResetStats was last called if later, with the
+// current age, so scrapers can compute rates without diffing Stats themselves.
+func (l *LFUDA) StatsDelta() Stats {
+	delta := l.stats.since(l.statsMark)
+	delta.Age = l.age
+	l.statsMark = l.stats
+	return delta
+}
+
+// since returns the counts added to s after prev was taken.
+func (s Stats) since(prev Stats) Stats {
+	return Stats{
+		Hits:         s.Hits - prev.Hits,
+		Misses:       s.Misses - prev.Misses,
+		Evictions:    s.Evictions - prev.Evictions,
+		EvictedBytes: s.EvictedBytes - prev.EvictedBytes,
+		Expirations:  s.Expirations - prev.Expirations,
+		Rejected:     s.Rejected - prev.Rejected,
+	}
+}
+
+// TakeTimings returns the time spent in operations since the last call, or
+// zero unless the cache was created WithTimings.
+func (l *LFUDA) TakeTimings() Timings {
+	if l.timings == nil {
+		return Timings{}
+	}
+	t := *l.timings
+	*l.timings = Timings{}
+	return t
+}
+
+// sizeOf calculates a value's size, timing it if timings are recorded.
+func (l *LFUDA) sizeOf(key, value interface{}) float64 {
+	if l.countEntries {
+		return 1
+	}
+	if l.timings == nil {
+		return l.calcSize(key, value)
+	}
+	start := time.Now()
+	size := l.calcSize(key, value)
+	l.timings.Sizing += time.Since(start)
+	return size
+}
+
+// calcSize sizes a value with the sizer if there is one.
+func (l *LFUDA) calcSize(key, value interface{}) float64 {
+	if l.sizer != nil {
+		return l.sizer(key, value)
+	}
+	return calcBytes(value)
+}
+
+func (l *LFUDA) initRand() {
+	if l.rand == nil {
+		l.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+}
+
+// NewGDSF constructs an LFUDA of the given size in bytes and uses the GDSF eviction policy
+func NewGDSF(size float64, onEvict EvictCallback, opts ...Option) *LFUDA {
+	return newLFUDA(size, onEvict, gdsfPolicy, opts)
+}
+
+// NewLFUDA constructs an LFUDA of the given size in bytes and uses the LFUDA eviction policy
+func NewLFUDA(size float64, onEvict EvictCallback, opts ...Option) *LFUDA {
+	return newLFUDA(size, onEvict, lfudaPolicy, opts)
+}
+
 // NewLFU constructs an LFUDA of the given size in bytes and uses the LFU eviction policy
-func NewLFU(size float64, onEvict EvictCallback) *LFUDA {
-	return &LFUDA{
+func NewLFU(size float64, onEvict EvictCallback, opts ...Option) *LFUDA {
+	return newLFUDA(size, onEvict, lfuPolicy, opts)
+}
+
+// NewLRU constructs an LFUDA of the given size in bytes and uses the LRU eviction
+// policy, evicting the least recently set or fetched entry.  Hits, boosts and
+// options affecting them are ignored, as is the first Get of an entry under
+// WithScanResistance.
+func NewLRU(size float64, onEvict EvictCallback, opts ...Option) *LFUDA {
+	return newLFUDA(size, onEvict, lruPolicy, opts)
+}
+
+// NewMRU constructs an LFUDA of the given size in bytes and uses the MRU eviction
+// policy, evicting the most recently set or fetched entry.  Large cyclic scans
+// which repeatedly evict every entry under LRU and LFU keep part of the cycle
+// cached under MRU.  Hits, boosts and options affecting them are ignored.
+func NewMRU(size float64, onEvict EvictCallback, opts ...Option) *LFUDA {
+	return newLFUDA(size, onEvict, mruPolicy, opts)
+}
+
+func newLFUDA(size float64, onEvict EvictCallback, policy cachePolicy, opts []Option) *LFUDA {
+	l := &LFUDA{
 		size:     size,
 		currSize: 0,
-		items:    make(map[interface{}]*item),
-		freqs:    list.New(),
 		onEvict:  onEvict,
 		age:      0,
-		policy:   lfuPolicy,
+		policy:   policy,
+	}
+	for _, opt := range opts {
+		opt(l)
 	}
+	l.items = make(map[interface{}]*item, l.expectedEntries)
+	return l
 }
 
-// Get looks up a key's value from the cache
+// Get looks up a key's value from the cache.  nil is a valid value, so a key set
+// to nil is found with a nil value; check the bool, not the value, for misses.
 func (l *LFUDA) Get(key interface{}) (interface{}, bool) {
 	if e, ok := l.items[key]; ok {
-		l.increment(e)
+		if e.expired() {
+			l.expire(e)
+			l.counters().Misses++
+			return nil, false
+		}
+		l.touch(e)
+		if e.unconfirmed {
+			e.unconfirmed = false
+		} else {
+			l.hit(e)
+		}
+		l.counters().Hits++
 		return e.value, true
 	}
 
+	l.counters().Misses++
 	return nil, false
 }
 
-// Peek looks up a key's value from the cache but will not increment the items hit counter
+// GetWithExpiry looks up a key's value like Get, also returning when it
+// expires, or the zero time if it doesn't.
+func (l *LFUDA) GetWithExpiry(key interface{}) (value interface{}, expires time.Time, ok bool) {
+	value, ok = l.Get(key)
+	if e := l.items[key]; ok && e.expires != 0 {
+		expires = time.Unix(0, e.expires)
+	}
+	return value, expires, ok
+}
+
+// Touch makes a key expire ttl from now, or never if ttl isn't positive,
+// without counting a hit, for sliding expiration.  Returns false if the key
+// isn't in the cache or has already expired.
+func (l *LFUDA) Touch(key interface{}, ttl time.Duration) bool {
+	e, ok := l.items[key]
+	if !ok || e.expired() {
+		return false
+	}
+	e.expires = 0
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl).UnixNano()
+	}
+	return true
+}
+
+// AddHits increments a key's hit counter by hits in a single promotion, as if it
+// had been fetched that many times.  Returns false if the key is not in the cache.
+func (l *LFUDA) AddHits(key interface{}, hits float64) bool {
+	e, ok := l.items[key]
+	if ok {
+		l.touch(e)
+	}
+	if ok && e.unconfirmed && hits > 0 {
+		// the first of the hits confirms the entry
+		e.unconfirmed = false
+		hits--
+	}
+	if ok && hits > 0 && l.logBase > 0 {
+		// convert to the counter value estimating the new number of accesses
+		b := l.logBase
+		accesses := (math.Pow(b, e.hits)-1)/(b-1) + hits
+		hits = math.Log(accesses*(b-1)+1)/math.Log(b) - e.hits
+	}
+	if ok && hits > 0 {
+		l.incrementBy(e, hits)
+	}
+	return ok
+}
+
+// Peek looks up a key's value from the cache but will not increment the items hit counter.
+// Expired entries are reported missing, but left for Get or RemoveExpired to remove.
 func (l *LFUDA) Peek(key interface{}) (interface{}, bool) {
-	if e, ok := l.items[key]; ok {
+	if e, ok := l.items[key]; ok && !e.expired() {
 		return e.value, true
 	}
 	return nil, false
 }
 
-// Set adds a value to the cache.  Returns true if an eviction occurred.
+// PeekMulti looks up many keys without incrementing their hit counters,
+// returning the values of those present.
+func (l *LFUDA) PeekMulti(keys []interface{}) map[interface{}]interface{} {
+	values := make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		if e, ok := l.items[key]; ok && !e.expired() {
+			values[key] = e.value
+		}
+	}
+	return values
+}
+
+// Set adds a value to the cache.  Returns true if an eviction occurred.  A nil
+// value is stored like any other, sized as 0 bytes like an empty value.
 func (l *LFUDA) Set(key interface{}, value interface{}) bool {
-	evicted := false
-	if e, ok := l.items[key]; ok {
-		// value already exists for key.  overwrite
-		e.value = value
-		l.increment(e)
-	} else {
-		// check if we need to evict
-		// convert to bytes so we can get the size of the value
-		numBytes := calcBytes(value)
-
-		// check this value will even fit in the cache.  if not just return
-		if l.size < numBytes {
-			return false
-		}
-
-		// evict until there is room for the new item
-		for {
-			if l.currSize+numBytes > l.size {
-				l.evict()
-				evicted = true
-			} else {
-				break
-			}
+	// convert to bytes so we can get the size of the value
+	_, victims := l.set(key, value, l.sizeOf(key, value), setOptions{})
+	return len(victims) > 0
+}
+
+// SetWithBoost adds a value to the cache with its hits weighted by boost when
+// calculating its priority, so a key boosted by 2 is kept as long as an ordinary
+// key with twice its hits.  The boost sticks until the key is next set with a
+// boost.  Returns true if an eviction occurred.
+func (l *LFUDA) SetWithBoost(key interface{}, value interface{}, boost float64) bool {
+	if boost <= 0 {
+		boost = 1
+	}
+	_, victims := l.set(key, value, l.sizeOf(key, value), setOptions{boost: boost})
+	return len(victims) > 0
+}
+
+// SetWithClass adds a value to the cache in the given priority class.  Entries
+// in lower classes are always evicted before those in higher ones, with LFUDA
+// ordering and a separate age within each class.  Entries are in class 0 unless
+// set otherwise, and stay in their class until next set with a class.  Returns
+// true if an eviction occurred.
+func (l *LFUDA) SetWithClass(key interface{}, value interface{}, class int) bool {
+	_, victims := l.set(key, value, l.sizeOf(key, value), setOptions{class: class, setClass: true})
+	return len(victims) > 0
+}
+
+// SetWithSize adds a value to the cache with an explicit size in bytes rather
+// than one derived from the value, for values such as file handles or paths
+// whose real cost lives elsewhere.  Returns true if an eviction occurred.
+func (l *LFUDA) SetWithSize(key interface{}, value interface{}, numBytes float64) bool {
+	_, victims := l.set(key, value, numBytes, setOptions{})
+	return len(victims) > 0
+}
+
+// SetWithTTL adds a value to the cache which expires after ttl, on top of being
+// evicted as usual.  Get treats expired entries as missing and removes them, and
+// RemoveExpired clears them out in bulk.  Setting the key again replaces its
+// expiry.  Returns true if an eviction occurred.
+func (l *LFUDA) SetWithTTL(key interface{}, value interface{}, ttl time.Duration) bool {
+	_, victims := l.set(key, value, l.sizeOf(key, value), setOptions{ttl: ttl})
+	return len(victims) > 0
+}
+
+// SetWithVictims adds a value to the cache, returning whether it was set and the
+// entries evicted to make room for it, lowest priority first.
+func (l *LFUDA) SetWithVictims(key interface{}, value interface{}) (bool, []Victim) {
+	return l.set(key, value, l.sizeOf(key, value), setOptions{})
+}
+
+// Import adds an entry described by another cache's metadata, carrying over its
+// hits, size, boost, class and insertion time.  The entry's priority is
+// recalculated against this cache's age.  If the key is already present its
+// value and settings are replaced and the imported hits added to its own.  A zero
+// Size is derived from the value as in Set.  Returns false if the entry doesn't
+// fit in the cache.
+func (l *LFUDA) Import(info EntryInfo, value interface{}) bool {
+	size := info.Size
+	if size <= 0 {
+		size = l.sizeOf(info.Key, value)
+	}
+	set, _ := l.set(info.Key, value, size, setOptions{boost: info.Boost, class: info.Class, setClass: true})
+	if !set {
+		return false
+	}
+	e := l.items[info.Key]
+	if created := info.Created.UnixNano(); !info.Created.IsZero() && created < e.created {
+		e.created = created
+	}
+	if !info.Expires.IsZero() {
+		e.expires = info.Expires.UnixNano()
+	}
+	if info.Hits > 1 {
+		e.unconfirmed = false
+		l.incrementBy(e, info.Hits-1)
+	}
+	return true
+}
+
+// Merge folds other's entries into the cache, summing the hits of keys present
+// in both and taking the larger of the two caches' ages.  Entries are imported
+// lowest priority first, so if they don't all fit it's other's hottest entries
+// which are kept.
+func (l *LFUDA) Merge(other *LFUDA) {
+	if other == l {
+		return
+	}
+	entries := other.Entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		l.Import(entries[i], other.items[entries[i].Key].value)
+	}
+	l.RaiseAge(other.age)
+	for class, age := range other.classAges {
+		l.RaiseClassAge(int(class), age)
+	}
+}
+
+// Partition picks which of n parts a key belongs in, returning an index from 0
+// to n-1.
+type Partition func(key interface{}, n int) int
+
+// HashPartition partitions keys by their keyhash.Sum, the default for Split.
+func HashPartition(key interface{}, n int) int {
+	return int(keyhash.Sum(key) % uint64(n))
+}
+
+// Split partitions the cache's entries with partition, or HashPartition if
+// nil, into n new caches, each with an nth of the size and the same policy,
+// options, eviction callback and ages.  Entries keep their hits, so a single
+// instance can be migrated to a sharded deployment without losing what it has
+// learned.  partition must route keys as the deployment's own router does, or
+// each part will hold keys its shard never sees.  Panics if partition returns
+// an index out of range.  The cache itself is left unchanged.
+func (l *LFUDA) Split(n int, partition Partition) []*LFUDA {
+	if n < 1 {
+		return nil
+	}
+	if partition == nil {
+		partition = HashPartition
+	}
+	parts := make([]*LFUDA, n)
+	for i := range parts {
+		parts[i] = l.emptyCopy(l.size / float64(n))
+		parts[i].age = l.age
+		for class, age := range l.classAges {
+			parts[i].setClassAge(class, age)
 		}
+	}
+	// import lowest priority first, so if a part overflows its hottest entries
+	// are kept
+	entries := l.Entries()
+	for i := len(entries) - 1; i >= 0; i-- {
+		part := parts[partIndex(partition, entries[i].Key, n)]
+		part.Import(entries[i], l.items[entries[i].Key].value)
+	}
+	return parts
+}
+
+// partIndex returns the part partition picks for key, panicking if it's out of
+// range.
+func partIndex(partition Partition, key interface{}, n int) int {
+	i := partition(key, n)
+	if i < 0 || i >= n {
+		panic(fmt.Sprintf("simplelfuda: partition returned %d for %d parts", i, n))
+	}
+	return i
+}
+
+// emptyCopy creates an empty cache of the given size configured like l.
+func (l *LFUDA) emptyCopy(size float64) *LFUDA {
+	c := newLFUDA(size, l.onEvict, l.policy, nil)
+	c.observeVictim = l.observeVictim
+	c.observeAge = l.observeAge
+	c.veto = l.veto
+	c.onReject = l.onReject
+	c.maxVetoes = l.maxVetoes
+	c.secondChance = l.secondChance
+	c.resetOnSet = l.resetOnSet
+	c.countEntries = l.countEntries
+	c.maxEntries = l.maxEntries
+	c.sizer = l.sizer
+	c.defaultTTL = l.defaultTTL
+	c.ttlWeight, c.ttlHorizon = l.ttlWeight, l.ttlHorizon
+	if l.window != nil {
+		WithHitWindow(l.window.epoch, l.window.epochs)(c)
+	}
+	if w := l.statsWindow; w != nil {
+		WithStatsWindow(w.interval, w.interval*time.Duration(len(w.marks)-1))(c)
+	}
+	c.onError = l.onError
+	c.normalizeSize = l.normalizeSize
+	c.bucketWidth = l.bucketWidth
+	c.scanResistant = l.scanResistant
+	c.hotThreshold = l.hotThreshold
+	c.logBase = l.logBase
+	if l.rand != nil {
+		c.initRand()
+	}
+	if cap(l.ageHistory) > 0 {
+		c.ageHistory = make([]AgeEvent, 0, cap(l.ageHistory))
+	}
+	if l.residency != nil {
+		WithResidencyHistogram(l.residency.Bounds...)(c)
+	}
+	return c
+}
+
+// RaiseAge raises the cache age to age if it is currently lower.  Entries'
+// priorities are left as they are until they are next accessed.
+func (l *LFUDA) RaiseAge(age float64) {
+	if l.age < age {
+		event := AgeEvent{OldAge: l.age, NewAge: age, Time: time.Now()}
+		l.age = age
+		l.agedObserved(event, Victim{})
+	}
+}
+
+// ClassAges returns the ages of the priority classes other than the default
+// class 0, whose age is Age, which have aged.
+func (l *LFUDA) ClassAges() map[int]float64 {
+	ages := make(map[int]float64, len(l.classAges))
+	for class, age := range l.classAges {
+		ages[int(class)] = age
+	}
+	return ages
+}
+
+// RaiseClassAge raises the age of a priority class to age if it is currently
+// lower, as RaiseAge does the default class's.
+func (l *LFUDA) RaiseClassAge(class int, age float64) {
+	if class == 0 {
+		l.RaiseAge(age)
+	} else if l.classAge(int32(class)) < age {
+		l.setClassAge(int32(class), age)
+	}
+}
 
+// setOptions carries per-entry settings for set.  The zero value keeps an
+// existing entry's settings, or the defaults for a new one.
+type setOptions struct {
+	boost    float64
+	class    int
+	setClass bool
+	ttl      time.Duration
+}
+
+func (l *LFUDA) set(key interface{}, value interface{}, numBytes float64, o setOptions) (bool, []Victim) {
+	if l.countEntries {
+		numBytes = 1
+	}
+	if l.sets++; l.sets == recentSets {
+		l.sets /= 2
+		l.refused /= 2
+	}
+	// check this value will even fit in the cache.  if not just return
+	if l.size < numBytes {
+		l.refused++
+		l.counters().Rejected++
+		l.rejected(key, value, RejectTooLarge)
+		return false, nil
+	}
+	if l.window != nil {
+		l.rollWindow()
+	}
+
+	e, ok := l.items[key]
+	if ok && e.expired() {
+		// replace an expired entry with a new one
+		l.expire(e)
+		ok = false
+	}
+	if ok {
+		// value already exists for key.  detach it while making room so it
+		// can't be picked for eviction itself
+		l.remEntry(e.freqNode, e)
+		l.currSize -= e.size
+	}
+
+	// evict all the victims needed to make room for the new item in one pass
+	victims := l.evict(l.currSize+numBytes-l.size, l.excessEntries(ok))
+
+	if !ok {
 		// value doesn't exist.  insert
-		e := new(item)
-		e.size = numBytes
+		e = new(item)
 		e.key = key
-		e.value = value
 		l.items[key] = e
-		l.currSize += numBytes
-		l.increment(e)
 	}
-	return evicted
+	if !ok || l.resetOnSet {
+		// a new entry, or one replaced as if it were new
+		e.hits, e.boost, e.class = 0, 1, 0
+		e.unconfirmed = l.scanResistant
+		e.spared = false
+		e.created = time.Now().UnixNano()
+		if l.window != nil {
+			delete(l.window.counts, e)
+		}
+	}
+	if o.boost > 0 {
+		e.boost = o.boost
+	}
+	if o.setClass {
+		e.class = int32(o.class)
+	}
+	e.expires = 0
+	if ttl := o.ttl; ttl > 0 || l.defaultTTL > 0 {
+		if ttl <= 0 {
+			ttl = l.defaultTTL
+		}
+		e.expires = time.Now().Add(ttl).UnixNano()
+	}
+	e.value = value
+	e.size = numBytes
+	l.touch(e)
+	l.currSize += numBytes
+	l.increment(e)
+	return true, victims
 }
 
 // Len returns the number of items in the cache.
@@ -146,133 +1136,809 @@ func (l *LFUDA) Len() int {
 	return len(l.items)
 }
 
-// Size returns the number of items in the cache.
+// Size returns the total size of the items in the cache in bytes, or in entries
+// if it was created WithEntryCounting.
 func (l *LFUDA) Size() float64 {
 	return l.currSize
 }
 
-func (l *LFUDA) evict() bool {
-	if place := l.freqs.Front(); place != nil {
-		for entry := range place.Value.(*listEntry).entries {
-			// set age to the value of the evicted object
-			// cache age should be less than or equal to the minimum key value in the cache
-			if l.age < entry.priorityKey {
-				l.age = entry.priorityKey
-			}
+// Capacity returns the size the cache holds up to.
+func (l *LFUDA) Capacity() float64 {
+	return l.size
+}
+
+// Evict evicts the n lowest priority entries, returning them lowest priority
+// first.  The cache ages as if they had been evicted to make room for a Set.
+func (l *LFUDA) Evict(n int) []Victim {
+	victims := l.NextVictims(n)
+	l.evictVictims(victims)
+	return victims
+}
+
+// SetPlan describes what a Set would do.
+type SetPlan struct {
+	// Admitted is whether the value would be stored.  Values are only refused
+	// for being larger than the cache.
+	Admitted bool
+	// EvictBytes is how many bytes would have to be freed to make room
+	EvictBytes float64
+	// Victims are the entries which would be evicted, lowest priority first
+	Victims []Victim
+}
+
+// WouldSet reports what setting key to value would do, without changing the
+// cache.  Ties are evicted oldest first, so the victims are those a Set would
+// evict, unless spared by an eviction veto.
+func (l *LFUDA) WouldSet(key interface{}, value interface{}) SetPlan {
+	if l.countEntries {
+		return l.wouldSet(key, 1)
+	}
+	return l.wouldSet(key, l.calcSize(key, value))
+}
+
+func (l *LFUDA) wouldSet(key interface{}, numBytes float64) SetPlan {
+	if l.size < numBytes {
+		return SetPlan{}
+	}
+	plan := SetPlan{Admitted: true}
+	used := l.currSize
+	existing, ok := l.items[key]
+	if ok {
+		used -= existing.size
+	}
+	plan.EvictBytes = math.Max(0, used+numBytes-l.size)
+	excess := l.excessEntries(ok)
+	if plan.EvictBytes == 0 && excess <= 0 {
+		return plan
+	}
+	freed := 0.0
+	l.ascend(func(e *item) bool {
+		if e == existing {
+			return true
+		}
+		plan.Victims = append(plan.Victims, Victim{Key: e.key, Value: e.value, Size: e.size, Priority: e.priorityKey})
+		freed += e.size
+		return freed < plan.EvictBytes || len(plan.Victims) < excess
+	})
+	return plan
+}
+
+// Explanation describes why setting a key would or wouldn't keep it cached.
+type Explanation struct {
+	// Admitted is whether the value would be stored
+	Admitted bool
+	// Reason summarizes the outcome in words
+	Reason string
+	// Priority is the key's priority after the Set, against Age
+	Priority float64
+	// Age is the age of the key's class after any evictions
+	Age float64
+	// Victims are the entries which would be evicted, lowest priority first
+	Victims []Victim
+	// NextToGo reports whether the key would then be the first entry evicted,
+	// or tied for it, as happens to a new key when the others have been read
+	NextToGo bool
+	// Next is the entry which would be evicted first after the key, if any
+	Next *Victim
+}
+
+// Explain reports why setting key to a value of the given size would or
+// wouldn't keep it cached, to answer "why isn't my key being cached?".  A value
+// is only refused for being larger than the cache, but is evicted again soon if
+// its priority stays below the other entries'.
+func (l *LFUDA) Explain(key interface{}, size float64) Explanation {
+	if l.countEntries {
+		size = 1
+	}
+	plan := l.wouldSet(key, size)
+	if !plan.Admitted {
+		return Explanation{Reason: fmt.Sprintf("refused: size %v is larger than the cache's %v", size, l.size)}
+	}
+	x := Explanation{Admitted: true, Victims: plan.Victims}
+
+	// the key's priority is calculated after the evictions have aged the cache
+	e := item{key: key, size: size, hits: 1, boost: 1, lastAccess: l.clock + 1}
+	existing, ok := l.items[key]
+	if ok {
+		e.hits, e.boost, e.class = existing.hits+1, existing.boost, existing.class
+	}
+	if l.defaultTTL > 0 {
+		e.expires = time.Now().Add(l.defaultTTL).UnixNano()
+	}
+	x.Age = l.classAge(e.class)
+	for _, v := range plan.Victims {
+		if c := l.items[v.Key].class; c == e.class && v.Priority > x.Age {
+			x.Age = v.Priority
+		}
+	}
+	x.Priority = l.policy(l, &e, x.Age) - l.ttlPenalty(&e)
+	if l.bucketWidth > 0 {
+		x.Priority = math.Floor(x.Priority/l.bucketWidth) * l.bucketWidth
+	}
+	e.priorityKey = x.Priority
 
-			// since entries is a map this is a random key in the lowest frequency node
-			l.Remove(entry.key)
+	skip := len(plan.Victims)
+	l.ascend(func(other *item) bool {
+		if other == existing {
 			return true
 		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		x.Next = &Victim{Key: other.key, Value: other.value, Size: other.size, Priority: other.priorityKey}
+		x.NextToGo = other.class > e.class || other.class == e.class && other.priorityKey >= e.priorityKey
+		return false
+	})
+	if x.Next == nil {
+		x.NextToGo = true
 	}
-	return false
+
+	if len(x.Victims) > 0 {
+		x.Reason = fmt.Sprintf("admitted by evicting %d entries with priorities up to %v", len(x.Victims), x.Victims[len(x.Victims)-1].Priority)
+	} else {
+		x.Reason = "admitted into free space"
+	}
+	if x.NextToGo {
+		x.Reason += fmt.Sprintf(", but at priority %v against age %v it would be the next entry evicted", x.Priority, x.Age)
+	}
+	return x
+}
+
+// NextVictims returns the n entries which would be evicted next, lowest
+// priority first, without evicting them, so their values can be persisted or
+// refreshed ahead of time.  Entries with equal priority are returned oldest
+// first, the order they're evicted in.
+func (l *LFUDA) NextVictims(n int) []Victim {
+	if n <= 0 {
+		return nil
+	}
+	var victims []Victim
+	l.ascend(func(e *item) bool {
+		victims = append(victims, Victim{Key: e.key, Value: e.value, Size: e.size, Priority: e.priorityKey})
+		return len(victims) < n
+	})
+	return victims
+}
+
+// PurgePercent evicts the lowest priority p percent of the entries, rounded up,
+// returning them lowest priority first, to shed memory under pressure while
+// keeping the hot core of the cache.  The cache ages as with Evict.
+func (l *LFUDA) PurgePercent(p float64) []Victim {
+	return l.Evict(int(math.Ceil(float64(len(l.items)) * math.Min(p, 100) / 100)))
+}
+
+// PurgePercentBytes evicts the lowest priority entries until at least p percent
+// of the bytes in use have been freed, returning them lowest priority first.
+func (l *LFUDA) PurgePercentBytes(p float64) []Victim {
+	return l.evictBytes(l.currSize * math.Min(p, 100) / 100)
+}
+
+// Resize changes the size of the cache in bytes, evicting the lowest priority
+// entries until they fit if it shrinks, and returns the entries evicted lowest
+// priority first.  The cache ages as if they had been evicted to make room for
+// a Set.
+func (l *LFUDA) Resize(size float64) []Victim {
+	l.size = size
+	return l.evictBytes(l.currSize - size)
+}
+
+// EvictBytes evicts the lowest priority entries until at least n bytes of the
+// cache are free, returning them lowest priority first.
+func (l *LFUDA) EvictBytes(n float64) []Victim {
+	return l.evictBytes(l.currSize + n - l.size)
+}
+
+// excessEntries returns how many entries must be evicted to make room for a key
+// under the entry cap.
+func (l *LFUDA) excessEntries(existing bool) int {
+	if l.maxEntries == 0 || existing {
+		return 0
+	}
+	return len(l.items) + 1 - l.maxEntries
+}
+
+// evictBytes evicts the lowest priority entries until at least n bytes have
+// been freed, then calls the eviction callback for each of them.
+func (l *LFUDA) evictBytes(n float64) []Victim {
+	return l.evict(n, 0)
+}
+
+// evict evicts the lowest priority entries until at least n bytes and m entries
+// have been freed, then calls the eviction callback for each of them.
+func (l *LFUDA) evict(n float64, m int) []Victim {
+	if n <= 0 && m <= 0 {
+		return nil
+	}
+	if l.timings != nil {
+		// callbacks time themselves, and are subtracted out
+		start, callbacks := time.Now(), l.timings.Callbacks
+		defer func() {
+			l.timings.Eviction += time.Since(start) - (l.timings.Callbacks - callbacks)
+		}()
+	}
+	var victims, spared []Victim
+	var chances []*item
+	freed := 0.0
+	l.ascend(func(e *item) bool {
+		if l.secondChance && !e.spared && len(chances) < maxChances {
+			chances = append(chances, e)
+			return true
+		}
+		v := Victim{Key: e.key, Value: e.value, Size: e.size, Priority: e.priorityKey}
+		if len(spared) < l.maxVetoes && l.vetoed(v) {
+			spared = append(spared, v)
+			return true
+		}
+		victims = append(victims, v)
+		freed += v.Size
+		return freed < n || len(victims) < m
+	})
+	// fall back on the spared entries if nothing else was left
+	for (freed < n || len(victims) < m) && len(chances) > 0 {
+		e := chances[0]
+		chances = chances[1:]
+		victims = append(victims, Victim{Key: e.key, Value: e.value, Size: e.size, Priority: e.priorityKey})
+		freed += e.size
+	}
+	for i := 0; (freed < n || len(victims) < m) && i < len(spared); i++ {
+		victims = append(victims, spared[i])
+		freed += spared[i].Size
+	}
+	for _, e := range chances {
+		e.spared = true
+		l.demote(e)
+	}
+	l.evictVictims(victims)
+	return victims
+}
+
+// vetoed reports whether the eviction veto spares v.  A panicking veto spares
+// nothing.
+func (l *LFUDA) vetoed(v Victim) (spare bool) {
+	if l.onError != nil {
+		defer l.recoverCallback("eviction veto")
+	}
+	return l.veto(v)
+}
+
+// evictVictims removes the given entries, ages the cache and calls the eviction
+// callback for each.
+func (l *LFUDA) evictVictims(victims []Victim) {
+	var now int64
+	if l.residency != nil && len(victims) > 0 {
+		now = time.Now().UnixNano()
+	}
+	var raises []ageRaise
+	for _, v := range victims {
+		e := l.items[v.Key]
+		l.counters().Evictions++
+		l.stats.EvictedBytes += v.Size
+		if l.residency != nil {
+			l.residency.observe(time.Duration(now - e.created))
+		}
+		// set age to the value of the evicted object
+		// cache age should be less than or equal to the minimum key value in the cache
+		if old := l.classAge(e.class); old < v.Priority {
+			l.setClassAge(e.class, v.Priority)
+			if cap(l.ageHistory) > 0 || l.observeAge != nil {
+				event := ageEvent(old, e)
+				l.recordAge(event)
+				if l.observeAge != nil {
+					raises = append(raises, ageRaise{event, v})
+				}
+			}
+		}
+		l.unlink(e)
+	}
+	var start time.Time
+	if l.timings != nil {
+		start = time.Now()
+	}
+	for _, v := range victims {
+		l.observed(v)
+	}
+	for _, r := range raises {
+		l.agedObserved(r.event, r.victim)
+	}
+	for _, v := range victims {
+		l.evicted(v.Key, v.Value)
+	}
+	if l.timings != nil {
+		l.timings.Callbacks += time.Since(start)
+	}
+}
+
+// evicted calls the eviction callback, if any.
+func (l *LFUDA) evicted(key, value interface{}) {
+	if l.onEvict == nil {
+		return
+	}
+	if l.onError != nil {
+		defer l.recoverCallback("eviction callback")
+	}
+	l.onEvict(key, value)
+}
+
+// rejected calls the reject callback, if any.
+func (l *LFUDA) rejected(key, value interface{}, reason RejectReason) {
+	if l.onReject == nil {
+		return
+	}
+	if l.onError != nil {
+		defer l.recoverCallback("reject callback")
+	}
+	l.onReject(key, value, reason)
+}
+
+// observed calls the victim observer, if any.
+func (l *LFUDA) observed(v Victim) {
+	if l.observeVictim == nil {
+		return
+	}
+	if l.onError != nil {
+		defer l.recoverCallback("victim observer")
+	}
+	l.observeVictim(v)
+}
+
+// ageRaise is an increase in age waiting to be passed to the age observer.
+type ageRaise struct {
+	event  AgeEvent
+	victim Victim
+}
+
+// agedObserved calls the age observer, if any.
+func (l *LFUDA) agedObserved(event AgeEvent, v Victim) {
+	if l.observeAge == nil {
+		return
+	}
+	if l.onError != nil {
+		defer l.recoverCallback("age observer")
+	}
+	l.observeAge(event, v)
+}
+
+// recoverCallback passes a panicking callback's panic to the error hook.  Must
+// be deferred.
+func (l *LFUDA) recoverCallback(callback string) {
+	if r := recover(); r != nil {
+		l.onError(&CallbackPanic{Callback: callback, Value: r, Stack: debug.Stack()})
+	}
+}
+
+// Ascend calls fn for each entry from the lowest priority to the highest, which
+// is the order they would be evicted in, until fn returns false.  Entries with
+// equal priority are visited in the order they reached it.  fn must not modify
+// the cache.
+func (l *LFUDA) Ascend(fn func(key, value interface{}) bool) {
+	l.ascend(func(e *item) bool {
+		return fn(e.key, e.value)
+	})
+}
+
+// ascend calls fn for each entry in eviction order, lowest priority first, until
+// fn returns false.  Entries sharing a frequency node are visited in the order
+// they reached it.  fn must not modify the cache.
+func (l *LFUDA) ascend(fn func(e *item) bool) {
+	for place := l.freqs.front; place != nil; place = place.next {
+		for entry := place.first; entry != nil; entry = entry.next {
+			if !fn(entry) {
+				return
+			}
+		}
+	}
+}
+
+// touch records an access to an entry.
+func (l *LFUDA) touch(e *item) {
+	l.clock++
+	e.lastAccess = l.clock
+}
+
+// hit counts a Get of an entry.
+func (l *LFUDA) hit(e *item) {
+	if l.logBase > 0 {
+		if l.rand.Float64() < math.Pow(l.logBase, -e.hits) {
+			l.increment(e)
+		}
+		return
+	}
+	if l.hotThreshold > 0 {
+		if lead := e.priorityKey - l.classAge(e.class); lead > l.hotThreshold {
+			p := l.hotThreshold / lead
+			if l.rand.Float64() < p {
+				l.incrementBy(e, 1/p)
+			}
+			return
+		}
+	}
+	l.increment(e)
 }
 
 func (l *LFUDA) increment(e *item) {
+	l.incrementBy(e, 1)
+}
+
+func (l *LFUDA) incrementBy(e *item, hits float64) {
+	// must update item's hits before updating priorityKey
+	if l.window != nil {
+		l.rollWindow()
+		e.hits = l.window.count(e, hits)
+	} else {
+		e.hits += hits
+	}
+	e.priorityKey = l.policy(l, e, l.classAge(e.class)) - l.ttlPenalty(e)
+	if l.bucketWidth > 0 {
+		e.priorityKey = math.Floor(e.priorityKey/l.bucketWidth) * l.bucketWidth
+	}
+
+	steps := l.place(e)
+	l.promotions++
+	l.promotionSteps += steps
+	if steps > l.maxPromotionSteps {
+		l.maxPromotionSteps = steps
+	}
+}
+
+// place moves an entry up from its frequency node, or the front of the list if
+// it has none, to the node for its priority key, returning the number of nodes
+// stepped over.  Entries can only move down by going to the front.
+func (l *LFUDA) place(e *item) int {
 	oldNode := e.freqNode
 	cursor := e.freqNode
-	var nextPlace *list.Element
+	var nextPlace *listEntry
 
+	if cursor != nil && cursor.holds(e) {
+		return 0
+	}
+	if oldNode != nil && len(l.walks) > 0 {
+		l.moving(e)
+	}
+	if front := l.freqs.front; front != nil && front.above(e) {
+		// going to the front, as entries do under MRU
+		cursor = nil
+	} else if back := l.freqs.back; back != nil && back != cursor && !back.above(e) {
+		// going to the back, as entries do under LRU, so skip the walk
+		if back.holds(e) {
+			cursor = back.prev
+		} else {
+			cursor = back
+		}
+	}
 	if cursor == nil {
 		// new entry
-		nextPlace = l.freqs.Front()
+		nextPlace = l.freqs.front
 	} else {
-		nextPlace = cursor.Next()
+		nextPlace = cursor.next
 	}
 
-	// must update item's hits before updating priorityKey
-	e.hits++
-	e.priorityKey = l.policy(e, l.age)
-
 	// move up until hits is < next frequency node's
+	steps := 0
 	for {
 		// we've reached the back or the point where the next frequency
 		// node is greater than the item's hits count.  Either way, create
 		// a new frequency node
-		if nextPlace == nil || nextPlace.Value.(*listEntry).priorityKey > e.priorityKey {
+		if nextPlace == nil || nextPlace.above(e) {
 			// create a new frequency node
 			li := new(listEntry)
+			li.class = e.class
 			li.priorityKey = e.priorityKey
-			li.entries = make(map[*item]byte)
-			if cursor != nil {
-				nextPlace = l.freqs.InsertAfter(li, cursor)
-			} else {
-				nextPlace = l.freqs.PushFront(li)
-			}
+			l.freqs.insertAfter(li, cursor)
+			nextPlace = li
 			break
-		} else if nextPlace.Value.(*listEntry).priorityKey == e.priorityKey {
+		} else if nextPlace.holds(e) {
 			// found the right place
 			break
-		} else if e.priorityKey > nextPlace.Value.(*listEntry).priorityKey {
+		} else {
 			// keep searching
 			cursor = nextPlace
-			nextPlace = cursor.Next()
+			nextPlace = cursor.next
+			steps++
 		}
 	}
 
-	// set the right frequency node in the master list
-	e.freqNode = nextPlace
-	nextPlace.Value.(*listEntry).entries[e] = 1
-
-	// clenaup
+	// move to the right frequency node, only dropping the old one once the
+	// new one is linked after it
 	if oldNode != nil {
-		// remove from old position
-		l.remEntry(oldNode, e)
+		oldNode.remove(e)
+	}
+	nextPlace.push(e)
+	if oldNode != nil && oldNode.len == 0 {
+		l.freqs.remove(oldNode)
+	}
+	return steps
+}
+
+// demote takes one hit's worth off an entry's priority, without otherwise
+// aging it, and moves it down to its new place.
+func (l *LFUDA) demote(e *item) {
+	l.remEntry(e.freqNode, e)
+	worth := l.policy(l, e, 0)
+	e.hits--
+	e.priorityKey -= worth - l.policy(l, e, 0)
+	if l.bucketWidth > 0 {
+		e.priorityKey = math.Floor(e.priorityKey/l.bucketWidth) * l.bucketWidth
 	}
+	l.place(e)
 }
 
 // Purge will completely clear the LFUDA cache
 func (l *LFUDA) Purge() {
-	for k, v := range l.items {
-		if l.onEvict != nil {
-			l.onEvict(k, v.value)
-		}
-		delete(l.items, k)
-	}
+	items := l.items
+	l.items = make(map[interface{}]*item, l.expectedEntries)
 	l.age = 0
+	l.classAges = nil
 	l.currSize = 0
-	l.freqs.Init()
+	l.freqs = freqList{}
+	l.restartWalks()
+	if l.window != nil {
+		l.window.counts = make(map[*item]*windowCounts)
+	}
+	// the cache is already empty, should a callback panic
+	for k, v := range items {
+		l.evicted(k, v.value)
+	}
+}
+
+// Renormalize scales every entry's hits and priority, and the cache age, by
+// factor, which must be between 0 and 1.  Relative priorities are preserved but
+// history built up under a previous size or workload carries less weight, so new
+// entries can catch up with old ones sooner.  Useful after shrinking or
+// partially purging the cache.
+func (l *LFUDA) Renormalize(factor float64) {
+	if factor <= 0 || factor >= 1 {
+		return
+	}
+	for _, e := range l.items {
+		e.hits *= factor
+		e.priorityKey *= factor
+	}
+	for place := l.freqs.front; place != nil; place = place.next {
+		place.priorityKey *= factor
+	}
+	l.age *= factor
+	for class := range l.classAges {
+		l.classAges[class] *= factor
+	}
 }
 
 // Contains checks if a key is in the cache, without updating the recent-ness
-// or deleting it for being stale.
+// or deleting it for being stale.  Expired entries are reported missing.
 func (l *LFUDA) Contains(key interface{}) (ok bool) {
-	_, ok = l.items[key]
-	return ok
+	e, ok := l.items[key]
+	return ok && !e.expired()
+}
+
+// ContainsMulti checks whether each of keys is in the cache, without updating
+// their recent-ness.  The result is in the same order as keys.
+func (l *LFUDA) ContainsMulti(keys []interface{}) []bool {
+	present := make([]bool, len(keys))
+	for i, key := range keys {
+		e, ok := l.items[key]
+		present[i] = ok && !e.expired()
+	}
+	return present
 }
 
 // Remove removes the provided key from the cache, returning if the
 // key was contained
 func (l *LFUDA) Remove(key interface{}) bool {
 	if item, ok := l.items[key]; ok {
-		if l.onEvict != nil {
-			l.onEvict(item.key, item.value)
+		l.unlink(item)
+		l.evicted(item.key, item.value)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired removes every expired entry, calling the eviction callback for
+// each, and returns how many were removed.  It takes time proportional to the
+// size of the cache, so run it periodically rather than on every access.
+func (l *LFUDA) RemoveExpired() int {
+	now := time.Now().UnixNano()
+	var expired []*item
+	for _, e := range l.items {
+		if e.expires != 0 && e.expires <= now {
+			expired = append(expired, e)
 		}
-		delete(l.items, key)
-		l.remEntry(item.freqNode, item)
+	}
+	for _, e := range expired {
+		l.expire(e)
+	}
+	return len(expired)
+}
 
-		// subtract current size of the cache by the size of the evicted item
-		l.currSize -= item.size
+// expire removes an expired entry.
+func (l *LFUDA) expire(e *item) {
+	l.unlink(e)
+	l.counters().Expirations++
+	l.evicted(e.key, e.value)
+}
 
-		return true
+// expired reports whether the entry has expired, only reading the clock for
+// entries with an expiry.
+func (e *item) expired() bool {
+	return e.expires != 0 && e.expires <= time.Now().UnixNano()
+}
+
+func (l *LFUDA) unlink(item *item) {
+	delete(l.items, item.key)
+	l.remEntry(item.freqNode, item)
+	if l.window != nil {
+		delete(l.window.counts, item)
+	}
+
+	// subtract current size of the cache by the size of the evicted item
+	l.currSize -= item.size
+}
+
+func (l *LFUDA) remEntry(place *listEntry, entry *item) {
+	if len(l.walks) > 0 {
+		l.moving(entry)
+	}
+	place.remove(entry)
+	if place.len == 0 {
+		l.freqs.remove(place)
 	}
-	return false
 }
 
-func (l *LFUDA) remEntry(place *list.Element, entry *item) {
-	entries := place.Value.(*listEntry).entries
-	delete(entries, entry)
-	if len(entries) == 0 {
-		l.freqs.Remove(place)
+// Shape describes the frequency list, to detect workloads which degrade it and
+// tune bucketing or the policy accordingly.  Promotions walk the list from an
+// entry's node to its new position, so long paths make every hit expensive.
+type Shape struct {
+	// Nodes is the number of frequency nodes
+	Nodes int
+	// EntriesPerNode is the average number of entries per node
+	EntriesPerNode float64
+	// LargestNode is the number of entries in the largest node
+	LargestNode int
+	// Promotions counts the moves of entries up the list since the cache was
+	// created, one per insertion or counted hit
+	Promotions uint64
+	// MeanPromotionPath is the average number of nodes stepped over per
+	// promotion
+	MeanPromotionPath float64
+	// MaxPromotionPath is the most nodes stepped over by one promotion
+	MaxPromotionPath int
+}
+
+// Shape returns statistics about the frequency list.  It visits every node, so
+// takes time proportional to the number of nodes.
+func (l *LFUDA) Shape() Shape {
+	s := Shape{
+		Nodes:            l.freqs.len,
+		Promotions:       l.promotions,
+		MaxPromotionPath: l.maxPromotionSteps,
+	}
+	if s.Nodes > 0 {
+		s.EntriesPerNode = float64(len(l.items)) / float64(s.Nodes)
+	}
+	if l.promotions > 0 {
+		s.MeanPromotionPath = float64(l.promotionSteps) / float64(l.promotions)
 	}
+	for place := l.freqs.front; place != nil; place = place.next {
+		if place.len > s.LargestNode {
+			s.LargestNode = place.len
+		}
+	}
+	return s
+}
+
+// Overhead estimates the memory the cache spends on bookkeeping, on top of the
+// keys and values themselves.
+type Overhead struct {
+	// Items is the bytes of the per-entry metadata structs
+	Items float64
+	// Index is the bytes of the key to entry map
+	Index float64
+	// Nodes is the bytes of the frequency list's nodes
+	Nodes float64
+	// Total is the sum of the above
+	Total float64
+	// PerEntry is Total divided by the number of entries
+	PerEntry float64
 }
 
-// Keys returns a slice of the keys in the cache ordered by frequency
+// approximate costs of a map slot beyond its key and value, and of an empty
+// map, assuming buckets of 8 slots with a tophash byte each, an overflow
+// pointer per bucket and an average load of 80%
+const (
+	mapLoad       = 0.8
+	mapSlotExtra  = 1 + 8.0/8
+	mapHeaderSize = 48
+)
+
+// Overhead estimates the memory spent on bookkeeping from the sizes of the
+// internal structs and the average cost of map slots, to answer how much RAM
+// caching N entries costs beyond the entries' own sizes.  Keys and values held
+// in interfaces may cost more for their own allocations, which aren't counted.
+func (l *LFUDA) Overhead() Overhead {
+	n := float64(len(l.items))
+	var o Overhead
+	o.Items = n * float64(unsafe.Sizeof(item{}))
+	o.Index = mapHeaderSize + n*(float64(unsafe.Sizeof(interface{}(nil))+unsafe.Sizeof(&item{}))+mapSlotExtra)/mapLoad
+	o.Nodes = float64(l.freqs.len) * float64(unsafe.Sizeof(listEntry{}))
+	o.Total = o.Items + o.Index + o.Nodes
+	if n > 0 {
+		o.PerEntry = o.Total / n
+	}
+	return o
+}
+
+// Health describes the state of a cache, for readiness probes.
+type Health struct {
+	// Problems lists any violated internal invariants, such as entries missing
+	// from the frequency list or a size which doesn't match the entries'.
+	// Healthy caches have none.
+	Problems []string
+	// Utilization is the fraction of the cache's capacity in use.
+	Utilization float64
+	// RefusalRate is the fraction of roughly the last thousand sets refused
+	// for being larger than the cache.
+	RefusalRate float64
+}
+
+// Healthy reports whether the cache's invariants hold.
+func (h Health) Healthy() bool {
+	return len(h.Problems) == 0
+}
+
+// Health checks the cache's invariants and reports its utilization and recent
+// refusal rate.  It visits every entry, so takes time proportional to the size
+// of the cache.
+func (l *LFUDA) Health() Health {
+	var h Health
+	if l.size > 0 {
+		h.Utilization = l.currSize / l.size
+	}
+	if l.sets > 0 {
+		h.RefusalRate = float64(l.refused) / float64(l.sets)
+	}
+
+	entries := 0
+	size := 0.0
+	var prev *listEntry
+	for node := l.freqs.front; node != nil; node = node.next {
+		if node.first == nil {
+			h.Problems = append(h.Problems, "empty frequency node")
+		}
+		if prev != nil && (node.class < prev.class || node.class == prev.class && node.priorityKey <= prev.priorityKey) {
+			h.Problems = append(h.Problems, fmt.Sprintf("frequency node %v out of order", node.priorityKey))
+		}
+		prev = node
+		linked := 0
+		for e := node.first; e != nil; e = e.next {
+			linked++
+			entries++
+			size += e.size
+			if l.items[e.key] != e {
+				h.Problems = append(h.Problems, fmt.Sprintf("key %v in frequency list but not cache", e.key))
+			}
+			if e.freqNode != node || !node.holds(e) {
+				h.Problems = append(h.Problems, fmt.Sprintf("key %v in wrong frequency node", e.key))
+			}
+		}
+		if linked != node.len {
+			h.Problems = append(h.Problems, fmt.Sprintf("frequency node %v links %d entries, not %d", node.priorityKey, linked, node.len))
+		}
+	}
+	if entries != len(l.items) {
+		h.Problems = append(h.Problems, fmt.Sprintf("%d entries in frequency list, %d in cache", entries, len(l.items)))
+	}
+	if math.Abs(size-l.currSize) > 1e-9*math.Max(1, l.currSize) {
+		h.Problems = append(h.Problems, fmt.Sprintf("size %v doesn't match entries' %v", l.currSize, size))
+	}
+	return h
+}
+
+// Keys returns a slice of the keys in the cache in priority order, highest
+// first.
 func (l *LFUDA) Keys() []interface{} {
 	keys := make([]interface{}, len(l.items))
 	i := 0
-	for node := l.freqs.Back(); node != nil; node = node.Prev() {
-		for ent := range node.Value.(*listEntry).entries {
+	for node := l.freqs.back; node != nil; node = node.prev {
+		for ent := node.last; ent != nil; ent = ent.prev {
 			keys[i] = ent.key
 			i++
 		}
@@ -280,33 +1946,164 @@ func (l *LFUDA) Keys() []interface{} {
 	return keys
 }
 
+// Inspect returns a key's hits, priority and other metadata without counting it
+// as a hit.
+func (l *LFUDA) Inspect(key interface{}) (EntryInfo, bool) {
+	e, ok := l.items[key]
+	if !ok {
+		return EntryInfo{}, false
+	}
+	return e.info(), true
+}
+
+// Entries returns every entry in the cache with its value and metadata, highest
+// priority first like Keys.  Entries sharing a priority are ordered most
+// recently accessed first.  Importing them lowest priority first into an empty
+// cache restores the cache, so they can be persisted however suits.
+func (l *LFUDA) Entries() []EntryInfo {
+	entries := make([]EntryInfo, 0, len(l.items))
+	for node := l.freqs.back; node != nil; node = node.prev {
+		start := len(entries)
+		for ent := node.last; ent != nil; ent = ent.prev {
+			entries = append(entries, ent.info())
+		}
+		same := entries[start:]
+		sort.Slice(same, func(i, j int) bool {
+			return same[i].LastAccess > same[j].LastAccess
+		})
+	}
+	return entries
+}
+
+func (e *item) info() EntryInfo {
+	info := EntryInfo{
+		Key:        e.key,
+		Value:      e.value,
+		Hits:       e.hits,
+		Priority:   e.priorityKey,
+		Size:       e.size,
+		Boost:      e.boost,
+		Class:      int(e.class),
+		LastAccess: e.lastAccess,
+		Created:    time.Unix(0, e.created),
+	}
+	if e.expires != 0 {
+		info.Expires = time.Unix(0, e.expires)
+	}
+	return info
+}
+
 // Age returns the cache age factor
 func (l *LFUDA) Age() float64 {
 	return l.age
 }
 
-// Ki = Ci * Fi + L where C is set to 1
-func lfudaPolicy(element *item, cacheAge float64) float64 {
-	return element.hits + cacheAge
+// AgeHistory returns the recorded aging events, oldest first.  Nothing is
+// recorded unless the cache was created WithAgeHistory.
+func (l *LFUDA) AgeHistory() []AgeEvent {
+	events := make([]AgeEvent, 0, len(l.ageHistory))
+	if len(l.ageHistory) == cap(l.ageHistory) {
+		events = append(events, l.ageHistory[l.ageNext:]...)
+		return append(events, l.ageHistory[:l.ageNext]...)
+	}
+	return append(events, l.ageHistory...)
 }
 
-// Ki = Fi * Ci / Si + L where C is set to 1
-func gdsfPolicy(element *item, cacheAge float64) float64 {
-	return (element.hits / element.size) + cacheAge
+// Residency returns a copy of the histogram of how long evicted entries were
+// resident, and false unless the cache was created WithResidencyHistogram.
+func (l *LFUDA) Residency() (Histogram, bool) {
+	if l.residency == nil {
+		return Histogram{}, false
+	}
+	h := *l.residency
+	h.Bounds = append([]time.Duration(nil), h.Bounds...)
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h, true
 }
 
-func lfuPolicy(element *item, cacheAge float64) float64 {
-	return element.hits
+func ageEvent(old float64, e *item) AgeEvent {
+	return AgeEvent{
+		OldAge:  old,
+		NewAge:  e.priorityKey,
+		Class:   int(e.class),
+		KeyHash: keyhash.Sum(e.key),
+		Time:    time.Now(),
+	}
 }
 
-func calcBytes(value interface{}) float64 {
-	// if the value is binary
-	if valBytes, ok := value.([]byte); ok {
-		return float64(len(valBytes))
-	} else if valBytes := binary.Size(value); valBytes != -1 {
-		return float64(valBytes)
-	} else {
-		// otherwise use the default format
-		return float64(len([]byte(fmt.Sprintf("%v", value.(interface{})))))
+func (l *LFUDA) recordAge(event AgeEvent) {
+	if cap(l.ageHistory) == 0 {
+		return
+	}
+	if len(l.ageHistory) < cap(l.ageHistory) {
+		l.ageHistory = append(l.ageHistory, event)
+		return
+	}
+	l.ageHistory[l.ageNext] = event
+	l.ageNext = (l.ageNext + 1) % len(l.ageHistory)
+}
+
+func (l *LFUDA) classAge(class int32) float64 {
+	if class == 0 {
+		return l.age
+	}
+	return l.classAges[class]
+}
+
+func (l *LFUDA) setClassAge(class int32, age float64) {
+	if class == 0 {
+		l.age = age
+		return
+	}
+	if l.classAges == nil {
+		l.classAges = make(map[int32]float64)
 	}
+	l.classAges[class] = age
+}
+
+// ttlPenalty returns how much the entry's remaining TTL lowers its priority.
+func (l *LFUDA) ttlPenalty(e *item) float64 {
+	if l.ttlWeight == 0 || e.expires == 0 {
+		return 0
+	}
+	left := time.Duration(e.expires - time.Now().UnixNano())
+	if left >= l.ttlHorizon {
+		return 0
+	}
+	if left < 0 {
+		left = 0
+	}
+	return l.ttlWeight * (1 - float64(left)/float64(l.ttlHorizon))
+}
+
+// Ki = Ci * Fi + L where C is the entry's boost, 1 by default
+func lfudaPolicy(l *LFUDA, element *item, cacheAge float64) float64 {
+	return element.boost*element.hits + cacheAge
+}
+
+// Ki = Fi * Ci / Si + L where C is the entry's boost, 1 by default, and S its
+// normalized size.  Sizes below 1, such as those of nil values, count as 1 so
+// priorities stay finite, as do normalized sizes which aren't positive.
+func gdsfPolicy(l *LFUDA, element *item, cacheAge float64) float64 {
+	size := math.Max(element.size, 1)
+	if l.normalizeSize != nil {
+		if size = l.normalizeSize(size); !(size > 0) {
+			size = 1
+		}
+	}
+	return (element.boost * element.hits / size) + cacheAge
+}
+
+func lfuPolicy(l *LFUDA, element *item, cacheAge float64) float64 {
+	return element.boost * element.hits
+}
+
+// Ki = Ti where T is the logical time of the entry's last set or hit
+func lruPolicy(l *LFUDA, element *item, cacheAge float64) float64 {
+	return float64(element.lastAccess)
+}
+
+// Ki = -Ti, so the most recently used entry is evicted first
+func mruPolicy(l *LFUDA, element *item, cacheAge float64) float64 {
+	return -float64(element.lastAccess)
 }
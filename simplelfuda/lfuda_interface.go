@@ -1,5 +1,7 @@
 package simplelfuda
 
+import "time"
+
 // LFUDACache is the interface for simple LFUDA cache.
 type LFUDACache interface {
 	// Adds a value to the cache, returns true if an eviction occurred and
@@ -19,13 +21,13 @@ type LFUDACache interface {
 	// Removes a key from the cache.
 	Remove(key interface{}) bool
 
-	// Returns a slice of the keys in the cache, from oldest to newest.
+	// Returns a slice of the keys in the cache, highest priority first.
 	Keys() []interface{}
 
 	// Returns the number of items in the cache.
 	Len() int
 
-	// Returns the current size of the cache in bytes.
+	// Returns the current size of the cache in bytes, or entries if counted.
 	Size() float64
 
 	// Clears all cache entries.
@@ -34,3 +36,156 @@ type LFUDACache interface {
 	// Returns current age factor of the cache
 	Age() float64
 }
+
+// The interfaces below group the capabilities added to LFUDA since
+// LFUDACache, so code needing them can ask for just what it uses and
+// implementations of LFUDACache needn't grow with every addition.
+
+// Prioritizer sets entries with a say in their priority.
+type Prioritizer interface {
+	// Adds a value to the cache with its hits weighted by boost, returns true if
+	// an eviction occurred.
+	SetWithBoost(key, value interface{}, boost float64) bool
+
+	// Adds a value to the cache in a priority class, returns true if an eviction
+	// occurred.
+	SetWithClass(key, value interface{}, class int) bool
+
+	// Adds a value to the cache with an explicit size in bytes, returns true if
+	// an eviction occurred.
+	SetWithSize(key, value interface{}, size float64) bool
+
+	// Adds hits to a key's counter in a single promotion.
+	AddHits(key interface{}, hits float64) bool
+}
+
+// Expirer expires entries after a TTL.
+type Expirer interface {
+	// Adds a value to the cache which expires after ttl, returns true if an
+	// eviction occurred.
+	SetWithTTL(key, value interface{}, ttl time.Duration) bool
+
+	// Returns key's value like Get along with when it expires.
+	GetWithExpiry(key interface{}) (value interface{}, expires time.Time, ok bool)
+
+	// Changes when a key expires without counting a hit.
+	Touch(key interface{}, ttl time.Duration) bool
+
+	// Removes every expired entry, returns how many were removed.
+	RemoveExpired() int
+}
+
+// Evicter evicts entries on demand and reports what was evicted.
+type Evicter interface {
+	// Adds a value to the cache, returns whether it was set and the entries
+	// evicted to make room for it.
+	SetWithVictims(key, value interface{}) (bool, []Victim)
+
+	// Evicts the n lowest priority entries.
+	Evict(n int) []Victim
+
+	// Evicts the lowest priority entries until at least n bytes are free.
+	EvictBytes(n float64) []Victim
+
+	// Returns the n entries which would be evicted next without evicting them.
+	NextVictims(n int) []Victim
+
+	// Evicts the lowest priority p percent of the entries.
+	PurgePercent(p float64) []Victim
+
+	// Evicts the lowest priority entries holding p percent of the bytes in use.
+	PurgePercentBytes(p float64) []Victim
+
+	// Changes the size of the cache, evicting entries until they fit.
+	Resize(size float64) []Victim
+}
+
+// Planner predicts what sets would do.
+type Planner interface {
+	// Reports what setting key to value would do without changing the cache.
+	WouldSet(key, value interface{}) SetPlan
+
+	// Explains why setting key would or wouldn't keep it cached.
+	Explain(key interface{}, size float64) Explanation
+}
+
+// Inspector reads entries and their metadata without counting hits.
+type Inspector interface {
+	// Checks if each key exists in cache without updating the recent-ness.
+	ContainsMulti(keys []interface{}) []bool
+
+	// Returns the values of the present keys without updating their recent-ness.
+	PeekMulti(keys []interface{}) map[interface{}]interface{}
+
+	// Returns a key's metadata without updating the "recently used"-ness of the key.
+	Inspect(key interface{}) (EntryInfo, bool)
+
+	// Returns every entry with its value and metadata, highest priority first.
+	Entries() []EntryInfo
+
+	// Walks entries in eviction order, lowest priority first.
+	Ascend(fn func(key, value interface{}) bool)
+}
+
+// Migrator moves entries and ages between caches.
+type Migrator interface {
+	// Adds an entry carrying over another cache's metadata, returns whether it
+	// was set.
+	Import(info EntryInfo, value interface{}) bool
+
+	// Raises the age factor of the cache to age if it is lower.
+	RaiseAge(age float64)
+
+	// Returns the ages of the priority classes other than class 0.
+	ClassAges() map[int]float64
+
+	// Raises the age factor of a priority class to age if it is lower.
+	RaiseClassAge(class int, age float64)
+
+	// Scales hits, priorities and age by factor.
+	Renormalize(factor float64)
+}
+
+// Diagnoser reports on the cache's activity and internals.
+type Diagnoser interface {
+	// Returns the cache's activity counters and current age.
+	Stats() Stats
+
+	// Zeroes the activity counters.
+	ResetStats()
+
+	// Returns the activity since the last call, with the current age.
+	StatsDelta() Stats
+
+	// Returns the activity over roughly the last d, if windowed.
+	WindowStats(d time.Duration) (Stats, bool)
+
+	// Returns the recorded aging events, oldest first.
+	AgeHistory() []AgeEvent
+
+	// Returns the histogram of how long evicted entries were resident.
+	Residency() (Histogram, bool)
+
+	// Checks the cache's invariants and reports its utilization.
+	Health() Health
+
+	// Estimates the memory spent on bookkeeping.
+	Overhead() Overhead
+
+	// Returns statistics about the frequency list.
+	Shape() Shape
+
+	// Returns the time spent in operations since the last call.
+	TakeTimings() Timings
+}
+
+var (
+	_ LFUDACache  = (*LFUDA)(nil)
+	_ Prioritizer = (*LFUDA)(nil)
+	_ Expirer     = (*LFUDA)(nil)
+	_ Evicter     = (*LFUDA)(nil)
+	_ Planner     = (*LFUDA)(nil)
+	_ Inspector   = (*LFUDA)(nil)
+	_ Migrator    = (*LFUDA)(nil)
+	_ Diagnoser   = (*LFUDA)(nil)
+)
@@ -2,7 +2,16 @@ package simplelfuda
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
 	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/bparli/lfuda-go/internal/keyhash"
 )
 
 func TestLFUDA(t *testing.T) {
@@ -102,6 +111,42 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+func TestWalkKeys(t *testing.T) {
+	c := NewLFUDA(100, nil)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, key)
+	}
+
+	w := c.WalkKeys()
+	keys, done := w.Next(nil, 4)
+	if !done || !w.Clean() || !reflect.DeepEqual(keys, c.Keys()) {
+		t.Errorf("a walk should visit the keys in order: %v, %v", keys, done)
+	}
+	w.Close()
+
+	w = c.WalkKeys()
+	keys, done = w.Next(nil, 2)
+	if done || !reflect.DeepEqual(keys, []interface{}{"d", "c"}) {
+		t.Fatalf("bad first chunk: %v, %v", keys, done)
+	}
+	// a moves up past the walk, and b moves as it's next
+	c.Get("a")
+	c.Get("b")
+	keys, done = w.Next(keys, 2)
+	if !done || w.Clean() {
+		t.Errorf("the walk should be done and record the moves: %v, %v", keys, done)
+	}
+	moved := w.Moved()
+	sort.Slice(moved, func(i, j int) bool { return moved[i].(string) < moved[j].(string) })
+	if !reflect.DeepEqual(moved, []interface{}{"a", "b"}) {
+		t.Errorf("bad moved keys: %v", moved)
+	}
+	w.Close()
+	if len(c.walks) != 0 {
+		t.Errorf("closed walks shouldn't be kept in step")
+	}
+}
+
 func TestPurge(t *testing.T) {
 	c := NewLFUDA(3, nil)
 	c.Set("a", "a")
@@ -162,6 +207,30 @@ func TestReSet(t *testing.T) {
 	}
 }
 
+func TestResetOnSet(t *testing.T) {
+	for _, reset := range []bool{false, true} {
+		var opts []Option
+		if reset {
+			opts = append(opts, WithResetOnSet())
+		}
+		c := NewLFUDA(2, nil, opts...)
+		c.SetWithClass("a", "a", 1)
+		c.Get("a")
+		c.Get("a")
+		c.Set("a", "b")
+		info, _ := c.Inspect("a")
+		if reset && (info.Hits != 1 || info.Priority != 1 || info.Class != 0) {
+			t.Errorf("a should have been reset: %+v", info)
+		}
+		if !reset && (info.Hits != 4 || info.Priority != 4 || info.Class != 1) {
+			t.Errorf("a should have kept its hits and class: %+v", info)
+		}
+		if v, _ := c.Peek("a"); v != "b" {
+			t.Errorf("the value should be replaced: %v", v)
+		}
+	}
+}
+
 func TestEvict(t *testing.T) {
 	c := NewLFUDA(3, nil)
 	c.Set("a", "a")
@@ -314,6 +383,64 @@ func TestEvictLFU(t *testing.T) {
 	}
 }
 
+func TestEvictLRU(t *testing.T) {
+	c := NewLRU(3, nil)
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Set("c", "c")
+
+	// popularity counts for nothing, only recency
+	for i := 0; i < 10; i++ {
+		c.Get("a")
+	}
+	c.Get("b")
+	c.Set("d", "d")
+	if c.Contains("c") || !c.Contains("a") {
+		t.Errorf("c was least recently used: %v", c.Keys())
+	}
+	c.Set("e", "e")
+	if c.Contains("a") {
+		t.Errorf("a was least recently used: %v", c.Keys())
+	}
+	if keys := c.Keys(); fmt.Sprint(keys) != "[e d b]" {
+		t.Errorf("keys should be most recently used first: %v", keys)
+	}
+	if s := c.Shape(); s.MaxPromotionPath != 0 {
+		t.Errorf("entries should move straight to the back: %+v", s)
+	}
+}
+
+func TestEvictMRU(t *testing.T) {
+	c := NewMRU(3, nil)
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Set("c", "c")
+	c.Get("a")
+	c.Set("d", "d")
+	if c.Contains("a") || !c.Contains("c") {
+		t.Errorf("a was most recently used: %v", c.Keys())
+	}
+
+	// a cyclic scan larger than the cache keeps hitting part of it
+	c = NewMRU(10, nil)
+	hits := 0
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 20; i++ {
+			if _, ok := c.Get(i); ok {
+				hits++
+			} else {
+				c.SetWithSize(i, i, 1)
+			}
+		}
+	}
+	if hits < 50 {
+		t.Errorf("too few hits for a cyclic scan: %d", hits)
+	}
+	if h := c.Health(); !h.Healthy() {
+		t.Errorf("unhealthy: %v", h.Problems)
+	}
+}
+
 func TestCalcBytes(t *testing.T) {
 	a := make([]int16, 0, 1)       // 0
 	b := [...]int8{2, 3, 5, 7, 11} // 5
@@ -348,3 +475,1461 @@ func TestCalcBytes(t *testing.T) {
 		}
 	}
 }
+
+type sizeNode struct {
+	name  string
+	score int32
+	next  *sizeNode
+}
+
+func TestCalcBytesDeep(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		size  float64
+	}{
+		{-42, 3},
+		{uint(7), 1},
+		{3.5, 8},
+		{[]int{1, 2, 3}, 24},
+		{[]string{"ab", "cde"}, 5},
+		{map[string][]byte{"k": []byte("value")}, 6},
+		{struct {
+			a, b string
+			c    int16
+		}{"x", "yz", 1}, 5},
+		{&sizeNode{name: "abc", score: 1}, 7},
+		{[]interface{}{"ab", int8(1), nil}, 3},
+	}
+	for _, test := range tests {
+		if size := calcBytes(test.value); size != test.size {
+			t.Errorf("%#v should be %v bytes, not %v", test.value, test.size, size)
+		}
+	}
+
+	// shared and cyclic data is counted once
+	a := &sizeNode{name: "a"}
+	b := &sizeNode{name: "bb", next: a}
+	a.next = b
+	if size := calcBytes(a); size != 11 {
+		t.Errorf("cycle should be counted once: %v", size)
+	}
+	m := map[string]interface{}{"k": "v"}
+	m["self"] = m
+	s := []interface{}{"ab", nil}
+	s[1] = s
+	if size := calcBytes(m); size != 6 {
+		t.Errorf("a map holding itself should be counted once: %v", size)
+	}
+	if size := calcBytes(s); size != 2 {
+		t.Errorf("a slice holding itself should be counted once: %v", size)
+	}
+
+	for _, value := range []interface{}{"str", []byte("bytes"), 12345, int64(1), [4]int32{}} {
+		if allocs := testing.AllocsPerRun(10, func() { calcBytes(value) }); allocs != 0 {
+			t.Errorf("sizing %T shouldn't allocate: %v", value, allocs)
+		}
+	}
+}
+
+func TestAddHits(t *testing.T) {
+	c := NewLFUDA(2, nil)
+	c.Set("a", "a")
+	c.Set("b", "b")
+
+	if !c.AddHits("a", 10) {
+		t.Errorf("AddHits should find key a")
+	}
+	if c.AddHits("z", 10) {
+		t.Errorf("AddHits should not find key z")
+	}
+	if c.Keys()[0] != "a" {
+		t.Errorf("key a should be the most frequently used")
+	}
+
+	// b is the least frequently used so it gets evicted
+	c.Set("c", "c")
+	if !c.Contains("a") || c.Contains("b") {
+		t.Errorf("key b should have been evicted")
+	}
+}
+
+func TestSetWithSize(t *testing.T) {
+	evicted := 0
+	c := NewLFUDA(10, func(k interface{}, v interface{}) { evicted++ })
+	c.SetWithSize("a", "/tmp/a", 4)
+	c.SetWithSize("b", "/tmp/b", 4)
+	if c.Size() != 8 {
+		t.Errorf("size should come from the given sizes: %f", c.Size())
+	}
+
+	if c.SetWithSize("c", "/tmp/c", 11) || c.Contains("c") {
+		t.Errorf("values larger than the cache should not be set")
+	}
+
+	// overwrite a with a larger value, which must evict b rather than a itself
+	if !c.SetWithSize("a", "/tmp/a2", 8) {
+		t.Errorf("growing a should have evicted")
+	}
+	if v, _ := c.Peek("a"); v != "/tmp/a2" || c.Contains("b") || evicted != 1 {
+		t.Errorf("b should have been evicted for the new a: %v", c.Keys())
+	}
+	if c.Size() != 8 {
+		t.Errorf("overwrite should replace the old size: %f", c.Size())
+	}
+
+	// overwrites keep their hits
+	c.Set("a", "a")
+	if c.Size() != 1 || c.items["a"].hits != 3 {
+		t.Errorf("bad overwrite: %f, %f", c.Size(), c.items["a"].hits)
+	}
+}
+
+func TestSetWithVictims(t *testing.T) {
+	var evicted []interface{}
+	var c *LFUDA
+	c = NewLFUDA(4, func(k interface{}, v interface{}) {
+		// callbacks run once the whole batch is out of the cache
+		if c.Len() != 1 {
+			t.Errorf("callback ran before the batch was evicted: %d", c.Len())
+		}
+		evicted = append(evicted, k)
+	})
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Set("c", "c")
+	c.Set("d", "d")
+	c.Get("c")
+	c.Get("d")
+
+	set, victims := c.SetWithVictims("big", "bbb")
+	if !set || len(victims) != 3 || len(evicted) != 3 {
+		t.Fatalf("should have evicted three entries: %v", victims)
+	}
+	// a and b share the lowest node, then c or d
+	for i, v := range victims[:2] {
+		if v.Key != "a" && v.Key != "b" || v.Size != 1 || v.Priority != 1 || evicted[i] != v.Key {
+			t.Errorf("bad victim: %+v", v)
+		}
+	}
+	if v := victims[2]; v.Key != "c" && v.Key != "d" || v.Priority != 2 {
+		t.Errorf("bad victim: %+v", v)
+	}
+	if c.Age() != 2 || c.Size() != 4 || c.Len() != 2 {
+		t.Errorf("bad cache after eviction: %f, %f, %d", c.Age(), c.Size(), c.Len())
+	}
+
+	if set, victims := c.SetWithVictims("huge", "hhhhh"); set || victims != nil {
+		t.Errorf("values larger than the cache should not be set")
+	}
+}
+
+func TestEvictN(t *testing.T) {
+	var evicted []interface{}
+	c := NewLFUDA(10, func(k interface{}, v interface{}) { evicted = append(evicted, k) })
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, k)
+	}
+	c.AddHits("c", 1)
+	c.AddHits("d", 2)
+
+	victims := c.Evict(3)
+	if len(victims) != 3 || len(evicted) != 3 || victims[2].Key != "c" {
+		t.Fatalf("bad victims: %v", victims)
+	}
+	if !c.Contains("d") || c.Len() != 1 || c.Size() != 1 || c.Age() != 2 {
+		t.Errorf("only d should be left: %v, age %f", c.Keys(), c.Age())
+	}
+
+	if victims := c.Evict(5); len(victims) != 1 || c.Len() != 0 {
+		t.Errorf("Evict should stop when the cache is empty: %v", victims)
+	}
+	if victims := c.Evict(0); victims != nil {
+		t.Errorf("nothing should be evicted")
+	}
+}
+
+func TestEvictBytes(t *testing.T) {
+	c := NewLFUDA(10, nil)
+	c.Set("a", "aaaa")
+	c.Set("b", "bb")
+	c.Set("c", "cc")
+	c.AddHits("b", 1)
+	c.AddHits("c", 2)
+
+	// 2 bytes are already free
+	if victims := c.EvictBytes(2); victims != nil {
+		t.Errorf("nothing should need evicting: %v", victims)
+	}
+	victims := c.EvictBytes(5)
+	if len(victims) != 1 || victims[0].Key != "a" || c.Size() != 4 {
+		t.Errorf("a should have been evicted: %v", victims)
+	}
+	victims = c.EvictBytes(7)
+	if len(victims) != 1 || victims[0].Key != "b" || c.Size() != 2 {
+		t.Errorf("b should have been evicted: %v", victims)
+	}
+	if victims = c.EvictBytes(100); len(victims) != 1 || c.Len() != 0 {
+		t.Errorf("everything should be evicted: %v", victims)
+	}
+}
+
+func TestPurgePercent(t *testing.T) {
+	c := NewLFUDA(100, nil)
+	for i := 0; i < 10; i++ {
+		c.SetWithSize(i, i, float64(i+1))
+		c.AddHits(i, float64(i))
+	}
+	// a quarter of 10 entries rounds up to 3
+	victims := c.PurgePercent(25)
+	if len(victims) != 3 || victims[0].Key != 0 || victims[2].Key != 2 || c.Len() != 7 {
+		t.Errorf("the 3 coldest entries should have been evicted: %v", victims)
+	}
+
+	// 49 bytes in use, so at least 24.5 must go: 3 through 7's 4+5+6+7+8
+	victims = c.PurgePercentBytes(50)
+	if len(victims) != 5 || victims[4].Key != 7 || c.Size() != 19 {
+		t.Errorf("3 through 7 should have been evicted: %v", victims)
+	}
+	if victims := c.PurgePercent(0); len(victims) != 0 {
+		t.Errorf("nothing should be evicted: %v", victims)
+	}
+	if c.PurgePercent(200); c.Len() != 0 {
+		t.Errorf("everything should be evicted: %v", c.Keys())
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := NewLFUDA(4, nil)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, k)
+	}
+	c.Get("b")
+	c.Get("d")
+	victims := c.Resize(2)
+	if len(victims) != 2 || victims[0].Key != "a" || victims[1].Key != "c" || c.Size() != 2 {
+		t.Errorf("a and c should have been evicted: %v", victims)
+	}
+	if c.Age() != 1 {
+		t.Errorf("shrinking should age the cache: %v", c.Age())
+	}
+	if victims := c.Resize(3); len(victims) != 0 {
+		t.Errorf("growing should evict nothing: %v", victims)
+	}
+	c.Set("e", "e")
+	if c.Len() != 3 {
+		t.Errorf("the cache should hold 3 entries now: %v", c.Keys())
+	}
+}
+
+func TestSetWithBoost(t *testing.T) {
+	c := NewLFUDA(2, nil)
+	c.SetWithBoost("critical", "c", 3)
+	c.Set("ordinary", "o")
+	c.Get("ordinary")
+	c.Get("ordinary")
+
+	// ordinary has 3 hits against critical's 1, but critical is boosted 3x
+	if p := c.items["critical"].priorityKey; p != 3 {
+		t.Errorf("bad boosted priority: %f", p)
+	}
+	c.Get("ordinary")
+	c.Set("x", "x")
+	if c.Contains("critical") {
+		t.Errorf("critical should be evicted once ordinary is more popular")
+	}
+
+	c = NewLFUDA(2, nil)
+	c.SetWithBoost("critical", "c", 3)
+	c.Set("ordinary", "o")
+	c.Get("ordinary")
+	c.Set("x", "x")
+	if !c.Contains("critical") || c.Contains("ordinary") {
+		t.Errorf("the boost should keep critical over ordinary: %v", c.Keys())
+	}
+
+	// plain overwrites keep the boost, boosting again replaces it
+	c.Set("critical", "c")
+	if e := c.items["critical"]; e.boost != 3 || e.priorityKey != 3*e.hits+c.Age() {
+		t.Errorf("boost should be kept: %f", e.boost)
+	}
+	c.SetWithBoost("critical", "c", 0.5)
+	if e := c.items["critical"]; e.priorityKey != 0.5*e.hits+c.Age() {
+		t.Errorf("boost should be replaced: %f", e.priorityKey)
+	}
+	c.Get("x")
+	c.Get("x")
+	if c.Keys()[0] != "x" {
+		t.Errorf("critical should have dropped below x: %v", c.Keys())
+	}
+}
+
+func TestSetWithClass(t *testing.T) {
+	const (
+		bestEffort = -1
+		critical   = 1
+	)
+	c := NewLFUDA(3, nil)
+	c.SetWithClass("c", "c", critical)
+	c.Set("n", "n")
+	c.SetWithClass("b", "b", bestEffort)
+	for i := 0; i < 10; i++ {
+		c.Get("b")
+		c.Get("n")
+	}
+
+	// however popular, lower classes go first
+	if keys := c.Keys(); keys[0] != "c" || keys[2] != "b" {
+		t.Errorf("keys should be ordered by class: %v", keys)
+	}
+	c.Set("x", "x")
+	if c.Contains("b") || c.classAge(bestEffort) != 11 || c.Age() != 0 {
+		t.Errorf("b should have been evicted, aging only its class")
+	}
+	c.Set("y", "y")
+	if c.Contains("x") || !c.Contains("n") || c.Age() != 1 {
+		t.Errorf("x should be evicted within class 0: %v", c.Keys())
+	}
+	c.Set("z", "z")
+	c.Set("w", "w")
+	if !c.Contains("c") {
+		t.Errorf("critical entries should outlast the rest: %v", c.Keys())
+	}
+
+	// entries can move between classes
+	c.SetWithClass("n", "n", bestEffort)
+	c.Set("v", "v")
+	if c.Contains("n") {
+		t.Errorf("n should have been evicted after being demoted: %v", c.Keys())
+	}
+
+	c.Purge()
+	if c.classAge(bestEffort) != 0 {
+		t.Errorf("Purge should reset class ages")
+	}
+}
+
+func TestScanResistance(t *testing.T) {
+	c := NewLFUDA(3, nil, WithScanResistance())
+	c.Set("hot", "h")
+	c.Get("hot")
+	c.Get("hot")
+	if hits := c.items["hot"].hits; hits != 2 {
+		t.Errorf("the first Get should not count: %f", hits)
+	}
+
+	// a one-pass scan touches each key once
+	c.Set("s1", "1")
+	c.Set("s2", "2")
+	c.Get("s1")
+	c.Get("s2")
+	if hits := c.items["s1"].hits; hits != 1 {
+		t.Errorf("scanned keys should not be promoted: %f", hits)
+	}
+
+	c.Set("s3", "3")
+	if !c.Contains("hot") {
+		t.Errorf("hot should outlast the scan")
+	}
+
+	// the first of a batch of hits confirms the entry
+	c.AddHits("s3", 3)
+	if hits := c.items["s3"].hits; hits != 3 {
+		t.Errorf("bad hits after AddHits: %f", hits)
+	}
+
+	c = NewLFUDA(3, nil)
+	c.Set("a", "a")
+	c.Get("a")
+	if hits := c.items["a"].hits; hits != 2 {
+		t.Errorf("Gets should count without scan resistance: %f", hits)
+	}
+}
+
+func TestProbabilisticHits(t *testing.T) {
+	c := NewLFUDA(2, nil, WithProbabilisticHits(100))
+	c.rand = rand.New(rand.NewSource(1))
+	c.Set("viral", "v")
+	for i := 0; i < 99; i++ {
+		c.Get("viral")
+	}
+	if hits := c.items["viral"].hits; hits != 100 {
+		t.Errorf("hits under the threshold should always count: %f", hits)
+	}
+
+	moves := 0
+	node := c.items["viral"].freqNode
+	const gets = 100000
+	for i := 0; i < gets; i++ {
+		c.Get("viral")
+		if n := c.items["viral"].freqNode; n != node {
+			node = n
+			moves++
+		}
+	}
+	// the expected hits are unchanged
+	if hits := c.items["viral"].hits; hits < gets*0.7 || hits > gets*1.3 {
+		t.Errorf("hits should stay roughly in line with gets: %f", hits)
+	}
+	if moves > gets/10 {
+		t.Errorf("viral key moved too often: %d", moves)
+	}
+}
+
+func TestLogCounters(t *testing.T) {
+	c := NewLFUDA(2, nil, WithLogCounters(2))
+	c.rand = rand.New(rand.NewSource(1))
+	c.Set("a", "a")
+	for i := 0; i < 1<<16; i++ {
+		c.Get("a")
+	}
+	// 2^16 accesses should be counted in about 16 hits
+	if hits := c.items["a"].hits; hits < 12 || hits > 20 {
+		t.Errorf("hits should be logarithmic in the accesses: %f", hits)
+	}
+
+	c.Set("b", "b")
+	c.AddHits("b", 6)
+	if hits := c.items["b"].hits; math.Abs(hits-3) > 1e-9 {
+		t.Errorf("7 accesses should be counted as 3 hits: %f", hits)
+	}
+
+	if NewLFUDA(2, nil, WithLogCounters(1)).logBase != 0 {
+		t.Errorf("bases of 1 or less should be ignored")
+	}
+}
+
+func TestRenormalize(t *testing.T) {
+	c := NewLFUDA(3, nil)
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Set("c", "c")
+	c.AddHits("a", 7)
+	c.AddHits("b", 3)
+	c.Set("d", "d")
+	before := c.Keys()
+
+	c.Renormalize(0.5)
+	if c.Age() != 0.5 || c.items["a"].hits != 4 || c.items["a"].priorityKey != 4 {
+		t.Errorf("bad renormalized values: %f, %f", c.Age(), c.items["a"].hits)
+	}
+	for i, k := range c.Keys() {
+		if before[i] != k {
+			t.Errorf("relative order should be preserved: %v != %v", before, c.Keys())
+		}
+	}
+
+	// new entries land in the right place among the rescaled ones
+	c.Set("e", "e")
+	c.Get("e")
+	if c.Contains("d") || c.items["e"].priorityKey != 3 {
+		t.Errorf("d should have been evicted: %v", c.Keys())
+	}
+
+	c.Renormalize(2)
+	if c.Age() != 1 {
+		t.Errorf("factors outside (0, 1) should be ignored: %f", c.Age())
+	}
+}
+
+func TestAscend(t *testing.T) {
+	c := NewLFUDA(10, nil)
+	for i, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, k)
+		c.AddHits(k, float64(3-i))
+	}
+	var keys []interface{}
+	c.Ascend(func(key, value interface{}) bool {
+		if key != value {
+			t.Errorf("bad value for %v: %v", key, value)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if fmt.Sprint(keys) != "[d c b a]" {
+		t.Errorf("keys should be in eviction order: %v", keys)
+	}
+
+	keys = keys[:0]
+	c.Ascend(func(key, value interface{}) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if fmt.Sprint(keys) != "[d c]" {
+		t.Errorf("Ascend should stop when fn returns false: %v", keys)
+	}
+
+	// Ascend doesn't count as hits or evict anything
+	victims := c.Evict(1)
+	if victims[0].Key != "d" {
+		t.Errorf("d should still be the next victim")
+	}
+}
+
+func TestAgeHistory(t *testing.T) {
+	c := NewLFUDA(1, nil, WithAgeHistory(3))
+	for i := 0; i < 5; i++ {
+		c.Set(i, i)
+		c.Get(i)
+	}
+	events := c.AgeHistory()
+	if len(events) != 3 {
+		t.Fatalf("only the last 3 events should be kept: %v", events)
+	}
+	// each eviction raised the age by 2, from 2 to 4 and so on
+	for i, e := range events {
+		if e.OldAge != float64(2*i+2) || e.NewAge != float64(2*i+4) || e.KeyHash != keyhash.Sum(i+1) || e.Time.IsZero() {
+			t.Errorf("bad event %d: %+v", i, e)
+		}
+	}
+
+	// removals don't age the cache
+	c.Remove(4)
+	if events := c.AgeHistory(); len(events) != 3 || events[2].NewAge != 8 {
+		t.Errorf("only aging should be recorded: %v", events)
+	}
+
+	if events := NewLFUDA(1, nil).AgeHistory(); len(events) != 0 {
+		t.Errorf("nothing should be recorded by default")
+	}
+}
+
+func TestAgeObserver(t *testing.T) {
+	var events []AgeEvent
+	var victims []Victim
+	c := NewLFUDA(1, nil, WithAgeObserver(func(e AgeEvent, v Victim) {
+		events = append(events, e)
+		victims = append(victims, v)
+	}))
+	c.Set("a", "a")
+	c.Get("a")
+	c.Set("b", "b")
+	if len(events) != 1 || events[0].OldAge != 0 || events[0].NewAge != 2 || events[0].KeyHash != keyhash.Sum("a") {
+		t.Fatalf("bad events: %+v", events)
+	}
+	if victims[0].Key != "a" || victims[0].Priority != 2 {
+		t.Errorf("bad victim: %+v", victims[0])
+	}
+
+	c.Set("c", "c")
+	if len(events) != 2 || events[1].NewAge != 3 {
+		t.Fatalf("b should have raised the age to 3: %+v", events)
+	}
+	// lowering the age isn't an increase
+	c.RaiseAge(10)
+	c.RaiseAge(5)
+	if len(events) != 3 || events[2].OldAge != 3 || events[2].NewAge != 10 || victims[2].Key != nil {
+		t.Errorf("RaiseAge should be reported once: %+v %+v", events, victims)
+	}
+}
+
+func TestEvictionVeto(t *testing.T) {
+	var asked []interface{}
+	c := NewLFUDA(3, nil, WithEvictionVeto(func(v Victim) bool {
+		asked = append(asked, v.Key)
+		return v.Key == "a" || v.Key == "b"
+	}, 1))
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Set("c", "c")
+
+	// a is spared, and the veto is only asked once
+	c.Set("d", "d")
+	if !c.Contains("a") || c.Contains("b") || fmt.Sprint(asked) != "[a]" {
+		t.Errorf("b should have been evicted in place of a: %v, asked %v", c.Keys(), asked)
+	}
+
+	// spared entries go if there's nothing else
+	c = NewLFUDA(1, nil, WithEvictionVeto(func(Victim) bool { return true }, 5))
+	c.Set("a", "a")
+	if !c.Set("b", "b") || c.Contains("a") || c.Size() != 1 {
+		t.Errorf("a should have been evicted anyway: %v", c.Keys())
+	}
+}
+
+func TestSecondChance(t *testing.T) {
+	c := NewLFUDA(10, nil, WithSecondChance())
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+	}
+	// the first few candidates are spared, taking a hit off their priority
+	c.Set("x", "x")
+	if c.Contains(maxChances) {
+		t.Fatalf("%d should have been evicted: %v", maxChances, c.Keys())
+	}
+	for i := 0; i < maxChances; i++ {
+		if info, ok := c.Inspect(i); !ok || info.Hits != 0 || info.Priority != 0 {
+			t.Errorf("%d should have been demoted: %+v", i, info)
+		}
+	}
+	// but only once
+	c.Set("y", "y")
+	if c.Contains(0) || !c.Contains(1) {
+		t.Errorf("0 should have been evicted the second time: %v", c.Keys())
+	}
+	if h := c.Health(); !h.Healthy() {
+		t.Errorf("unhealthy: %v", h.Problems)
+	}
+
+	// spared entries go if there's nothing else
+	c = NewLFUDA(1, nil, WithSecondChance())
+	c.Set("a", "a")
+	if !c.Set("b", "b") || c.Contains("a") || c.Size() != 1 {
+		t.Errorf("a should have been evicted anyway: %v", c.Keys())
+	}
+}
+
+func TestResidency(t *testing.T) {
+	c := NewLFUDA(2, nil, WithResidencyHistogram(time.Millisecond, time.Hour))
+	c.Set("a", "a")
+	c.Set("b", "b")
+	// pretend a has been resident for a minute
+	c.items["a"].created -= int64(time.Minute)
+	c.Get("b")
+	c.Set("c", "c")
+	c.Remove("b")
+
+	h, ok := c.Residency()
+	if !ok || h.Count != 1 || h.Counts[1] != 1 || h.Sum < time.Minute {
+		t.Errorf("a's eviction should be recorded in the second bucket: %+v", h)
+	}
+	// the result is a copy
+	h.Counts[0] = 10
+	if h, _ := c.Residency(); h.Counts[0] != 0 {
+		t.Errorf("Residency should return a copy")
+	}
+
+	if _, ok := NewLFUDA(2, nil).Residency(); ok {
+		t.Errorf("residency shouldn't be recorded by default")
+	}
+	if h, _ := NewLFUDA(2, nil, WithResidencyHistogram()).Residency(); len(h.Counts) != len(DefaultResidencyBounds)+1 {
+		t.Errorf("default bounds should be used: %+v", h)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	start := time.Now()
+	c := NewLFUDA(10, nil)
+	c.SetWithBoost("a", "aa", 2)
+	c.Set("b", "b")
+	c.Set("c", "c")
+	c.Get("b")
+	c.Get("c")
+
+	info, ok := c.Inspect("a")
+	if !ok || info.Value != "aa" || info.Hits != 1 || info.Priority != 2 || info.Size != 2 || info.Boost != 2 || info.LastAccess != 1 {
+		t.Errorf("bad info for a: %+v", info)
+	}
+	if info.Created.Before(start) || info.Created.After(time.Now()) {
+		t.Errorf("bad creation time for a: %v", info.Created)
+	}
+	if info, _ := c.Inspect("a"); info.Hits != 1 {
+		t.Errorf("Inspect should not count as a hit")
+	}
+	if _, ok := c.Inspect("z"); ok {
+		t.Errorf("z is not in the cache")
+	}
+
+	// all share priority 2, most recently accessed first
+	var keys []interface{}
+	for _, e := range c.Entries() {
+		keys = append(keys, e.Key)
+	}
+	if fmt.Sprint(keys) != "[c b a]" {
+		t.Errorf("entries should be ordered by priority then recency: %v", keys)
+	}
+
+	c.Get("a")
+	entries := c.Entries()
+	if entries[0].Key != "a" || entries[1].Key != "c" {
+		t.Errorf("a should now be first: %+v", entries)
+	}
+
+	// restore the cache from its entries
+	restored := NewLFUDA(10, nil)
+	for i := len(entries) - 1; i >= 0; i-- {
+		restored.Import(entries[i], entries[i].Value)
+	}
+	for _, e := range entries {
+		got, ok := restored.Inspect(e.Key)
+		if !ok || got.Value != e.Value || got.Hits != e.Hits || got.Priority != e.Priority || !got.Created.Equal(e.Created) {
+			t.Errorf("%v not restored: %+v != %+v", e.Key, got, e)
+		}
+	}
+}
+
+func TestPeekMulti(t *testing.T) {
+	c := NewLFUDA(10, nil)
+	c.Set("a", "a")
+	c.Set("b", "b")
+	values := c.PeekMulti([]interface{}{"a", "b", "z"})
+	if len(values) != 2 || values["a"] != "a" || values["b"] != "b" {
+		t.Errorf("bad values: %v", values)
+	}
+	if info, _ := c.Inspect("a"); info.Hits != 1 {
+		t.Errorf("PeekMulti should not count as a hit")
+	}
+}
+
+func TestContainsMulti(t *testing.T) {
+	c := NewLFUDA(10, nil)
+	c.Set("a", "a")
+	c.Set("c", "c")
+	if present := c.ContainsMulti([]interface{}{"a", "b", "c"}); fmt.Sprint(present) != "[true false true]" {
+		t.Errorf("bad result: %v", present)
+	}
+	if present := c.ContainsMulti(nil); len(present) != 0 {
+		t.Errorf("bad result: %v", present)
+	}
+}
+
+func TestImport(t *testing.T) {
+	src := NewLFUDA(10, nil)
+	src.SetWithClass("a", "a", 1)
+	src.AddHits("a", 4)
+	info, _ := src.Inspect("a")
+
+	c := NewLFUDA(10, nil)
+	c.Set("x", "x")
+	c.Evict(1)
+	if !c.Import(info, "a") {
+		t.Fatalf("a should have been imported")
+	}
+	got, _ := c.Inspect("a")
+	if got.Hits != 5 || got.Class != 1 || got.Boost != 1 || got.Size != 1 || got.Priority != 5 {
+		t.Errorf("bad imported entry: %+v", got)
+	}
+
+	// importing into an existing entry sums the hits
+	c.Import(info, "b")
+	if v, _ := c.Peek("a"); v != "b" {
+		t.Errorf("the value should be replaced: %v", v)
+	}
+	if got, _ := c.Inspect("a"); got.Hits != 10 {
+		t.Errorf("hits should be summed: %+v", got)
+	}
+
+	info.Size = 11
+	info.Key = "big"
+	if c.Import(info, "big") {
+		t.Errorf("entries larger than the cache should not be imported")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := NewLFUDA(3, nil)
+	a.Set("x", "x")
+	a.AddHits("x", 2)
+	b := NewLFUDA(10, nil)
+	b.Set("x", "X")
+	b.Set("y", "y")
+	b.Set("z", "z")
+	b.AddHits("y", 4)
+	b.SetWithClass("c", "c", 1)
+
+	// a has room for 3 entries, so b's coldest is dropped
+	a.Merge(b)
+	if got, _ := a.Inspect("x"); got.Hits != 4 {
+		t.Errorf("hits should be summed: %+v", got)
+	}
+	if v, _ := a.Peek("x"); v != "X" {
+		t.Errorf("merged values should win: %v", v)
+	}
+	if !a.Contains("y") || !a.Contains("c") || a.Len() != 3 {
+		t.Errorf("b's hottest entries should be kept: %v", a.Keys())
+	}
+	if a.Age() < b.Age() {
+		t.Errorf("the larger age should be taken: %f < %f", a.Age(), b.Age())
+	}
+}
+
+func TestSplit(t *testing.T) {
+	c := NewLFUDA(100, nil, WithScanResistance())
+	for i := 0; i < 50; i++ {
+		c.Set(i, "x")
+		c.AddHits(i, float64(i))
+	}
+	c.Evict(10)
+
+	parts := c.Split(4, nil)
+	if len(parts) != 4 {
+		t.Fatalf("bad number of parts: %d", len(parts))
+	}
+	total := 0
+	for i, p := range parts {
+		total += p.Len()
+		if p.size != 25 || p.Age() != c.Age() || !p.scanResistant {
+			t.Errorf("part %d not configured like the original: %f, %f", i, p.size, p.Age())
+		}
+		for _, e := range p.Entries() {
+			if keyhash.Sum(e.Key)%4 != uint64(i) {
+				t.Errorf("%v is in the wrong part", e.Key)
+			}
+			if orig, _ := c.Inspect(e.Key); orig.Hits != e.Hits {
+				t.Errorf("%v should keep its hits: %+v != %+v", e.Key, e, orig)
+			}
+		}
+	}
+	if total != 40 || c.Len() != 40 {
+		t.Errorf("every entry should be in one part: %d", total)
+	}
+	if NewLFUDA(1, nil).Split(0, nil) != nil {
+		t.Errorf("there's no splitting into 0 parts")
+	}
+
+	byValue := func(key interface{}, n int) int { return key.(int) % n }
+	for i, p := range c.Split(4, byValue) {
+		for _, key := range p.Keys() {
+			if key.(int)%4 != i {
+				t.Errorf("%v should be in part %d", key, key.(int)%4)
+			}
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("out of range partitions should panic")
+		}
+	}()
+	c.Split(4, func(key interface{}, n int) int { return -1 })
+}
+
+func TestLFUDAHealth(t *testing.T) {
+	l := NewLFUDA(10, nil)
+	for i := 0; i < 20; i++ {
+		l.Set(i, "x")
+		l.Get(i % 3)
+	}
+	l.Remove(19)
+	l.Set("big", "much too big for the cache")
+
+	h := l.Health()
+	if !h.Healthy() || h.Utilization != 0.9 || h.RefusalRate != 1.0/21 {
+		t.Errorf("bad health: %+v", h)
+	}
+
+	// corrupt the cache
+	l.items[0].priorityKey++
+	l.currSize++
+	if h := l.Health(); h.Healthy() || len(h.Problems) != 2 {
+		t.Errorf("problems should be reported: %v", h.Problems)
+	}
+}
+
+func TestLFUDAErrorHook(t *testing.T) {
+	var errs []error
+	evicted := 0
+	l := NewLFUDA(2, func(key, value interface{}) {
+		evicted++
+		panic(key)
+	}, WithErrorHook(func(err error) { errs = append(errs, err) }))
+
+	l.Set("a", "x")
+	l.Set("b", "x")
+	l.Set("c", "xx")
+	l.Remove("c")
+	if evicted != 3 || len(errs) != 3 || !l.Health().Healthy() || l.Len() != 0 {
+		t.Fatalf("panics should be recovered: %d, %v", evicted, errs)
+	}
+	if p, ok := errs[2].(*CallbackPanic); !ok || p.Value != "c" || p.Callback != "eviction callback" || len(p.Stack) == 0 {
+		t.Errorf("bad error: %#v", errs[2])
+	}
+
+	l.Set("a", "x")
+	l.Set("b", "x")
+	l.Purge()
+	if evicted != 5 || len(errs) != 5 || l.Len() != 0 || l.Size() != 0 {
+		t.Errorf("Purge should call every callback: %d", evicted)
+	}
+}
+
+func TestLFUDACallbackPanic(t *testing.T) {
+	l := NewLFUDA(2, func(key, value interface{}) { panic(key) })
+	l.Set("a", "x")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panics should propagate without an error hook")
+			}
+		}()
+		l.Remove("a")
+	}()
+	if l.Contains("a") || !l.Health().Healthy() {
+		t.Errorf("cache should be consistent after a panic")
+	}
+}
+
+func TestLFUDANilValue(t *testing.T) {
+	evicted := 0
+	l := NewLFUDA(10, func(key, value interface{}) {
+		if value != nil {
+			t.Errorf("evicted value should be nil: %v", value)
+		}
+		evicted++
+	})
+	l.Set("a", nil)
+	if v, ok := l.Get("a"); v != nil || !ok {
+		t.Errorf("nil values should be found: %v, %v", v, ok)
+	}
+	if v, ok := l.Peek("a"); v != nil || !ok || l.Size() != 0 || l.Len() != 1 {
+		t.Errorf("nil values should be sized as 0 bytes: %f", l.Size())
+	}
+	if !l.Remove("a") || evicted != 1 || l.Len() != 0 {
+		t.Errorf("nil values should be removable")
+	}
+}
+
+func TestGDSFZeroSize(t *testing.T) {
+	l := NewGDSF(10, nil)
+	l.Set("nil", nil)
+	l.SetWithSize("empty", "x", 0)
+	l.Set("a", "a")
+	l.Get("nil")
+	for _, key := range []interface{}{"nil", "empty", "a"} {
+		if info, _ := l.Inspect(key); math.IsInf(info.Priority, 0) || math.IsNaN(info.Priority) {
+			t.Errorf("%v should have a finite priority: %v", key, info.Priority)
+		}
+	}
+	if info, _ := l.Inspect("nil"); info.Priority != 2 {
+		t.Errorf("nil values should count as 1 byte: %v", info.Priority)
+	}
+	l.Set("b", "bbbbbbbbbb")
+	if !l.Health().Healthy() || l.Contains("empty") || l.Contains("a") || !l.Contains("nil") {
+		t.Errorf("zero sized entries should be evicted in order: %v", l.Keys())
+	}
+
+	l = NewGDSF(10, nil, WithSizeNormalization(math.Log2))
+	l.SetWithSize("a", "a", 1)
+	if info, _ := l.Inspect("a"); info.Priority != 1 {
+		t.Errorf("non-positive normalized sizes should count as 1: %v", info.Priority)
+	}
+}
+
+func TestGDSFSizeUnit(t *testing.T) {
+	l := NewGDSF(1<<20, nil, WithSizeUnit(1024))
+	l.SetWithSize("a", "x", 2048)
+	if info, _ := l.Inspect("a"); info.Priority != 0.5 {
+		t.Errorf("hits should be divided by KB: %f", info.Priority)
+	}
+
+	l = NewGDSF(1<<20, nil, WithSizeNormalization(math.Log2))
+	l.SetWithSize("a", "x", 1024)
+	l.Get("a")
+	if info, _ := l.Inspect("a"); info.Priority != 0.2 {
+		t.Errorf("hits should be divided by the normalized size: %f", info.Priority)
+	}
+
+	l = NewLFUDA(1<<20, nil, WithSizeUnit(1024))
+	l.SetWithSize("a", "x", 2048)
+	if info, _ := l.Inspect("a"); info.Priority != 1 {
+		t.Errorf("only GDSF should be affected: %f", info.Priority)
+	}
+}
+
+func TestLFUDAPriorityBuckets(t *testing.T) {
+	l := NewGDSF(1<<21, nil, WithPriorityBuckets(0.01))
+	for i := 1; i <= 1000; i++ {
+		l.SetWithSize(i, "x", float64(1000+i))
+	}
+	if n := l.freqs.len; n > 1 {
+		t.Errorf("similar priorities should share a node: %d nodes", n)
+	}
+	if info, _ := l.Inspect(1); info.Priority != 0 {
+		t.Errorf("priorities should be rounded down: %f", info.Priority)
+	}
+	for i := 0; i < 10; i++ {
+		l.Get(1)
+	}
+	if info, _ := l.Inspect(1); math.Abs(info.Priority-0.01) > 1e-12 || !l.Health().Healthy() {
+		t.Errorf("bad priority: %f", info.Priority)
+	}
+}
+
+func TestLFUDAShape(t *testing.T) {
+	l := NewLFUDA(100, nil)
+	for i := 0; i < 4; i++ {
+		l.Set(i, "x")
+	}
+	for i := 0; i < 3; i++ {
+		l.Get(3)
+	}
+	// 0-2 on one node, 3 on its own.  every promotion so far moved to the next
+	// node or a new one
+	s := l.Shape()
+	if s.Nodes != 2 || s.EntriesPerNode != 2 || s.LargestNode != 3 || s.Promotions != 7 || s.MaxPromotionPath != 0 {
+		t.Errorf("bad shape: %+v", s)
+	}
+
+	// reinserting entries starts from the bottom of the list: 1 steps over 0
+	// and 2's node, while boosted 0 goes straight to the back
+	l.SetWithBoost(1, "x", 1)
+	l.SetWithBoost(0, "x", 5)
+	if s := l.Shape(); s.MaxPromotionPath != 1 || s.MeanPromotionPath != 1.0/9 {
+		t.Errorf("only 1 should have stepped over a node: %+v", s)
+	}
+}
+
+func TestLFUDANextVictims(t *testing.T) {
+	l := NewLFUDA(100, nil)
+	for i := 0; i < 5; i++ {
+		l.Set(i, "x")
+		for j := 0; j < i; j++ {
+			l.Get(i)
+		}
+	}
+	victims := l.NextVictims(2)
+	if len(victims) != 2 || victims[0].Key != 0 || victims[1].Key != 1 || victims[1].Priority != 2 || victims[1].Size != 1 {
+		t.Errorf("bad victims: %+v", victims)
+	}
+	if l.Len() != 5 || l.Age() != 0 {
+		t.Errorf("NextVictims should not evict")
+	}
+	if len(l.NextVictims(10)) != 5 || l.NextVictims(0) != nil {
+		t.Errorf("NextVictims should be limited to the entries")
+	}
+	if evicted := l.Evict(2); evicted[0].Key != 0 || evicted[1].Key != 1 {
+		t.Errorf("Evict should evict the next victims: %+v", evicted)
+	}
+}
+
+func TestLFUDAWouldSet(t *testing.T) {
+	l := NewLFUDA(4, nil)
+	l.Set("a", "x")
+	l.Set("b", "xx")
+	l.Get("b")
+	l.Set("c", "x")
+	l.Get("c")
+	l.Get("c")
+
+	if plan := l.WouldSet("d", "x"); !plan.Admitted || plan.EvictBytes != 1 || len(plan.Victims) != 1 || plan.Victims[0].Key != "a" {
+		t.Errorf("a should be evicted: %+v", plan)
+	}
+	if plan := l.WouldSet("d", "xxx"); plan.EvictBytes != 3 || len(plan.Victims) != 2 || plan.Victims[1].Key != "b" {
+		t.Errorf("a and b should be evicted: %+v", plan)
+	}
+	// a's own size is freed by replacing it
+	if plan := l.WouldSet("a", "xx"); !plan.Admitted || plan.EvictBytes != 1 || len(plan.Victims) != 1 || plan.Victims[0].Key != "b" {
+		t.Errorf("a should not be its own victim: %+v", plan)
+	}
+	if plan := l.WouldSet("c", "x"); !plan.Admitted || plan.EvictBytes != 0 || plan.Victims != nil {
+		t.Errorf("nothing should be evicted: %+v", plan)
+	}
+	if plan := l.WouldSet("d", "xxxxx"); plan.Admitted {
+		t.Errorf("oversized values should not be admitted: %+v", plan)
+	}
+	if l.Len() != 3 || l.Size() != 4 {
+		t.Errorf("WouldSet should not change the cache")
+	}
+
+	plan := l.WouldSet("d", "xxx")
+	_, victims := l.SetWithVictims("d", "xxx")
+	if !reflect.DeepEqual(plan.Victims, victims) {
+		t.Errorf("plan should match the actual Set: %+v != %+v", plan.Victims, victims)
+	}
+}
+
+func TestLFUDAExplain(t *testing.T) {
+	l := NewLFUDA(3, nil)
+	if x := l.Explain("a", 4); x.Admitted || x.Reason != "refused: size 4 is larger than the cache's 3" {
+		t.Errorf("oversized values should be refused: %+v", x)
+	}
+
+	l.Set("a", "x")
+	l.Set("b", "x")
+	l.Get("b")
+	l.Get("b")
+	l.Set("c", "x")
+	l.Get("c")
+
+	// d evicts a, aging the cache to 1, and lands at 2 with c, below b
+	x := l.Explain("d", 1)
+	if !x.Admitted || len(x.Victims) != 1 || x.Victims[0].Key != "a" || x.Age != 1 || x.Priority != 2 {
+		t.Errorf("bad explanation: %+v", x)
+	}
+	if !x.NextToGo || x.Next == nil || x.Next.Key != "c" {
+		t.Errorf("d should be next to go, tied with c: %+v", x)
+	}
+	if x.Reason != "admitted by evicting 1 entries with priorities up to 1, but at priority 2 against age 1 it would be the next entry evicted" {
+		t.Errorf("bad reason: %s", x.Reason)
+	}
+
+	// setting b again keeps its hits
+	if x := l.Explain("b", 1); x.Priority != 4 || x.NextToGo || len(x.Victims) != 0 || x.Next.Key != "a" {
+		t.Errorf("bad explanation: %+v", x)
+	}
+	if l.Len() != 3 || l.Age() != 0 {
+		t.Errorf("Explain should not change the cache")
+	}
+}
+
+func TestLFUDATies(t *testing.T) {
+	l := NewLFUDA(3, nil)
+	l.SetWithSize("a", nil, 1)
+	l.SetWithSize("b", nil, 1)
+	l.SetWithSize("c", nil, 1)
+	// ties are evicted in the order they reached their priority
+	if v := l.NextVictims(3); v[0].Key != "a" || v[1].Key != "b" || v[2].Key != "c" {
+		t.Errorf("bad eviction order: %v", v)
+	}
+	l.Get("a")
+	l.Get("b")
+	l.SetWithSize("d", nil, 1)
+	if l.Contains("c") || !l.Contains("a") || !l.Contains("b") {
+		t.Errorf("c should have been evicted: %v", l.Keys())
+	}
+	if keys := l.Keys(); !reflect.DeepEqual(keys, []interface{}{"d", "b", "a"}) {
+		t.Errorf("bad key order: %v", keys)
+	}
+	if h := l.Health(); !h.Healthy() {
+		t.Errorf("unhealthy: %v", h.Problems)
+	}
+}
+
+func TestEntryCounting(t *testing.T) {
+	l := NewLFUDA(3, nil, WithEntryCounting())
+	l.Set("a", make([]byte, 100))
+	l.SetWithSize("b", "b", 50)
+	l.Set("c", "c")
+	if l.Len() != 3 || l.Size() != 3 {
+		t.Fatalf("every entry should count as 1: %d entries, size %f", l.Len(), l.Size())
+	}
+	l.Get("a")
+	if plan := l.WouldSet("d", make([]byte, 1000)); !plan.Admitted || len(plan.Victims) != 1 {
+		t.Errorf("one entry should make room: %+v", plan)
+	}
+	l.Set("d", "d")
+	if l.Len() != 3 || l.Contains("b") || !l.Contains("a") {
+		t.Errorf("the coldest entry should have been evicted: %v", l.Keys())
+	}
+}
+
+type sizedValue struct {
+	data map[string]string
+}
+
+func (v sizedValue) Size() int64 {
+	return 64
+}
+
+func TestSizer(t *testing.T) {
+	l := NewLFUDA(100, nil)
+	l.Set("a", sizedValue{})
+	if l.Size() != 64 {
+		t.Errorf("Sizer values should size themselves: %f", l.Size())
+	}
+
+	l = NewLFUDA(100, nil, WithSizer(func(key, value interface{}) float64 {
+		return float64(len(key.(string)) + len(value.(map[string]string))*10)
+	}))
+	l.Set("ab", map[string]string{"x": "y", "z": "w"})
+	if info, _ := l.Inspect("ab"); info.Size != 22 {
+		t.Errorf("values should be sized by the sizer: %f", info.Size)
+	}
+	big := make(map[string]string)
+	for i := 0; i < 9; i++ {
+		big[string(rune('a'+i))] = ""
+	}
+	if plan := l.WouldSet("cd", big); plan.EvictBytes != 14 {
+		t.Errorf("plans should size values with the sizer too: %+v", plan)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	var evicted []interface{}
+	l := NewLFUDA(100, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	expire := func(key interface{}) {
+		l.items[key].expires = time.Now().Add(-time.Second).UnixNano()
+	}
+	l.SetWithTTL("a", "a", time.Hour)
+	l.SetWithTTL("b", "b", time.Hour)
+	l.SetWithTTL("c", "c", time.Hour)
+	l.Set("d", "d")
+	if v, ok := l.Get("a"); !ok || v != "a" {
+		t.Errorf("fresh entries should be found")
+	}
+
+	expire("a")
+	if _, ok := l.Get("a"); ok || l.Contains("a") || l.Len() != 3 {
+		t.Errorf("expired entries should be removed when fetched")
+	}
+	expire("b")
+	l.Set("c", "c")
+	if n := l.RemoveExpired(); n != 1 || l.Len() != 2 || l.Size() != 2 {
+		t.Errorf("only b should have expired, as setting c again cleared its TTL: %d, %v", n, l.Keys())
+	}
+	if len(evicted) != 2 || evicted[0] != "a" || evicted[1] != "b" {
+		t.Errorf("expired entries should be passed to the eviction callback: %v", evicted)
+	}
+	if l.Age() != 0 {
+		t.Errorf("expiry shouldn't age the cache: %v", l.Age())
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	var evicted []interface{}
+	l := NewLFUDA(100, func(key, value interface{}) {
+		evicted = append(evicted, key)
+	}, WithDefaultTTL(time.Hour))
+	l.Set("a", "a")
+	l.SetWithTTL("b", "b", 2*time.Hour)
+	if a, b := l.items["a"].expires, l.items["b"].expires; a == 0 || b <= a {
+		t.Fatalf("every entry should expire, after its own TTL if given: %d, %d", a, b)
+	}
+
+	l.Get("a")
+	l.items["a"].expires = time.Now().Add(-time.Second).UnixNano()
+	if _, ok := l.Peek("a"); ok || l.Contains("a") || l.ContainsMulti([]interface{}{"a"})[0] || len(l.PeekMulti([]interface{}{"a"})) != 0 {
+		t.Errorf("expired entries should be skipped")
+	}
+	if l.Len() != 2 {
+		t.Errorf("skipping shouldn't remove the entry")
+	}
+	l.Set("a", "new")
+	if info, _ := l.Inspect("a"); info.Hits != 1 || len(evicted) != 1 {
+		t.Errorf("setting an expired key should replace it with a new entry: %+v, %v", info, evicted)
+	}
+}
+
+func TestTTLWeight(t *testing.T) {
+	for _, weighted := range []bool{false, true} {
+		var opts []Option
+		if weighted {
+			opts = append(opts, WithTTLWeight(5, time.Hour))
+		}
+		l := NewLFUDA(2, nil, opts...)
+		// hot, but with a minute left to live
+		l.SetWithTTL("hot", "h", time.Minute)
+		for i := 0; i < 3; i++ {
+			l.Get("hot")
+		}
+		l.Set("cold", "c")
+		l.Set("new", "n")
+
+		// unweighted, the cold entry goes; weighted, the one about to expire
+		if l.Contains("hot") == weighted || l.Contains("cold") != weighted {
+			t.Errorf("weighted %v: bad victim, left %v", weighted, l.Keys())
+		}
+	}
+
+	l := NewLFUDA(10, nil, WithTTLWeight(5, time.Hour))
+	l.SetWithTTL("a", "a", 2*time.Hour)
+	l.Set("b", "b")
+	l.SetWithTTL("c", "c", 30*time.Minute)
+	a, _ := l.Inspect("a")
+	b, _ := l.Inspect("b")
+	c, _ := l.Inspect("c")
+	if a.Priority != b.Priority || c.Priority >= b.Priority || c.Priority < b.Priority-5 {
+		t.Errorf("only TTLs inside the horizon should lower priorities: %v, %v, %v", a.Priority, b.Priority, c.Priority)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	l := NewLFUDA(100, nil)
+	l.SetWithTTL("a", "a", time.Minute)
+	l.Set("b", "b")
+	v, expires, ok := l.GetWithExpiry("a")
+	if v != "a" || !ok || time.Until(expires) > time.Minute || time.Until(expires) < 59*time.Second {
+		t.Errorf("bad expiry: %v, %v, %v", v, expires, ok)
+	}
+	if _, expires, _ := l.GetWithExpiry("b"); !expires.IsZero() {
+		t.Errorf("b shouldn't expire: %v", expires)
+	}
+
+	if !l.Touch("a", time.Hour) || !l.Touch("b", time.Hour) || l.Touch("c", time.Hour) {
+		t.Errorf("present keys should be touched")
+	}
+	info, _ := l.Inspect("b")
+	if time.Until(info.Expires) < 59*time.Minute || info.Hits != 2 {
+		t.Errorf("touching should extend the expiry without a hit: %+v", info)
+	}
+	l.Touch("b", 0)
+	if info, _ := l.Inspect("b"); !info.Expires.IsZero() {
+		t.Errorf("a zero TTL should clear the expiry: %v", info.Expires)
+	}
+
+	l.items["a"].expires = time.Now().Add(-time.Second).UnixNano()
+	if l.Touch("a", time.Hour) {
+		t.Errorf("expired entries can't be touched")
+	}
+	if _, _, ok := l.GetWithExpiry("a"); ok {
+		t.Errorf("expired entries should be missing")
+	}
+}
+
+func TestStats(t *testing.T) {
+	l := NewLFUDA(3, nil)
+	l.Set("a", "a")
+	l.Set("b", "b")
+	l.Get("a")
+	l.Get("a")
+	l.Get("missing")
+	l.Set("big", "toobig")
+	l.SetWithTTL("c", "c", time.Hour)
+	l.items["c"].expires = 1
+	l.Get("c")
+	l.Set("d", "dd")
+
+	stats := l.Stats()
+	want := Stats{Hits: 2, Misses: 2, Evictions: 1, EvictedBytes: 1, Expirations: 1, Rejected: 1, Age: 1}
+	if stats != want {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	if r := stats.HitRatio(); r != 0.5 {
+		t.Errorf("bad hit ratio: %v", r)
+	}
+
+	l.ResetStats()
+	if stats := l.Stats(); stats != (Stats{Age: 1}) || stats.HitRatio() != 0 {
+		t.Errorf("counters should be reset but not the age: %+v", stats)
+	}
+}
+
+func TestStatsDelta(t *testing.T) {
+	l := NewLFUDA(2, nil)
+	l.Set("a", "a")
+	l.Get("a")
+	l.Get("missing")
+	if delta := l.StatsDelta(); delta != (Stats{Hits: 1, Misses: 1}) {
+		t.Errorf("bad first delta: %+v", delta)
+	}
+
+	l.Get("a")
+	l.Set("b", "b")
+	l.Set("c", "c")
+	if delta := l.StatsDelta(); delta != (Stats{Hits: 1, Evictions: 1, EvictedBytes: 1, Age: 1}) {
+		t.Errorf("delta should only count activity since the last: %+v", delta)
+	}
+	if delta := l.StatsDelta(); delta != (Stats{Age: 1}) {
+		t.Errorf("delta should be empty without activity: %+v", delta)
+	}
+	if stats := l.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("deltas shouldn't reset the lifetime counts: %+v", stats)
+	}
+
+	l.Get("c")
+	l.ResetStats()
+	l.Get("c")
+	if delta := l.StatsDelta(); delta.Hits != 1 {
+		t.Errorf("delta should count from ResetStats: %+v", delta)
+	}
+}
+
+func TestRejectCallback(t *testing.T) {
+	var rejected []interface{}
+	var errs []error
+	l := NewLFUDA(3, nil, WithRejectCallback(func(key, value interface{}, reason RejectReason) {
+		if reason != RejectTooLarge {
+			t.Errorf("bad reason: %v", reason)
+		}
+		rejected = append(rejected, key, value)
+		panic(key)
+	}), WithErrorHook(func(err error) { errs = append(errs, err) }))
+	l.Set("a", "abc")
+	l.Set("big", "toobig")
+	if len(rejected) != 2 || rejected[0] != "big" || rejected[1] != "toobig" || !l.Contains("a") {
+		t.Errorf("only refused sets should be reported: %v", rejected)
+	}
+	if p, ok := errs[0].(*CallbackPanic); len(errs) != 1 || !ok || p.Callback != "reject callback" {
+		t.Errorf("panics should be recovered: %v", errs)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	l := NewLFUDA(100, nil, WithMaxEntries(3))
+	for i := 0; i < 3; i++ {
+		l.SetWithSize(i, i, 1)
+	}
+	l.Get(0)
+	l.Get(1)
+	if plan := l.WouldSet(3, "x"); len(plan.Victims) != 1 || plan.Victims[0].Key != 2 {
+		t.Errorf("the entry cap should make room: %+v", plan)
+	}
+	l.SetWithSize(3, 3, 1)
+	if l.Len() != 3 || l.Contains(2) {
+		t.Errorf("the coldest entry should have been evicted for the cap: %v", l.Keys())
+	}
+	l.SetWithSize(0, 0, 2)
+	if l.Len() != 3 {
+		t.Errorf("replacing a value shouldn't evict for the cap: %v", l.Keys())
+	}
+
+	// the byte limit still applies
+	_, victims := l.SetWithVictims("big", make([]byte, 98))
+	if l.Len() != 2 || len(victims) != 2 || l.Size() != 100 {
+		t.Errorf("the byte limit should make room: %v, %v", victims, l.Keys())
+	}
+}
+
+func TestLFUDAExpectedEntries(t *testing.T) {
+	fill := func(opts ...Option) float64 {
+		return testing.AllocsPerRun(5, func() {
+			l := NewLFUDA(1<<20, nil, opts...)
+			for i := 0; i < 1000; i++ {
+				l.SetWithSize(i, nil, 1)
+			}
+			if l.Len() != 1000 {
+				t.Fatalf("expected 1000 entries, got %d", l.Len())
+			}
+		})
+	}
+	if presized, grown := fill(WithExpectedEntries(1000)), fill(); presized >= grown {
+		t.Errorf("presizing should save allocations: %v >= %v", presized, grown)
+	}
+
+	l := NewLFUDA(10, nil, WithExpectedEntries(100))
+	for i := 0; i < 20; i++ {
+		l.SetWithSize(i, nil, 1)
+	}
+	l.Purge()
+	l.SetWithSize("a", nil, 1)
+	if l.Len() != 1 || !l.Contains("a") {
+		t.Errorf("expected just a after purge: %v", l.Keys())
+	}
+}
+
+func TestLFUDAOverhead(t *testing.T) {
+	l := NewLFUDA(1<<20, nil)
+	for i := 0; i < 1000; i++ {
+		l.Set(i, i)
+	}
+	o := l.Overhead()
+	if o.Total != o.Items+o.Index+o.Nodes || o.PerEntry != o.Total/1000 {
+		t.Errorf("bad totals: %+v", o)
+	}
+	if o.Items != 1000*float64(unsafe.Sizeof(item{})) || o.PerEntry < 100 || o.PerEntry > 1000 {
+		t.Errorf("implausible overhead: %+v", o)
+	}
+
+	// measure it roughly
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	l = NewLFUDA(1<<30, nil)
+	for i := 0; i < 100000; i++ {
+		l.SetWithSize(i, nil, 1)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	measured := float64(after.HeapAlloc-before.HeapAlloc) / 100000
+	if est := l.Overhead().PerEntry; est < measured/2 || est > measured*2 {
+		t.Errorf("estimate %f should be near the measured %f", est, measured)
+	}
+}
+
+// reports the heap bytes held per entry by a cache of 1M small entries
+func BenchmarkMemory(b *testing.B) {
+	const entries = 1 << 20
+	var before, after runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		l := NewLFUDA(1<<30, nil)
+		for k := 0; k < entries; k++ {
+			l.SetWithSize(k, nil, 1)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/entries, "B/entry")
+		runtime.KeepAlive(l)
+	}
+}
@@ -0,0 +1,174 @@
+package simplelfuda
+
+import (
+	"math"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// calcBytes estimates the bytes a value holds without formatting or otherwise
+// allocating.  Common types are sized directly: strings and []byte by their
+// length, fixed size numbers by their width, and ints and uints by their decimal
+// digits as they always have been.  Anything else is sized by reflection as the
+// sum of the data it holds and references, see deepSize.
+func calcBytes(value interface{}) float64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case Sizer:
+		return float64(v.Size())
+	case []byte:
+		return float64(len(v))
+	case string:
+		return float64(len(v))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int64, uint64, float64, complex64:
+		return 8
+	case complex128:
+		return 16
+	case int:
+		return intDigits(int64(v))
+	case uint:
+		return uintDigits(uint64(v))
+	case time.Time:
+		return float64(unsafe.Sizeof(v))
+	}
+	var d deepSizer
+	return d.size(reflect.ValueOf(value))
+}
+
+// intDigits returns the length of n in decimal, including any minus sign.
+func intDigits(n int64) float64 {
+	if n < 0 {
+		if n == math.MinInt64 {
+			return 20
+		}
+		return 1 + uintDigits(uint64(-n))
+	}
+	return uintDigits(uint64(n))
+}
+
+func uintDigits(n uint64) float64 {
+	digits := 1.0
+	for n >= 10 {
+		n /= 10
+		digits++
+	}
+	return digits
+}
+
+// deepSizer sizes a value as the bytes of the data it holds, counting what its
+// pointers, slices, maps and interfaces reference and leaving out headers and
+// padding, so [5]int8 is 5 bytes and a struct of two strings the sum of their
+// lengths.  Each pointer's target, map and slice's elements are only counted
+// once, so shared data doesn't inflate the size and cyclic data, even a map or
+// slice holding itself, doesn't recurse forever.  Channels, functions and
+// unsafe pointers count only themselves, as what they reference isn't the
+// value's to account for.
+type deepSizer struct {
+	// targets of the pointers and data of the maps and slices followed so
+	// far, made on the first
+	seen map[seenRef]struct{}
+}
+
+// seenRef identifies referenced data by its address and the kind of reference,
+// as a slice's elements and a pointer to its first one share an address.
+type seenRef struct {
+	p    uintptr
+	kind reflect.Kind
+}
+
+// visit reports whether the data v references is yet to be counted, marking it
+// counted.
+func (d *deepSizer) visit(v reflect.Value) bool {
+	if d.seen == nil {
+		d.seen = make(map[seenRef]struct{})
+	}
+	ref := seenRef{v.Pointer(), v.Kind()}
+	if _, ok := d.seen[ref]; ok {
+		return false
+	}
+	d.seen[ref] = struct{}{}
+	return true
+}
+
+func (d *deepSizer) size(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.String:
+		return float64(v.Len())
+	case reflect.Ptr:
+		if v.IsNil() || !d.visit(v) {
+			return 0
+		}
+		return d.size(v.Elem())
+	case reflect.Interface:
+		return d.size(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if n, ok := flatSize(v.Type().Elem()); ok {
+			return float64(n * v.Len())
+		}
+		if v.Kind() == reflect.Slice && (v.Len() == 0 || !d.visit(v)) {
+			return 0
+		}
+		total := 0.0
+		for i := 0; i < v.Len(); i++ {
+			total += d.size(v.Index(i))
+		}
+		return total
+	case reflect.Map:
+		if v.Len() == 0 || !d.visit(v) {
+			return 0
+		}
+		total := 0.0
+		iter := v.MapRange()
+		for iter.Next() {
+			total += d.size(iter.Key()) + d.size(iter.Value())
+		}
+		return total
+	case reflect.Struct:
+		if n, ok := flatSize(v.Type()); ok {
+			return float64(n)
+		}
+		total := 0.0
+		for i := 0; i < v.NumField(); i++ {
+			total += d.size(v.Field(i))
+		}
+		return total
+	default:
+		return float64(v.Type().Size())
+	}
+}
+
+// flatSize returns the size of a type holding no strings, pointers or other
+// references, without padding, or false for any other type.
+func flatSize(t reflect.Type) (int, bool) {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int(t.Size()), true
+	case reflect.Array:
+		n, ok := flatSize(t.Elem())
+		return n * t.Len(), ok
+	case reflect.Struct:
+		total := 0
+		for i := 0; i < t.NumField(); i++ {
+			n, ok := flatSize(t.Field(i).Type)
+			if !ok {
+				return 0, false
+			}
+			total += n
+		}
+		return total, true
+	default:
+		return 0, false
+	}
+}
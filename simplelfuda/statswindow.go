@@ -0,0 +1,88 @@
+package simplelfuda
+
+import "time"
+
+// statsWindow keeps the activity counters as they stood at the start of each of
+// the last intervals, so the activity over a recent span is the difference
+// between the current counters and those at its start.
+type statsWindow struct {
+	interval time.Duration
+	start    time.Time
+	// the counters at the start of each interval, indexed by the interval's
+	// number modulo the ring's length
+	marks []Stats
+	// the latest interval marked
+	current int64
+}
+
+// WithStatsWindow keeps the Stats counters at the start of each interval of the
+// given length over the last span, so WindowStats can report the activity, and
+// so the hit ratio, over recent periods such as the last minute or hour.
+// Lifetime counters hide recent regressions, after a configuration change say.
+// The window costs a clock read per counted operation and a Stats per interval.
+func WithStatsWindow(interval, span time.Duration) Option {
+	return func(l *LFUDA) {
+		if interval > 0 && span > 0 {
+			intervals := int((span + interval - 1) / interval)
+			l.statsWindow = &statsWindow{
+				interval: interval,
+				start:    time.Now(),
+				marks:    make([]Stats, intervals+1),
+			}
+		}
+	}
+}
+
+// mark records stats as the counters at the start of every interval begun
+// since the last mark.
+func (w *statsWindow) mark(stats Stats) {
+	now := int64(time.Since(w.start) / w.interval)
+	if now-w.current >= int64(len(w.marks)) {
+		// idle for longer than the window
+		w.current = now - int64(len(w.marks))
+	}
+	for ; w.current < now; w.current++ {
+		w.marks[(w.current+1)%int64(len(w.marks))] = stats
+	}
+}
+
+// reset marks every interval in the window as starting from zero.
+func (w *statsWindow) reset() {
+	for i := range w.marks {
+		w.marks[i] = Stats{}
+	}
+}
+
+// counters returns the activity counters for updating, marking the start of
+// any intervals begun since they were last updated.
+func (l *LFUDA) counters() *Stats {
+	if l.statsWindow != nil {
+		l.statsWindow.mark(l.stats)
+	}
+	return &l.stats
+}
+
+// WindowStats returns the activity over roughly the last d, rounded to whole
+// intervals of the window and limited to its span, with the current age.  It
+// returns false unless the cache was created WithStatsWindow.
+func (l *LFUDA) WindowStats(d time.Duration) (Stats, bool) {
+	w := l.statsWindow
+	if w == nil {
+		return Stats{}, false
+	}
+	w.mark(l.stats)
+	back := int64(d / w.interval)
+	if back < 1 {
+		back = 1
+	}
+	if max := int64(len(w.marks)) - 1; back > max {
+		back = max
+	}
+	var since Stats
+	if first := w.current - back + 1; first > 0 {
+		since = w.marks[first%int64(len(w.marks))]
+	}
+	stats := l.stats.since(since)
+	stats.Age = l.age
+	return stats, true
+}
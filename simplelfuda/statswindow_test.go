@@ -0,0 +1,58 @@
+package simplelfuda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsWindow(t *testing.T) {
+	l := NewLFUDA(10, nil, WithStatsWindow(time.Minute, time.Hour))
+	pass := func(d time.Duration) {
+		l.statsWindow.start = l.statsWindow.start.Add(-d)
+	}
+
+	l.Set("a", "a")
+	l.Get("a")
+	l.Get("missing")
+	pass(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		l.Get("a")
+	}
+
+	for _, tc := range []struct {
+		d            time.Duration
+		hits, misses uint64
+	}{
+		{time.Second, 3, 0},
+		{time.Minute, 3, 0},
+		{2 * time.Minute, 3, 0},
+		{3 * time.Minute, 4, 1},
+		{time.Hour, 4, 1},
+		{24 * time.Hour, 4, 1},
+	} {
+		stats, ok := l.WindowStats(tc.d)
+		if !ok || stats.Hits != tc.hits || stats.Misses != tc.misses {
+			t.Errorf("bad stats over %v: %+v, %v", tc.d, stats, ok)
+		}
+	}
+
+	// idle for longer than the window
+	pass(2 * time.Hour)
+	if stats, _ := l.WindowStats(time.Hour); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("old activity should have left the window: %+v", stats)
+	}
+	if stats := l.Stats(); stats.Hits != 4 {
+		t.Errorf("the lifetime counts should be kept: %+v", stats)
+	}
+
+	l.Get("a")
+	l.ResetStats()
+	l.Get("a")
+	if stats, _ := l.WindowStats(time.Hour); stats.Hits != 1 || stats.HitRatio() != 1 {
+		t.Errorf("the window should count from ResetStats: %+v", stats)
+	}
+
+	if _, ok := NewLFUDA(10, nil).WindowStats(time.Minute); ok {
+		t.Errorf("caches without a window shouldn't report one")
+	}
+}
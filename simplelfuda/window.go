@@ -0,0 +1,142 @@
+package simplelfuda
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// hitWindow counts entries' hits over a sliding window of epochs.
+type hitWindow struct {
+	epoch  time.Duration
+	epochs int
+	start  time.Time
+	// the epoch entries were last decayed in
+	current int64
+	counts  map[*item]*windowCounts
+}
+
+// windowCounts is an entry's ring of per-epoch hit counts.
+type windowCounts struct {
+	counts []float64
+	// the epoch the newest count is for
+	epoch int64
+}
+
+// WithHitWindow counts only the hits of the last epochs periods of the given
+// length rather than every hit since an entry was set, so priorities reflect
+// recent demand and formerly popular entries lose their standing once they stop
+// being used.  Hits expire a whole epoch at a time: when an epoch ends every
+// entry's expired hits are taken off its priority, which takes time
+// proportional to the size of the cache.
+func WithHitWindow(epoch time.Duration, epochs int) Option {
+	return func(l *LFUDA) {
+		if epoch > 0 && epochs > 0 {
+			l.window = &hitWindow{
+				epoch:  epoch,
+				epochs: epochs,
+				start:  time.Now(),
+				counts: make(map[*item]*windowCounts),
+			}
+		}
+	}
+}
+
+func (w *hitWindow) now() int64 {
+	return int64(time.Since(w.start) / w.epoch)
+}
+
+// count adds hits to the entry's current epoch, returning its hits over the
+// window.
+func (w *hitWindow) count(e *item, hits float64) float64 {
+	c, ok := w.counts[e]
+	if !ok {
+		c = &windowCounts{counts: make([]float64, w.epochs), epoch: w.current}
+		w.counts[e] = c
+	}
+	c.advance(w.current)
+	c.counts[int(c.epoch%int64(len(c.counts)))] += hits
+	return c.sum()
+}
+
+// advance expires the counts of epochs which have left the window.
+func (c *windowCounts) advance(epoch int64) {
+	if epoch-c.epoch >= int64(len(c.counts)) {
+		for i := range c.counts {
+			c.counts[i] = 0
+		}
+		c.epoch = epoch
+		return
+	}
+	for c.epoch < epoch {
+		c.epoch++
+		c.counts[int(c.epoch%int64(len(c.counts)))] = 0
+	}
+}
+
+func (c *windowCounts) sum() float64 {
+	sum := 0.0
+	for _, n := range c.counts {
+		sum += n
+	}
+	return sum
+}
+
+// rollWindow expires hits which have left the window once an epoch ends,
+// lowering the priorities of entries which have lost hits and reordering the
+// frequency list to match.
+func (l *LFUDA) rollWindow() {
+	w := l.window
+	now := w.now()
+	if now == w.current {
+		return
+	}
+	w.current = now
+	changed := false
+	for e, c := range w.counts {
+		before := c.sum()
+		c.advance(now)
+		if after := c.sum(); after != before {
+			// take the expired hits' worth off the priority, as the age it
+			// was calculated against stays
+			worth := l.policy(l, e, 0)
+			e.hits = after
+			e.priorityKey -= worth - l.policy(l, e, 0)
+			if l.bucketWidth > 0 {
+				e.priorityKey = math.Floor(e.priorityKey/l.bucketWidth) * l.bucketWidth
+			}
+			changed = true
+		}
+	}
+	if changed {
+		l.relink()
+	}
+}
+
+// relink rebuilds the frequency list from the entries' priority keys.  Entries
+// sharing a priority are kept in order of last access.
+func (l *LFUDA) relink() {
+	entries := make([]*item, 0, len(l.items))
+	for _, e := range l.items {
+		// entries being set are detached, and placed once set
+		if e.freqNode != nil {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		if a.priorityKey != b.priorityKey {
+			return a.priorityKey < b.priorityKey
+		}
+		return a.lastAccess < b.lastAccess
+	})
+	l.freqs = freqList{}
+	for _, e := range entries {
+		e.freqNode, e.prev, e.next = nil, nil, nil
+		l.place(e)
+	}
+	l.restartWalks()
+}
@@ -0,0 +1,47 @@
+package simplelfuda
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHitWindow(t *testing.T) {
+	l := NewLFUDA(10, nil, WithHitWindow(time.Hour, 2))
+	passEpoch := func() {
+		l.window.start = l.window.start.Add(-time.Hour)
+	}
+
+	l.Set("a", "a")
+	for i := 0; i < 4; i++ {
+		l.Get("a")
+	}
+	l.Set("b", "b")
+	passEpoch()
+	l.Get("b")
+	l.Get("b")
+	if info, _ := l.Inspect("a"); info.Hits != 5 || info.Priority != 5 {
+		t.Fatalf("a's hits should all count so far: %+v", info)
+	}
+
+	// a's hits leave the window, b's last two don't
+	passEpoch()
+	l.Set("c", "c")
+	if info, _ := l.Inspect("a"); info.Hits != 0 || info.Priority != 0 {
+		t.Errorf("a's hits should have expired: %+v", info)
+	}
+	if info, _ := l.Inspect("b"); info.Hits != 2 || info.Priority != 2 {
+		t.Errorf("b should have kept its recent hits: %+v", info)
+	}
+	if v := l.NextVictims(1); v[0].Key != "a" {
+		t.Errorf("a should be evicted first now: %v", v)
+	}
+	if h := l.Health(); !h.Healthy() {
+		t.Errorf("unhealthy: %v", h.Problems)
+	}
+
+	l.Remove("a")
+	l.Purge()
+	if len(l.window.counts) != 0 {
+		t.Errorf("counts should be dropped with their entries: %v", l.window.counts)
+	}
+}
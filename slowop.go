@@ -0,0 +1,71 @@
+package lfuda
+
+import (
+	"time"
+
+	"github.com/bparli/lfuda-go/internal/keyhash"
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// SlowOp describes an operation which held the cache's lock longer than the
+// threshold set WithSlowOpHook.
+type SlowOp struct {
+	// Op names the operation, such as "Set"
+	Op string
+	// KeyHash is the hash of the operation's key, as returned by
+	// accesslog.Hash, or 0 for operations without one
+	KeyHash uint64
+	// Duration is how long the lock was held
+	Duration time.Duration
+	// Timings breaks down where the time went
+	Timings simplelfuda.Timings
+}
+
+// WithSlowOpHook calls fn, after the lock is released, for every operation
+// which held the cache's write lock longer than threshold, to pinpoint lock
+// hold outliers.
+func WithSlowOpHook(threshold time.Duration, fn func(SlowOp)) Option {
+	return func(c *config) {
+		c.slowThreshold = threshold
+		c.slowHook = fn
+		c.lfuda = append(c.lfuda, simplelfuda.WithTimings())
+	}
+}
+
+// slowTracer times write lock holds.  A nil tracer does nothing.
+type slowTracer struct {
+	threshold time.Duration
+	hook      func(SlowOp)
+
+	// the current operation, set by the write lock's holder
+	op    string
+	key   interface{}
+	began time.Time
+}
+
+// start times an operation.  Must be called with the write lock held.
+func (t *slowTracer) start(op string, key interface{}) {
+	if t != nil {
+		t.op, t.key, t.began = op, key, time.Now()
+	}
+}
+
+// stop ends timing an operation, returning it if it was slow.  Must be called
+// with the write lock held.
+func (t *slowTracer) stop(lfuda *simplelfuda.LFUDA) *SlowOp {
+	if t == nil {
+		return nil
+	}
+	elapsed := time.Since(t.began)
+	timings := lfuda.TakeTimings()
+	key := t.key
+	t.key = nil
+	if elapsed < t.threshold {
+		return nil
+	}
+	slow := &SlowOp{Op: t.op, Duration: elapsed, Timings: timings}
+	if key != nil {
+		slow.KeyHash = keyhash.Sum(key)
+	}
+	return slow
+}
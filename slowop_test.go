@@ -0,0 +1,38 @@
+package lfuda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bparli/lfuda-go/accesslog"
+)
+
+func TestSlowOpHook(t *testing.T) {
+	var slow []SlowOp
+	c := NewWithEvict(1, func(key, value interface{}) {
+		time.Sleep(20 * time.Millisecond)
+	}, WithSlowOpHook(10*time.Millisecond, func(op SlowOp) { slow = append(slow, op) }))
+
+	c.Set("a", "x")
+	c.Get("a")
+	if len(slow) != 0 {
+		t.Fatalf("fast operations should not be reported: %+v", slow)
+	}
+
+	c.Set("b", "x")
+	if len(slow) != 1 {
+		t.Fatalf("slow Set should be reported")
+	}
+	op := slow[0]
+	if op.Op != "Set" || op.KeyHash != accesslog.Hash("b") || op.Duration < 20*time.Millisecond || op.Timings.Callbacks < 20*time.Millisecond {
+		t.Errorf("bad report: %+v", op)
+	}
+	if op.Timings.Eviction >= op.Timings.Callbacks {
+		t.Errorf("callbacks should not count as eviction time: %+v", op.Timings)
+	}
+
+	c.Purge()
+	if len(slow) != 2 || slow[1].Op != "Purge" || slow[1].KeyHash != 0 {
+		t.Errorf("slow Purge should be reported: %+v", slow)
+	}
+}
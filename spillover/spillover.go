@@ -0,0 +1,211 @@
+// Package spillover tiers an lfuda cache of []byte values over an object store,
+// for CDN-like setups where losing a large value from memory shouldn't mean
+// going back to the origin.
+//
+// Values of at least a minimum size are uploaded to the store when they are
+// evicted, and a small stub is kept in a second lfuda cache recording that the
+// store has them.  A Get which misses memory but finds a stub restores the value
+// from the store.  Spilled values can be encoded by an lfuda.Codec, for example
+// to compress them.  Objects are deleted from the store once their stub is
+// dropped or the value is restored.
+//
+// Uploads and deletes are queued by the eviction callbacks and carried out by
+// whichever call triggered them after the caches' locks are released, so a
+// slow store never blocks other callers' cache operations.
+package spillover
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/bparli/lfuda-go"
+)
+
+// ObjectStore is the client for the store values spill over to, such as a thin
+// wrapper around an S3 or GCS bucket.  Objects are named by their cache key.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Cache is a two tier cache of []byte values.
+type Cache struct {
+	cache    *lfuda.Cache
+	stubs    *lfuda.Cache
+	store    ObjectStore
+	codec    lfuda.Codec
+	minSpill int
+
+	mu      sync.Mutex
+	pending []op
+	// keys being removed, whose evictions must not spill
+	removed map[string]int
+	purging bool
+
+	// serializes running pending ops so they reach the store in order
+	run sync.Mutex
+}
+
+// op is a queued store operation
+type op struct {
+	key  string
+	data []byte
+	del  bool
+}
+
+// New creates a Cache holding size bytes of values in memory using the GDSF
+// policy, and stubs for up to stubSize bytes of keys of spilled values.  Values
+// of at least minSpill bytes are spilled to store when evicted.
+func New(size, stubSize float64, minSpill int, store ObjectStore) *Cache {
+	return NewWithCodec(size, stubSize, minSpill, store, lfuda.BytesCodec{})
+}
+
+// NewWithCodec is like New but encodes spilled values with codec, which must
+// marshal []byte values and unmarshal them back to []byte.
+func NewWithCodec(size, stubSize float64, minSpill int, store ObjectStore, codec lfuda.Codec) *Cache {
+	c := &Cache{
+		store:    store,
+		codec:    codec,
+		minSpill: minSpill,
+		removed:  make(map[string]int),
+	}
+	c.cache = lfuda.NewGDSFWithEvict(size, c.onEvict)
+	c.stubs = lfuda.NewWithEvict(stubSize, c.onStubEvict)
+	return c
+}
+
+func (c *Cache) onEvict(key, value interface{}) {
+	data := value.([]byte)
+	c.mu.Lock()
+	if !c.purging && c.removed[key.(string)] == 0 && len(data) >= c.minSpill {
+		c.pending = append(c.pending, op{key: key.(string), data: data})
+	}
+	c.mu.Unlock()
+}
+
+func (c *Cache) onStubEvict(key, value interface{}) {
+	c.mu.Lock()
+	c.pending = append(c.pending, op{key: key.(string), del: true})
+	c.mu.Unlock()
+}
+
+// flush carries out queued store operations, returning the first error.
+func (c *Cache) flush(ctx context.Context) error {
+	c.run.Lock()
+	defer c.run.Unlock()
+	var first error
+	for {
+		c.mu.Lock()
+		ops := c.pending
+		c.pending = nil
+		c.mu.Unlock()
+		if len(ops) == 0 {
+			return first
+		}
+
+		for _, o := range ops {
+			var err error
+			if o.del {
+				err = c.store.Delete(ctx, o.key)
+			} else {
+				err = c.spill(ctx, o)
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+}
+
+func (c *Cache) spill(ctx context.Context, o op) error {
+	data, err := c.codec.Marshal(o.data)
+	if err != nil {
+		return err
+	}
+	if err := c.store.Put(ctx, o.key, data); err != nil {
+		return err
+	}
+	// may evict other stubs, queueing their deletes for the next pass
+	c.stubs.SetWithSize(o.key, struct{}{}, float64(len(o.key)))
+	return nil
+}
+
+// Get looks up a key's value, restoring it from the store if it was spilled.
+// Errors from the store are returned with ok false.
+func (c *Cache) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	if v, ok := c.cache.Get(key); ok {
+		return v.([]byte), true, nil
+	}
+	if !c.stubs.Contains(key) {
+		return nil, false, nil
+	}
+
+	stored, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	v, err := c.codec.Unmarshal(stored)
+	if err != nil {
+		return nil, false, err
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("spillover: codec unmarshaled %T, not []byte", v)
+	}
+	c.cache.Set(key, data)
+	c.stubs.Remove(key)
+	return data, true, c.flush(ctx)
+}
+
+// Set adds a value to the in-memory tier, spilling any values it evicts.
+// Returns true if an eviction occurred, and the first error from the store.
+func (c *Cache) Set(ctx context.Context, key string, value []byte) (bool, error) {
+	evicted := c.cache.Set(key, value)
+	// a spilled copy is now stale
+	c.stubs.Remove(key)
+	return evicted, c.flush(ctx)
+}
+
+// Spilled checks if a key's value is held in the store.
+func (c *Cache) Spilled(key string) bool {
+	return c.stubs.Contains(key)
+}
+
+// Remove removes a key from both tiers, returning if it was in either.
+func (c *Cache) Remove(ctx context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	c.removed[key]++
+	c.mu.Unlock()
+	present := c.cache.Remove(key)
+	c.mu.Lock()
+	if c.removed[key]--; c.removed[key] == 0 {
+		delete(c.removed, key)
+	}
+	c.mu.Unlock()
+
+	if c.stubs.Remove(key) {
+		present = true
+	}
+	return present, c.flush(ctx)
+}
+
+// Purge clears both tiers, deleting every spilled value from the store.
+func (c *Cache) Purge(ctx context.Context) error {
+	c.mu.Lock()
+	c.purging = true
+	c.mu.Unlock()
+	c.cache.Purge()
+	c.mu.Lock()
+	c.purging = false
+	c.mu.Unlock()
+
+	c.stubs.Purge()
+	return c.flush(ctx)
+}
+
+// Cache returns the in-memory tier.
+func (c *Cache) Cache() *lfuda.Cache {
+	return c.cache
+}
@@ -0,0 +1,149 @@
+package spillover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/bparli/lfuda-go"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    bool
+}
+
+func (m *memStore) Put(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("unavailable")
+	}
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.New("no such object")
+	}
+	return data, nil
+}
+
+func (m *memStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memStore) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.objects)
+}
+
+func TestSpillover(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{objects: make(map[string][]byte)}
+	c := New(10, 100, 4, store)
+
+	big := bytes.Repeat([]byte("a"), 8)
+	c.Set(ctx, "big", big)
+	c.Set(ctx, "small", []byte("s"))
+
+	// big has the lowest GDSF priority, so it spills
+	if _, err := c.Set(ctx, "other", []byte("oo")); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cache().Contains("big") || !c.Spilled("big") || store.len() != 1 {
+		t.Fatalf("big should have spilled")
+	}
+
+	// small values are just dropped
+	c.Set(ctx, "big2", big)
+	if c.Spilled("small") || c.Spilled("other") {
+		t.Errorf("values under minSpill should not spill")
+	}
+
+	v, ok, err := c.Get(ctx, "big")
+	if err != nil || !ok || !bytes.Equal(v, big) {
+		t.Fatalf("big should be restored: %q, %v, %v", v, ok, err)
+	}
+	if !c.Cache().Contains("big") || c.Spilled("big") {
+		t.Errorf("restored values should move back into memory")
+	}
+
+	// restoring big evicted big2
+	if !c.Spilled("big2") || store.len() != 1 {
+		t.Errorf("big2 should be the only spilled value: %d", store.len())
+	}
+
+	if present, _ := c.Remove(ctx, "big2"); !present || store.len() != 0 {
+		t.Errorf("Remove should delete spilled values from the store")
+	}
+	if present, _ := c.Remove(ctx, "big"); !present || store.len() != 0 || c.Spilled("big") {
+		t.Errorf("removed values should not spill")
+	}
+
+	if _, ok, _ := c.Get(ctx, "nothing"); ok {
+		t.Errorf("should be a miss")
+	}
+}
+
+func TestSpilloverErrors(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{objects: make(map[string][]byte), fail: true}
+	c := New(10, 100, 1, store)
+	c.Set(ctx, "a", []byte("aaaaaa"))
+	if _, err := c.Set(ctx, "b", []byte("bbbbbb")); err == nil {
+		t.Errorf("upload error should be returned")
+	}
+	if c.Spilled("a") {
+		t.Errorf("failed uploads should not leave a stub")
+	}
+}
+
+func TestStubEviction(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{objects: make(map[string][]byte)}
+	// room for one value in memory and two stubs
+	c := New(4, 2, 1, store)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(ctx, key, []byte("1234"))
+	}
+	if store.len() != 2 || !c.Spilled("c") {
+		t.Errorf("only two stubs should be kept: %d", store.len())
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if _, ok := store.objects[key]; ok != c.Spilled(key) {
+			t.Errorf("objects should be deleted with their stubs: %s", key)
+		}
+	}
+
+	if err := c.Purge(ctx); err != nil || store.len() != 0 || c.Cache().Len() != 0 {
+		t.Errorf("Purge should clear both tiers")
+	}
+}
+
+func TestSpilloverCodec(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{objects: make(map[string][]byte)}
+	c := NewWithCodec(100, 100, 1, store, lfuda.GzipCodec{})
+	big := bytes.Repeat([]byte("a"), 100)
+	c.Set(ctx, "a", big)
+	c.Set(ctx, "b", []byte("b"))
+
+	if len(store.objects["a"]) >= len(big) {
+		t.Errorf("spilled value should have been compressed")
+	}
+	if v, ok, err := c.Get(ctx, "a"); err != nil || !ok || !bytes.Equal(v, big) {
+		t.Errorf("bad restore: %v, %v", ok, err)
+	}
+}
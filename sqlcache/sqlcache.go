@@ -0,0 +1,290 @@
+// Package sqlcache caches database/sql query results in an lfuda cache.
+//
+// Results are keyed by the whitespace-normalized SQL plus its arguments and
+// are fully materialized, so they are sized by the cache like any other value.
+// Each query can be tagged (typically with the tables it reads) and all results
+// carrying a tag are dropped by Invalidate, e.g. after writing to that table.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bparli/lfuda-go"
+)
+
+// Queryer is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Result is a materialized query result.  Results are shared between callers
+// and must not be modified.
+type Result struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Cache caches query results.
+type Cache struct {
+	cache *lfuda.Cache
+
+	mu sync.Mutex
+	// keys carrying each tag, and the tags of each key
+	tagged map[string]map[string]struct{}
+	tags   map[string][]string
+	// bumped on every invalidation so in-flight queries don't cache stale rows
+	gens map[string]uint64
+}
+
+// New creates a Cache holding up to size bytes of results under the GDSF policy.
+func New(size float64) *Cache {
+	c := &Cache{
+		tagged: make(map[string]map[string]struct{}),
+		tags:   make(map[string][]string),
+		gens:   make(map[string]uint64),
+	}
+	c.cache = lfuda.NewGDSFWithEvict(size, c.evicted)
+	return c
+}
+
+// Query returns the result of query from the cache, or runs it against db and
+// caches the result under the given tags.
+func (c *Cache) Query(ctx context.Context, db Queryer, tags []string, query string, args ...interface{}) (*Result, error) {
+	key := Key(query, args...)
+	if res, ok := c.cache.Get(key); ok {
+		return res.(*Result), nil
+	}
+
+	gens := c.generations(tags)
+	res, err := run(ctx, db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// register the tags first so an Invalidate racing with the Set below
+	// finds the key, and drop the entry if one happened anyway
+	if !c.register(key, tags, gens) {
+		return res, nil
+	}
+	c.cache.Set(key, res)
+	if !c.current(tags, gens) || !c.cache.Contains(key) {
+		c.cache.Remove(key)
+		c.evicted(key, res)
+	}
+	return res, nil
+}
+
+// Invalidate removes every cached result carrying any of the given tags.
+func (c *Cache) Invalidate(tags ...string) {
+	var keys []string
+	c.mu.Lock()
+	for _, tag := range tags {
+		c.gens[tag]++
+		for key := range c.tagged[tag] {
+			keys = append(keys, key)
+		}
+	}
+	c.mu.Unlock()
+
+	// removing calls back into evicted, which takes c.mu
+	for _, key := range keys {
+		c.cache.Remove(key)
+	}
+}
+
+// Purge removes every cached result.
+func (c *Cache) Purge() {
+	c.cache.Purge()
+}
+
+// Cache returns the underlying lfuda cache.
+func (c *Cache) Cache() *lfuda.Cache {
+	return c.cache
+}
+
+func (c *Cache) generations(tags []string) []uint64 {
+	gens := make([]uint64, len(tags))
+	c.mu.Lock()
+	for i, tag := range tags {
+		gens[i] = c.gens[tag]
+	}
+	c.mu.Unlock()
+	return gens
+}
+
+// register indexes key under tags, unless any tag was invalidated since gens
+// were taken.
+func (c *Cache) register(key string, tags []string, gens []uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, tag := range tags {
+		if c.gens[tag] != gens[i] {
+			return false
+		}
+	}
+	for _, tag := range tags {
+		if c.tagged[tag] == nil {
+			c.tagged[tag] = make(map[string]struct{})
+		}
+		c.tagged[tag][key] = struct{}{}
+	}
+	c.tags[key] = tags
+	return true
+}
+
+func (c *Cache) current(tags []string, gens []uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, tag := range tags {
+		if c.gens[tag] != gens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cache) evicted(key, value interface{}) {
+	k := key.(string)
+	c.mu.Lock()
+	for _, tag := range c.tags[k] {
+		delete(c.tagged[tag], k)
+		if len(c.tagged[tag]) == 0 {
+			delete(c.tagged, tag)
+		}
+	}
+	delete(c.tags, k)
+	c.mu.Unlock()
+}
+
+// Key returns the cache key for a query: its whitespace-normalized text
+// followed by the type and value of each argument, each prefixed by its length
+// so no query and arguments can run together into another's.  Only whitespace
+// outside quotes and comments is normalized, so queries differing in the
+// spacing of a string literal or quoted identifier get different keys.
+// Pointer arguments are keyed by what they point to, as they are when the query
+// is run, rather than by their address.
+func Key(query string, args ...interface{}) string {
+	var normalized strings.Builder
+	normalize(&normalized, query)
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d:%s", normalized.Len(), normalized.String())
+	for _, arg := range args {
+		arg = deref(arg)
+		value := fmt.Sprint(arg)
+		fmt.Fprintf(&key, "\x00%T:%d:%s", arg, len(value), value)
+	}
+	return key.String()
+}
+
+// deref follows pointers to the value they point to, leaving nil pointers nil.
+func deref(arg interface{}) interface{} {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// normalize writes query to b with each run of whitespace outside quotes and
+// comments replaced by a single space, and leading and trailing whitespace
+// removed.  Quotes are ended by the same quote character, doubled quotes
+// staying quoted.  Backslash escapes and dollar quoting vary between databases,
+// so queries containing them are written verbatim rather than risk normalizing
+// inside a literal.
+func normalize(b *strings.Builder, query string) {
+	if strings.ContainsRune(query, '\\') || dollarQuote.MatchString(query) {
+		b.WriteString(query)
+		return
+	}
+	space := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		if isSpace(c) {
+			space = true
+			i++
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		end := i + 1
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// a doubled quote ends the string and starts it again, leaving it
+			// quoted as a whole
+			if n := strings.IndexByte(query[end:], c); n >= 0 {
+				end += n + 1
+			} else {
+				end = len(query)
+			}
+		case strings.HasPrefix(query[i:], "--"):
+			// the newline ending the comment is kept in place of the
+			// whitespace after it, which would otherwise join the comment
+			if n := strings.IndexByte(query[i:], '\n'); n >= 0 {
+				end = i + n + 1
+				b.WriteString(query[i:end])
+				for i = end; i < len(query) && isSpace(query[i]); i++ {
+				}
+				continue
+			} else {
+				end = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if n := strings.Index(query[i+2:], "*/"); n >= 0 {
+				end = i + 2 + n + 2
+			} else {
+				end = len(query)
+			}
+		}
+		b.WriteString(query[i:end])
+		i = end
+	}
+}
+
+// dollarQuote matches the start of a PostgreSQL dollar-quoted string.
+var dollarQuote = regexp.MustCompile(`\$[A-Za-z_]*\$`)
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func run(ctx context.Context, db Queryer, query string, args ...interface{}) (*Result, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Columns: cols}
+	for rows.Next() {
+		// scanning into *interface{} copies any []byte the driver returns
+		row := make([]interface{}, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,155 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDriver answers every query with a single row holding the query's first
+// argument and counts the queries it has run.
+type fakeDriver struct{ queries int32 }
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s fakeStmt) Close() error                                    { return nil }
+func (s fakeStmt) NumInput() int                                   { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt32(&s.d.queries, 1)
+	return &fakeRows{value: args[0]}, nil
+}
+
+type fakeRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+var drivers int32
+
+func openDB(t *testing.T) (*sql.DB, *fakeDriver) {
+	d := &fakeDriver{}
+	name := "sqlcache-fake-" + string(rune('a'+atomic.AddInt32(&drivers, 1)))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+func TestQueryCaches(t *testing.T) {
+	db, d := openDB(t)
+	c := New(1 << 20)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := c.Query(ctx, db, []string{"users"}, "SELECT name FROM users\n  WHERE id = ?", int64(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Rows) != 1 || res.Rows[0][0] != int64(1) || res.Columns[0] != "value" {
+			t.Fatalf("bad result: %+v", res)
+		}
+	}
+	// whitespace differences normalize to the same key
+	c.Query(ctx, db, []string{"users"}, "SELECT name FROM users WHERE id = ?", int64(1))
+	if n := atomic.LoadInt32(&d.queries); n != 1 {
+		t.Errorf("result should have been served from the cache: %d queries", n)
+	}
+
+	c.Query(ctx, db, []string{"users"}, "SELECT name FROM users WHERE id = ?", int64(2))
+	if n := atomic.LoadInt32(&d.queries); n != 2 {
+		t.Errorf("different arguments should not share entries: %d queries", n)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	db, d := openDB(t)
+	c := New(1 << 20)
+	ctx := context.Background()
+
+	c.Query(ctx, db, []string{"users"}, "SELECT * FROM users WHERE id = ?", "a")
+	c.Query(ctx, db, []string{"orders"}, "SELECT * FROM orders WHERE id = ?", "b")
+	c.Query(ctx, db, []string{"users", "orders"}, "SELECT * FROM users JOIN orders WHERE id = ?", "c")
+
+	c.Invalidate("users")
+	if c.Cache().Len() != 1 {
+		t.Errorf("only the orders query should remain: %v", c.Cache().Keys())
+	}
+
+	c.Query(ctx, db, []string{"orders"}, "SELECT * FROM orders WHERE id = ?", "b")
+	c.Query(ctx, db, []string{"users"}, "SELECT * FROM users WHERE id = ?", "a")
+	if n := atomic.LoadInt32(&d.queries); n != 4 {
+		t.Errorf("invalidated query should run again: %d queries", n)
+	}
+
+	c.Purge()
+	if len(c.tagged) != 0 || len(c.tags) != 0 {
+		t.Errorf("tag index should be empty after Purge: %v %v", c.tagged, c.tags)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("SELECT  1\n", 1) == Key("SELECT 1", "1") {
+		t.Errorf("arguments of different types should not share keys")
+	}
+	if Key(" SELECT\t1 ") != Key("SELECT 1") {
+		t.Errorf("query should be normalized: %q", Key(" SELECT\t1 "))
+	}
+	if Key("SELECT ?", "a\x00string:1:b") == Key("SELECT ?", "a", "b") {
+		t.Errorf("arguments shouldn't run together")
+	}
+	if Key("SELECT ?\x00string:1:a") == Key("SELECT ?", "a") {
+		t.Errorf("the query and arguments shouldn't run together")
+	}
+	n, m := 1, 1
+	if Key("SELECT ?", &n) != Key("SELECT ?", &m) || Key("SELECT ?", &n) != Key("SELECT ?", 1) {
+		t.Errorf("pointers should be keyed by their targets")
+	}
+	var nilp *int
+	if Key("SELECT ?", nilp) != Key("SELECT ?", nil) {
+		t.Errorf("nil pointers should be keyed as nil")
+	}
+	if Key("SELECT * FROM t WHERE name = 'a  b'") == Key("SELECT * FROM t WHERE name = 'a b'") {
+		t.Errorf("whitespace in string literals should be kept")
+	}
+	if Key(`SELECT "a  b" FROM t`) == Key(`SELECT "a b" FROM t`) {
+		t.Errorf("whitespace in quoted identifiers should be kept")
+	}
+	for query, want := range map[string]string{
+		"SELECT  'it''s  here' ,\n x": "SELECT 'it''s  here' , x",
+		"SELECT 1 -- don't\n  FROM t": "SELECT 1 -- don't\nFROM t",
+		"SELECT /* it's */  'a  b'":   "SELECT /* it's */ 'a  b'",
+		"SELECT 'a\\'  b'":            "SELECT 'a\\'  b'",
+	} {
+		var got strings.Builder
+		if normalize(&got, query); got.String() != want {
+			t.Errorf("bad normalized query for %q: %q", query, got.String())
+		}
+	}
+}
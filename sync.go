@@ -0,0 +1,165 @@
+package lfuda
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bparli/lfuda-go/simplelfuda"
+)
+
+// DiffKeys compares the keys in the cache with those in other, returning the
+// keys only found here and those only found in other.
+func (c *Cache) DiffKeys(other *Cache) (onlyHere, onlyThere []interface{}) {
+	here := c.Keys()
+	for i, present := range other.ContainsMulti(here) {
+		if !present {
+			onlyHere = append(onlyHere, here[i])
+		}
+	}
+	there := other.Keys()
+	for i, present := range c.ContainsMulti(there) {
+		if !present {
+			onlyThere = append(onlyThere, there[i])
+		}
+	}
+	return onlyHere, onlyThere
+}
+
+// SyncFrom copies up to limit of the highest priority entries in other which are
+// missing here, carrying over their hits, so two instances such as blue/green
+// deployments can reconcile their hot sets.  Returns the number of entries
+// copied.
+func (c *Cache) SyncFrom(other *Cache, limit int) int {
+	if limit <= 0 || other == c {
+		return 0
+	}
+	entries := other.Entries()
+	keys := make([]interface{}, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	present := c.ContainsMulti(keys)
+
+	missing := entries[:0]
+	keys = keys[:0]
+	for i, e := range entries {
+		if !present[i] && len(missing) < limit {
+			missing = append(missing, e)
+			keys = append(keys, e.Key)
+		}
+	}
+	values := other.PeekMulti(keys)
+
+	copied := 0
+	c.writeLock("SyncFrom", nil)
+	for _, e := range missing {
+		// skip entries which have since been evicted from other or set here
+		value, ok := values[e.Key]
+		if !ok || c.lfuda.Contains(e.Key) {
+			continue
+		}
+		if c.lfuda.Import(e, value) {
+			copied++
+		}
+	}
+	c.publish()
+	c.writeUnlock()
+	return copied
+}
+
+// Merge folds other's entries into the cache, summing the hits of keys present
+// in both and taking the larger of the two caches' ages, class by class, for
+// consolidating per-worker caches into a shared one.  If they don't all fit
+// it's other's hottest entries which are kept.  Expired entries are left out.
+func (c *Cache) Merge(other *Cache) {
+	if other == c {
+		return
+	}
+	other.lock.RLock()
+	entries := other.lfuda.Entries()
+	age, classAges := other.lfuda.Age(), other.lfuda.ClassAges()
+	other.lock.RUnlock()
+
+	now := time.Now()
+	c.writeLock("Merge", nil)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !expired(entries[i], now) {
+			c.lfuda.Import(entries[i], entries[i].Value)
+		}
+	}
+	c.lfuda.RaiseAge(age)
+	for class, age := range classAges {
+		c.lfuda.RaiseClassAge(class, age)
+	}
+	c.publish()
+	c.writeUnlock()
+}
+
+// expired reports whether an entry had expired by now.
+func expired(e simplelfuda.EntryInfo, now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+// Split partitions the cache's entries with partition, or
+// simplelfuda.HashPartition if nil, into n new caches, each with an nth of the
+// size and entry limit and created with the same policy, eviction callback and
+// options followed by opts, keeping entries' hits and the cache's ages.  Used
+// when migrating from a single instance to a sharded deployment, so partition
+// must route keys exactly as the deployment's router does, or each part will
+// hold keys its shard never sees.  Panics if partition returns an index out of
+// range.
+// Unless opts include WithMetrics the parts report to the cache's metrics, as
+// registering the same metrics again would clash.  Expired entries are left
+// out, and the parts aren't managed even if the cache is.  The cache itself is
+// left unchanged.
+func (c *Cache) Split(n int, partition simplelfuda.Partition, opts ...Option) []*Cache {
+	if n < 1 {
+		return nil
+	}
+	if partition == nil {
+		partition = simplelfuda.HashPartition
+	}
+	c.lock.RLock()
+	entries := c.lfuda.Entries()
+	size := c.lfuda.Capacity()
+	age, classAges := c.lfuda.Age(), c.lfuda.ClassAges()
+	c.lock.RUnlock()
+
+	var cfg config
+	for _, opt := range c.opts {
+		opt(&cfg)
+	}
+	partOpts := append(c.opts[:len(c.opts):len(c.opts)], func(cfg *config) {
+		cfg.onVictim, cfg.onMiss = nil, nil
+		cfg.instruments = c.metrics
+	})
+	if cfg.maxEntries > 0 {
+		partOpts = append(partOpts, WithMaxEntries((cfg.maxEntries+n-1)/n))
+	}
+	partOpts = append(partOpts, opts...)
+	parts := make([]*Cache, n)
+	for i := range parts {
+		parts[i] = newWithEvict(size/float64(n), c.policy, c.onEvicted, partOpts)
+		parts[i].lfuda.RaiseAge(age)
+		for class, age := range classAges {
+			parts[i].lfuda.RaiseClassAge(class, age)
+		}
+	}
+	// import lowest priority first, so if a part overflows its hottest entries
+	// are kept
+	now := time.Now()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if expired(entries[i], now) {
+			continue
+		}
+		p := partition(entries[i].Key, n)
+		if p < 0 || p >= n {
+			panic(fmt.Sprintf("lfuda: partition returned %d for %d parts", p, n))
+		}
+		parts[p].lfuda.Import(entries[i], entries[i].Value)
+	}
+	for _, part := range parts {
+		part.publish()
+	}
+	return parts
+}
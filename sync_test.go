@@ -0,0 +1,156 @@
+package lfuda
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDiffKeys(t *testing.T) {
+	a, b := New(10), New(10)
+	a.Set(1, 1)
+	a.Set(2, 2)
+	b.Set(2, 2)
+	b.Set(3, 3)
+	onlyHere, onlyThere := a.DiffKeys(b)
+	if fmt.Sprint(onlyHere) != "[1]" || fmt.Sprint(onlyThere) != "[3]" {
+		t.Errorf("bad diff: %v, %v", onlyHere, onlyThere)
+	}
+}
+
+func TestSyncFrom(t *testing.T) {
+	blue, green := New(10), New(10)
+	for i := 0; i < 5; i++ {
+		blue.Set(i, i)
+		for j := 0; j < i; j++ {
+			blue.Get(i)
+		}
+	}
+	green.Set(4, 4)
+
+	// 4 is already present, so 3 and 2 are the hottest missing entries
+	if n := green.SyncFrom(blue, 2); n != 2 {
+		t.Errorf("two entries should have been copied: %d", n)
+	}
+	if !green.Contains(3) || !green.Contains(2) || green.Contains(1) {
+		t.Errorf("the hottest entries should have been copied: %v", green.Keys())
+	}
+	if info, _ := green.Inspect(3); info.Hits != 4 {
+		t.Errorf("hits should be carried over: %+v", info)
+	}
+	if info, _ := green.Inspect(4); info.Hits != 1 {
+		t.Errorf("present entries should be left alone: %+v", info)
+	}
+	if n := green.SyncFrom(green, 10); n != 0 {
+		t.Errorf("a cache can't sync from itself")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	shared, worker := New(10), New(10)
+	shared.Set(1, 1)
+	shared.Get(1)
+	worker.Set(1, 1)
+	worker.Set(2, 2)
+	worker.Get(2)
+	worker.Evict(1)
+	worker.Set(3, 3)
+
+	shared.Merge(worker)
+	if info, _ := shared.Inspect(3); info.Hits != 1 {
+		t.Errorf("new keys should keep their hits: %+v", info)
+	}
+	if !shared.Contains(1) {
+		t.Errorf("1 was evicted from the worker so only the shared copy should remain")
+	}
+	if shared.Age() != worker.Age() || shared.Age() == 0 {
+		t.Errorf("the larger age should be taken: %v", shared.Age())
+	}
+}
+
+func TestSplit(t *testing.T) {
+	l := New(100)
+	for i := 0; i < 30; i++ {
+		l.Set(i, "x")
+	}
+	parts := l.Split(3, nil)
+	total := 0
+	for _, p := range parts {
+		total += p.Len()
+		if len(p.Keys()) != p.Len() {
+			t.Errorf("parts should be published: %d != %d", len(p.Keys()), p.Len())
+		}
+	}
+	if total != 30 {
+		t.Errorf("every entry should be in one part: %d", total)
+	}
+
+	// keys routed as an external router does
+	byValue := func(key interface{}, n int) int { return key.(int) % n }
+	for i, p := range l.Split(3, byValue) {
+		for _, key := range p.Keys() {
+			if key.(int)%3 != i {
+				t.Errorf("%v should be in part %d", key, key.(int)%3)
+			}
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("out of range partitions should panic")
+		}
+	}()
+	l.Split(3, func(key interface{}, n int) int { return n })
+}
+
+func TestSplitOptions(t *testing.T) {
+	h := &recordingHooks{}
+	m := &testMetrics{values: make(map[string]float64), counts: make(map[string]int)}
+	l := New(100, WithHooks(h), WithMetrics(m), WithMaxEntries(31))
+	for i := 0; i < 30; i++ {
+		l.Set(i, "x")
+	}
+	l.SetWithTTL("expired", "x", time.Nanosecond)
+	l.lfuda.RaiseClassAge(1, 5)
+	time.Sleep(time.Millisecond)
+
+	parts := l.Split(3, nil)
+	total := 0
+	for _, p := range parts {
+		total += p.Len()
+		if p.lfuda.Capacity() != 100.0/3 || p.lfuda.ClassAges()[1] != 5 {
+			t.Errorf("parts should have a third of the size and the ages: %v, %v", p.lfuda.Capacity(), p.lfuda.ClassAges())
+		}
+		if p.hooks != h || p.metrics != l.metrics {
+			t.Errorf("parts should keep the cache's options")
+		}
+	}
+	if total != 30 {
+		t.Errorf("expired entries should be left out: %d", total)
+	}
+	parts[0].Get("missing")
+	if len(h.misses) != 1 || m.values["misses"] != 1 {
+		t.Errorf("parts should report to the cache's hooks and metrics: %v, %v", h.misses, m.values)
+	}
+	for i := 30; i < 50; i++ {
+		parts[0].Set(i, "x")
+	}
+	if parts[0].Len() != 11 {
+		t.Errorf("parts should have a third of the entry limit: %d", parts[0].Len())
+	}
+}
+
+func TestMergeExpiredAndClassAges(t *testing.T) {
+	shared, worker := New(10), New(10)
+	worker.Set("a", "x")
+	worker.SetWithTTL("expired", "x", time.Nanosecond)
+	worker.lfuda.RaiseClassAge(2, 7)
+	time.Sleep(time.Millisecond)
+
+	shared.Merge(worker)
+	if shared.Contains("expired") || !shared.Contains("a") {
+		t.Errorf("only unexpired entries should be merged: %v", shared.Keys())
+	}
+	if ages := shared.lfuda.ClassAges(); ages[2] != 7 {
+		t.Errorf("class ages should be merged: %v", ages)
+	}
+}
@@ -0,0 +1,79 @@
+// Package tokencache caches OAuth/JWT style access tokens keyed by scope.
+//
+// It is built on recordcache: every token is cached with a TTL ending a
+// configurable window before the token itself expires, so a token is never
+// handed out when it is about to expire and the next request for its scope
+// refreshes it through the loader instead.
+package tokencache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bparli/lfuda-go/recordcache"
+)
+
+// ErrExpired is returned when the loader returns a token which has already expired.
+var ErrExpired = errors.New("tokencache: loaded token has already expired")
+
+// ErrExpiring is returned when the loader returns a token which expires within
+// the window, so would be about to expire as soon as it was handed out.
+var ErrExpiring = errors.New("tokencache: loaded token expires within the window")
+
+// Token is an access token and the time it expires.
+type Token struct {
+	Value  string
+	Expiry time.Time
+}
+
+// Loader fetches a new token for a scope.
+type Loader func(scope string) (Token, error)
+
+// Cache caches tokens by scope.
+type Cache struct {
+	records *recordcache.Cache
+	window  time.Duration
+	load    Loader
+	now     func() time.Time
+}
+
+// New creates a Cache of the given size in bytes which loads tokens with load
+// and stops returning them window before they expire.
+func New(size float64, window time.Duration, load Loader) *Cache {
+	return &Cache{
+		records: recordcache.New(size),
+		window:  window,
+		load:    load,
+		now:     time.Now,
+	}
+}
+
+// Token returns a token for scope which is valid for at least the window,
+// loading a new one if necessary.  Concurrent requests for the same scope share
+// a single load.
+func (c *Cache) Token(scope string) (Token, error) {
+	tok, _, err := c.records.Lookup(scope, func() (interface{}, time.Duration, error) {
+		tok, err := c.load(scope)
+		if err != nil {
+			return nil, 0, err
+		}
+		now := c.now()
+		if !tok.Expiry.After(now) {
+			return nil, 0, ErrExpired
+		}
+		ttl := tok.Expiry.Sub(now) - c.window
+		if ttl <= 0 {
+			return nil, 0, ErrExpiring
+		}
+		return tok, ttl, nil
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	return tok.(Token), nil
+}
+
+// Invalidate drops the cached token for scope, e.g. after the server rejected it.
+func (c *Cache) Invalidate(scope string) {
+	c.records.Remove(scope)
+}
@@ -0,0 +1,56 @@
+package tokencache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	loads := 0
+	c := New(1000, time.Minute, func(scope string) (Token, error) {
+		loads++
+		return Token{
+			Value:  fmt.Sprintf("%s-%d", scope, loads),
+			Expiry: time.Now().Add(time.Hour),
+		}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if tok, err := c.Token("read"); err != nil || tok.Value != "read-1" {
+			t.Fatalf("bad token: %v, %v", tok, err)
+		}
+	}
+	if tok, _ := c.Token("write"); tok.Value != "write-2" {
+		t.Errorf("scopes should not share tokens: %v", tok)
+	}
+
+	c.Invalidate("read")
+	if tok, _ := c.Token("read"); tok.Value != "read-3" {
+		t.Errorf("invalidated token should be reloaded: %v", tok)
+	}
+}
+
+func TestTokenWindow(t *testing.T) {
+	expiry := time.Now().Add(30 * time.Second)
+	loads := 0
+	c := New(1000, time.Minute, func(scope string) (Token, error) {
+		loads++
+		return Token{Value: "t", Expiry: expiry}, nil
+	})
+
+	// the token is inside the window so it is refused, and reloaded next time
+	for i := 0; i < 2; i++ {
+		if tok, err := c.Token("read"); err != ErrExpiring || tok != (Token{}) {
+			t.Errorf("tokens within the expiry window should be refused: %v, %v", tok, err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("tokens within the expiry window should be refreshed: %d loads", loads)
+	}
+
+	expiry = time.Now().Add(-time.Second)
+	if _, err := c.Token("read"); err != ErrExpired {
+		t.Errorf("expired tokens should be refused: %v", err)
+	}
+}
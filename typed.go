@@ -0,0 +1,57 @@
+package lfuda
+
+import "fmt"
+
+// TypeError is returned by the typed getters when a key's value isn't of the
+// requested type.
+type TypeError struct {
+	Key   interface{}
+	Want  string
+	Value interface{}
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("lfuda: value of key %v is %T, not %s", e.Key, e.Value, e.Want)
+}
+
+// GetString looks up a key's string value.  ok reports whether the key was
+// found, and err is a *TypeError if its value isn't a string.
+func (c *Cache) GetString(key interface{}) (value string, ok bool, err error) {
+	v, ok := c.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	value, isType := v.(string)
+	if !isType {
+		return "", true, &TypeError{Key: key, Want: "string", Value: v}
+	}
+	return value, true, nil
+}
+
+// GetBytes looks up a key's []byte value.  ok reports whether the key was found,
+// and err is a *TypeError if its value isn't a []byte.
+func (c *Cache) GetBytes(key interface{}) (value []byte, ok bool, err error) {
+	v, ok := c.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	value, isType := v.([]byte)
+	if !isType {
+		return nil, true, &TypeError{Key: key, Want: "[]byte", Value: v}
+	}
+	return value, true, nil
+}
+
+// GetInt64 looks up a key's int64 value.  ok reports whether the key was found,
+// and err is a *TypeError if its value isn't an int64.
+func (c *Cache) GetInt64(key interface{}) (value int64, ok bool, err error) {
+	v, ok := c.Get(key)
+	if !ok {
+		return 0, false, nil
+	}
+	value, isType := v.(int64)
+	if !isType {
+		return 0, true, &TypeError{Key: key, Want: "int64", Value: v}
+	}
+	return value, true, nil
+}
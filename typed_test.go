@@ -0,0 +1,32 @@
+package lfuda
+
+import "testing"
+
+func TestTypedGetters(t *testing.T) {
+	c := New(100)
+	c.Set("s", "str")
+	c.Set("b", []byte("bytes"))
+	c.Set("i", int64(42))
+
+	if v, ok, err := c.GetString("s"); v != "str" || !ok || err != nil {
+		t.Errorf("bad string: %q, %v, %v", v, ok, err)
+	}
+	if v, ok, err := c.GetBytes("b"); string(v) != "bytes" || !ok || err != nil {
+		t.Errorf("bad bytes: %q, %v, %v", v, ok, err)
+	}
+	if v, ok, err := c.GetInt64("i"); v != 42 || !ok || err != nil {
+		t.Errorf("bad int64: %d, %v, %v", v, ok, err)
+	}
+
+	_, ok, err := c.GetInt64("s")
+	if e, isType := err.(*TypeError); !ok || !isType || e.Want != "int64" || e.Value != "str" {
+		t.Errorf("mismatches should return a TypeError: %v", err)
+	}
+	if err.Error() != "lfuda: value of key s is string, not int64" {
+		t.Errorf("bad message: %s", err)
+	}
+
+	if _, ok, err := c.GetString("missing"); ok || err != nil {
+		t.Errorf("misses aren't errors: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package lfuda
+
+// TypedCache is a Cache holding keys of type K and values of type V, checked at
+// compile time rather than asserted by every caller.  It adds type safety only:
+// it wraps a Cache, so the policy and options are the same and keys and values
+// are still stored as interfaces, costing the same allocations as Cache itself.
+// Pointers, maps and the like are stored as is, while other keys and values are
+// boxed on every Set and, for keys, every lookup, so store pointers where those
+// allocations matter.
+type TypedCache[K comparable, V any] struct {
+	cache *Cache
+}
+
+// NewTyped creates a TypedCache of the given size with the LFUDA policy.
+func NewTyped[K comparable, V any](size float64, opts ...Option) *TypedCache[K, V] {
+	return Typed[K, V](New(size, opts...))
+}
+
+// Typed wraps c, which should only hold keys of type K and values of type V, in
+// a TypedCache.  Use it for caches created with other policies or by a Manager.
+func Typed[K comparable, V any](c *Cache) *TypedCache[K, V] {
+	return &TypedCache[K, V]{cache: c}
+}
+
+// Cache returns the wrapped Cache, for the methods TypedCache doesn't mirror.
+func (t *TypedCache[K, V]) Cache() *Cache {
+	return t.cache
+}
+
+// Set adds a value to the cache.  Returns true if an eviction occurred.
+func (t *TypedCache[K, V]) Set(key K, value V) bool {
+	return t.cache.Set(key, value)
+}
+
+// SetWithSize adds a value to the cache with an explicit size in bytes.  Returns
+// true if an eviction occurred.
+func (t *TypedCache[K, V]) SetWithSize(key K, value V, size float64) bool {
+	return t.cache.SetWithSize(key, value, size)
+}
+
+// Get looks up a key's value from the cache.  A value of another type, set
+// through Cache, is reported missing.
+func (t *TypedCache[K, V]) Get(key K) (value V, ok bool) {
+	v, ok := t.cache.Get(key)
+	if !ok {
+		return value, false
+	}
+	return typed[V](v)
+}
+
+// Peek returns a key's value without updating its hits.  A value of another
+// type, set through Cache, is reported missing.
+func (t *TypedCache[K, V]) Peek(key K) (value V, ok bool) {
+	v, ok := t.cache.Peek(key)
+	if !ok {
+		return value, false
+	}
+	return typed[V](v)
+}
+
+// typed asserts a stored value is a V.  A nil interface is only a V if V is an
+// interface type, as typed nils such as nil slices are stored with their type.
+func typed[V any](v interface{}) (value V, ok bool) {
+	if v == nil {
+		return value, interface{}(value) == nil
+	}
+	value, ok = v.(V)
+	return value, ok
+}
+
+// Contains checks if a key is in the cache without updating its hits.
+func (t *TypedCache[K, V]) Contains(key K) bool {
+	return t.cache.Contains(key)
+}
+
+// Remove removes the provided key from the cache.
+func (t *TypedCache[K, V]) Remove(key K) bool {
+	return t.cache.Remove(key)
+}
+
+// Keys returns a slice of the keys in the cache, highest priority first.
+func (t *TypedCache[K, V]) Keys() []K {
+	keys := t.cache.Keys()
+	typed := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if k, ok := key.(K); ok {
+			typed = append(typed, k)
+		}
+	}
+	return typed
+}
+
+// Len returns the number of items in the cache.
+func (t *TypedCache[K, V]) Len() int {
+	return t.cache.Len()
+}
+
+// Size returns the total size of the items in the cache in bytes, or in entries
+// if it was created WithEntryCounting or WithMaxEntries.
+func (t *TypedCache[K, V]) Size() float64 {
+	return t.cache.Size()
+}
+
+// Purge is used to completely clear the cache.
+func (t *TypedCache[K, V]) Purge() {
+	t.cache.Purge()
+}
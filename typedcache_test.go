@@ -0,0 +1,50 @@
+package lfuda
+
+import "testing"
+
+func TestTypedCache(t *testing.T) {
+	c := NewTyped[string, []byte](10)
+	c.Set("a", []byte("aaaa"))
+	c.SetWithSize("b", nil, 2)
+	if v, ok := c.Get("a"); !ok || string(v) != "aaaa" {
+		t.Errorf("bad value: %q, %v", v, ok)
+	}
+	if v, ok := c.Peek("b"); !ok || v != nil {
+		t.Errorf("nil values should be found: %q, %v", v, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("missing keys shouldn't be found")
+	}
+	if keys := c.Keys(); len(keys) != 2 || c.Len() != 2 || c.Size() != 6 {
+		t.Errorf("bad contents: %v, %f", keys, c.Size())
+	}
+
+	// a value of the wrong type set through the Cache reads as missing
+	c.Cache().Set("c", "str")
+	if v, ok := c.Get("c"); ok || v != nil {
+		t.Errorf("mistyped values should be missing: %q, %v", v, ok)
+	}
+	c.Cache().Set("d", nil)
+	if _, ok := c.Peek("d"); ok {
+		t.Errorf("untyped nils aren't slices")
+	}
+	errs := NewTyped[string, error](10)
+	errs.Set("e", nil)
+	if v, ok := errs.Get("e"); !ok || v != nil {
+		t.Errorf("nil is a valid interface value: %v, %v", v, ok)
+	}
+
+	if !c.Remove("a") || c.Contains("a") {
+		t.Errorf("a should be removed")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("should be empty")
+	}
+
+	lfu := Typed[int, int](NewLFU(10))
+	lfu.Set(1, 2)
+	if v, _ := lfu.Get(1); v != 2 {
+		t.Errorf("bad value: %d", v)
+	}
+}